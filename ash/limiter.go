@@ -0,0 +1,308 @@
+package ash
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/flame"
+	"github.com/256dpi/fire/glut"
+	"github.com/256dpi/fire/stick"
+)
+
+const limiterLockTimeout = 5 * time.Second
+const limiterLockWait = time.Second
+
+// Limit defines a token bucket rate limit. A bucket holds up to Burst tokens
+// and is refilled with one token per Interval. Every request takes one token
+// from the bucket.
+type Limit struct {
+	Burst    int64
+	Interval time.Duration
+}
+
+// Limiter contains limits that are used to rate limit operations. Like with a
+// strategy, the most specific limit is selected. Requests are limited per
+// controller, selected limit and key using token buckets stored with glut,
+// which allows the limits to be enforced across multiple instances.
+//
+// The "X-RateLimit-Limit", "X-RateLimit-Remaining" and "X-RateLimit-Reset"
+// headers are set on limited responses. Requests that exceed the limit are
+// rejected with a "Too Many Requests" status and a "Retry-After" header.
+// Requests wait briefly for the bucket if it is locked by a concurrent request
+// with the same key and are rejected if it remains locked. Virtual
+// sub-requests like includes, bulk items and atomic operations are limited
+// without setting the headers.
+//
+// Note: The callback should be run after the identity has been established
+// using the flame and ash callbacks.
+type Limiter struct {
+	// The store used to persist the token buckets. The buckets are updated
+	// outside the transaction of the request.
+	//
+	// Note: Lungo stores do not support writes while a transaction is open.
+	// The store must therefore differ from the store of the controller when
+	// using lungo.
+	Store *coal.Store
+
+	// The function used to compute the key of a request. Requests with an
+	// empty key are not limited.
+	//
+	// Default: RequestKey.
+	Key func(ctx *fire.Context) (string, error)
+
+	// individual operations
+	List   *Limit
+	Find   *Limit
+	Create *Limit
+	Update *Limit
+	Delete *Limit
+
+	// individual action operations
+	CollectionAction map[string]*Limit
+	ResourceAction   map[string]*Limit
+
+	// all action operations
+	CollectionActions *Limit
+	ResourceActions   *Limit
+
+	// all List and Find operations
+	Read *Limit
+
+	// all Create, Update and Delete operations
+	Write *Limit
+
+	// all CollectionAction and ResourceAction operations
+	Actions *Limit
+
+	// all operations
+	All *Limit
+}
+
+// Callback will return an authorizer callback that rate limits operations
+// using the limiter.
+func (l *Limiter) Callback() *fire.Callback {
+	// check store
+	if l.Store == nil {
+		panic("ash: missing store")
+	}
+
+	// enforce defaults
+	if l.Key == nil {
+		l.Key = RequestKey
+	}
+
+	// construct and return callback
+	return fire.C("ash/Limiter.Callback", fire.Authorizer, fire.All(), func(ctx *fire.Context) error {
+		// select limit
+		scope, limit := l.selectLimit(ctx)
+		if limit == nil {
+			return nil
+		}
+
+		// get key
+		key, err := l.Key(ctx)
+		if err != nil {
+			return xo.W(err)
+		} else if key == "" {
+			return nil
+		}
+
+		// prefix key with controller and scope
+		if ctx.Controller != nil {
+			key = coal.GetMeta(ctx.Controller.Model).PluralName + "/" + scope + "/" + key
+		} else {
+			key = scope + "/" + key
+		}
+
+		// take token
+		remaining, reset, wait, err := takeToken(ctx, l.Store, key, *limit)
+		if err != nil {
+			return err
+		}
+
+		// set headers if available
+		header := http.Header{}
+		if ctx.ResponseWriter != nil {
+			header = ctx.ResponseWriter.Header()
+		}
+		header.Set("X-RateLimit-Limit", strconv.FormatInt(limit.Burst, 10))
+		header.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(reset), 10))
+
+		// check wait
+		if wait > 0 {
+			header.Set("Retry-After", strconv.FormatInt(max(ceilSeconds(wait), 1), 10))
+			return jsonapi.ErrorFromStatus(http.StatusTooManyRequests, "rate limit exceeded")
+		}
+
+		return nil
+	})
+}
+
+func (l *Limiter) selectLimit(ctx *fire.Context) (string, *Limit) {
+	// prepare candidates
+	var names []string
+	var limits []*Limit
+
+	// select candidates based on operation
+	switch ctx.Operation {
+	case fire.List:
+		names = []string{"list", "read", "all"}
+		limits = []*Limit{l.List, l.Read, l.All}
+	case fire.Find:
+		names = []string{"find", "read", "all"}
+		limits = []*Limit{l.Find, l.Read, l.All}
+	case fire.Create:
+		names = []string{"create", "write", "all"}
+		limits = []*Limit{l.Create, l.Write, l.All}
+	case fire.Update:
+		names = []string{"update", "write", "all"}
+		limits = []*Limit{l.Update, l.Write, l.All}
+	case fire.Delete:
+		names = []string{"delete", "write", "all"}
+		limits = []*Limit{l.Delete, l.Write, l.All}
+	case fire.CollectionAction:
+		action := ctx.JSONAPIRequest.CollectionAction
+		names = []string{"collection-action:" + action, "collection-actions", "actions", "all"}
+		limits = []*Limit{l.CollectionAction[action], l.CollectionActions, l.Actions, l.All}
+	case fire.ResourceAction:
+		action := ctx.JSONAPIRequest.ResourceAction
+		names = []string{"resource-action:" + action, "resource-actions", "actions", "all"}
+		limits = []*Limit{l.ResourceAction[action], l.ResourceActions, l.Actions, l.All}
+	}
+
+	// return first available limit
+	for i, limit := range limits {
+		if limit != nil {
+			return names[i], limit
+		}
+	}
+
+	return "", nil
+}
+
+// RequestKey returns a key for the request that is derived from the ash
+// identity if it is a model, the flame client or the remote IP address.
+//
+// Note: The remote address of proxied requests must be corrected before the
+// request is handled.
+func RequestKey(ctx *fire.Context) (string, error) {
+	// use identity
+	if model, ok := ctx.Data[IdentityDataKey].(coal.Model); ok {
+		return "identity:" + coal.GetMeta(model).PluralName + ":" + model.ID().Hex(), nil
+	}
+
+	// use client
+	if info, ok := ctx.Data[flame.AuthInfoDataKey].(*flame.AuthInfo); ok && info.Client != nil {
+		return "client:" + info.Client.ID().Hex(), nil
+	}
+
+	// use remote address
+	host, _, err := net.SplitHostPort(ctx.HTTPRequest.RemoteAddr)
+	if err != nil {
+		host = ctx.HTTPRequest.RemoteAddr
+	}
+
+	return "ip:" + host, nil
+}
+
+type limiterBucket struct {
+	glut.Base          `json:"-" glut:"ash/limiter/,0"`
+	Key                string     `json:"-"`
+	Tokens             float64    `json:"tokens"`
+	Updated            time.Time  `json:"updated"`
+	Deadline           *time.Time `json:"-"`
+	stick.NoValidation `json:"-"`
+}
+
+func (b *limiterBucket) GetExtension() string {
+	return b.Key
+}
+
+func (b *limiterBucket) GetDeadline() *time.Time {
+	return b.Deadline
+}
+
+func takeToken(ctx *fire.Context, store *coal.Store, key string, limit Limit) (int64, time.Duration, time.Duration, error) {
+	// trace
+	ctx.Tracer.Push("ash/takeToken")
+	defer ctx.Tracer.Pop()
+
+	// use request context to update the bucket outside the transaction
+	rc := ctx.HTTPRequest.Context()
+
+	// prepare bucket that expires once it would be full again
+	bucket := &limiterBucket{
+		Key:      key,
+		Deadline: stick.P(time.Now().Add(time.Duration(limit.Burst)*limit.Interval + limiterLockTimeout)),
+	}
+
+	// lock bucket, wait briefly while it is locked by another request
+	start := time.Now()
+	for {
+		locked, err := glut.Lock(rc, store, bucket, limiterLockTimeout)
+		if err != nil {
+			return 0, 0, 0, err
+		} else if locked {
+			break
+		}
+
+		// reject if the bucket remains locked
+		if time.Since(start) > limiterLockWait {
+			return 0, limit.Interval, limit.Interval, nil
+		}
+
+		// wait a moment
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-rc.Done():
+			return 0, 0, 0, rc.Err()
+		}
+	}
+
+	// ensure unlock
+	defer func() {
+		_, _ = glut.Unlock(rc, store, bucket)
+	}()
+
+	// refill tokens
+	now := time.Now()
+	tokens := float64(limit.Burst)
+	if !bucket.Updated.IsZero() {
+		tokens = math.Min(tokens, bucket.Tokens+float64(now.Sub(bucket.Updated))/float64(limit.Interval))
+	}
+
+	// take token or compute wait
+	var wait time.Duration
+	if tokens >= 1 {
+		tokens--
+	} else {
+		wait = time.Duration((1 - tokens) * float64(limit.Interval))
+	}
+
+	// compute reset
+	reset := time.Duration((float64(limit.Burst) - tokens) * float64(limit.Interval))
+
+	// store bucket
+	bucket.Tokens = tokens
+	bucket.Updated = now
+	bucket.Deadline = stick.P(now.Add(reset + limiterLockTimeout))
+	_, err := glut.SetLocked(rc, store, bucket)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return int64(tokens), reset, wait, nil
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
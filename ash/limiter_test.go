@@ -0,0 +1,270 @@
+package ash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/flame"
+	"github.com/256dpi/fire/glut"
+	"github.com/256dpi/fire/stick"
+)
+
+var limiterStore = coal.MustOpen(nil, "test-ash-limiter", xo.Crash)
+var bucketStore = coal.MustOpen(nil, "test-ash-limiter-buckets", xo.Crash)
+
+func TestLimiter(t *testing.T) {
+	tester := fire.NewTester(limiterStore, &postModel{})
+	tester.Clean()
+	coal.NewTester(bucketStore, &glut.Model{}).Clean()
+
+	user1 := coal.New()
+	user2 := coal.New()
+
+	tester.Assign("", &fire.Controller{
+		Model: &postModel{},
+		Authorizers: fire.L{
+			Identify(func(ctx *fire.Context) (Identity, error) {
+				if ctx.HTTPRequest.Header.Get("User") == "2" {
+					return &postModel{Base: coal.B(user2)}, nil
+				}
+				return &postModel{Base: coal.B(user1)}, nil
+			}),
+			(&Limiter{
+				Store: bucketStore,
+				List: &Limit{
+					Burst:    2,
+					Interval: time.Hour,
+				},
+				All: &Limit{
+					Burst:    1,
+					Interval: time.Minute,
+				},
+			}).Callback(),
+		},
+	})
+
+	post := tester.Insert(&postModel{
+		Title: "Hello",
+	}).ID()
+
+	for i := 1; i >= 0; i-- {
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+			assert.Equal(t, "2", r.Header().Get("X-RateLimit-Limit"))
+			assert.Equal(t, string(rune('0'+i)), r.Header().Get("X-RateLimit-Remaining"))
+			assert.NotEmpty(t, r.Header().Get("X-RateLimit-Reset"))
+			assert.Empty(t, r.Header().Get("Retry-After"))
+		})
+	}
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusTooManyRequests, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "rate limit exceeded", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		assert.Equal(t, "0", r.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "7200", r.Header().Get("X-RateLimit-Reset"))
+		assert.Equal(t, "3600", r.Header().Get("Retry-After"))
+	})
+
+	tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "1", r.Header().Get("X-RateLimit-Limit"))
+	})
+
+	tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusTooManyRequests, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "60", r.Header().Get("Retry-After"))
+	})
+
+	tester.Header["User"] = "2"
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "1", r.Header().Get("X-RateLimit-Remaining"))
+	})
+}
+
+func TestLimiterRefill(t *testing.T) {
+	tester := fire.NewTester(limiterStore, &postModel{})
+	tester.Clean()
+	coal.NewTester(bucketStore, &glut.Model{}).Clean()
+
+	tester.Assign("", &fire.Controller{
+		Model: &postModel{},
+		Authorizers: fire.L{
+			(&Limiter{
+				Store: bucketStore,
+				Read: &Limit{
+					Burst:    1,
+					Interval: 100 * time.Millisecond,
+				},
+			}).Callback(),
+		},
+	})
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+	})
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusTooManyRequests, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "1", r.Header().Get("Retry-After"))
+	})
+
+	time.Sleep(150 * time.Millisecond)
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+	})
+
+	tester.Request("DELETE", "posts/"+coal.New().Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusNotFound, r.Code, tester.DebugRequest(rq, r))
+		assert.Empty(t, r.Header().Get("X-RateLimit-Limit"))
+	})
+}
+
+func TestLimiterBusy(t *testing.T) {
+	tester := fire.NewTester(limiterStore, &postModel{})
+	tester.Clean()
+	coal.NewTester(bucketStore, &glut.Model{}).Clean()
+
+	tester.Assign("", &fire.Controller{
+		Model: &postModel{},
+		Authorizers: fire.L{
+			(&Limiter{
+				Store: bucketStore,
+				Key: func(ctx *fire.Context) (string, error) {
+					return "test", nil
+				},
+				Read: &Limit{
+					Burst:    5,
+					Interval: time.Minute,
+				},
+			}).Callback(),
+		},
+	})
+
+	bucket := &limiterBucket{Key: "posts/read/test"}
+	locked, err := glut.Lock(nil, bucketStore, bucket, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusTooManyRequests, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "0", r.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "60", r.Header().Get("Retry-After"))
+	})
+
+	unlocked, err := glut.Unlock(nil, bucketStore, bucket)
+	assert.NoError(t, err)
+	assert.True(t, unlocked)
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "4", r.Header().Get("X-RateLimit-Remaining"))
+	})
+
+	locked, err = glut.Lock(nil, bucketStore, bucket, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = glut.Unlock(nil, bucketStore, bucket)
+	}()
+
+	tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusOK, r.Code, tester.DebugRequest(rq, r))
+		assert.Equal(t, "3", r.Header().Get("X-RateLimit-Remaining"))
+	})
+}
+
+func TestLimiterSubRequest(t *testing.T) {
+	tester := fire.NewTester(limiterStore, &postModel{})
+	tester.Clean()
+	coal.NewTester(bucketStore, &glut.Model{}).Clean()
+
+	tester.Assign("", &fire.Controller{
+		Model: &postModel{},
+		Bulk:  true,
+		Authorizers: fire.L{
+			(&Limiter{
+				Store: bucketStore,
+				Create: &Limit{
+					Burst:    2,
+					Interval: time.Minute,
+				},
+			}).Callback(),
+		},
+	})
+
+	tester.Request("POST", "posts", `{
+		"data": [
+			{"type": "posts", "attributes": {"title": "A"}},
+			{"type": "posts", "attributes": {"title": "B"}}
+		]
+	}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusCreated, r.Code, tester.DebugRequest(rq, r))
+		assert.Empty(t, r.Header().Get("X-RateLimit-Limit"))
+	})
+
+	tester.Request("POST", "posts", `{
+		"data": [
+			{"type": "posts", "attributes": {"title": "C"}}
+		]
+	}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+		assert.Equal(t, http.StatusTooManyRequests, r.Code, tester.DebugRequest(rq, r))
+	})
+}
+
+func TestLimiterActions(t *testing.T) {
+	limiter := &Limiter{
+		CollectionAction: map[string]*Limit{
+			"foo": {Burst: 1},
+		},
+		Actions: &Limit{Burst: 2},
+	}
+
+	scope, limit := limiter.selectLimit(&fire.Context{Operation: fire.CollectionAction, JSONAPIRequest: &jsonapi.Request{CollectionAction: "foo"}})
+	assert.Equal(t, "collection-action:foo", scope)
+	assert.Equal(t, int64(1), limit.Burst)
+
+	scope, limit = limiter.selectLimit(&fire.Context{Operation: fire.ResourceAction, JSONAPIRequest: &jsonapi.Request{ResourceAction: "bar"}})
+	assert.Equal(t, "actions", scope)
+	assert.Equal(t, int64(2), limit.Burst)
+
+	scope, limit = limiter.selectLimit(&fire.Context{Operation: fire.List})
+	assert.Empty(t, scope)
+	assert.Nil(t, limit)
+}
+
+func TestRequestKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:5678"
+
+	key, err := RequestKey(&fire.Context{Data: stick.Map{}, HTTPRequest: req})
+	assert.NoError(t, err)
+	assert.Equal(t, "ip:1.2.3.4", key)
+
+	client := &flame.Application{Base: coal.B(coal.New())}
+	key, err = RequestKey(&fire.Context{Data: stick.Map{
+		flame.AuthInfoDataKey: &flame.AuthInfo{Client: client},
+	}, HTTPRequest: req})
+	assert.NoError(t, err)
+	assert.Equal(t, "client:"+client.ID().Hex(), key)
+
+	user := &flame.User{Base: coal.B(coal.New())}
+	key, err = RequestKey(&fire.Context{Data: stick.Map{
+		flame.AuthInfoDataKey: &flame.AuthInfo{Client: client},
+		IdentityDataKey:       user,
+	}, HTTPRequest: req})
+	assert.NoError(t, err)
+	assert.Equal(t, "identity:users:"+user.ID().Hex(), key)
+}
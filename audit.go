@@ -0,0 +1,158 @@
+package fire
+
+import (
+	"time"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func init() {
+	// add indexes
+	coal.AddIndex(&Audit{}, false, 0, "Resource", "ResourceID", "Time")
+	coal.AddIndex(&Audit{}, false, 0, "Actor", "Time")
+	coal.AddIndex(&Audit{}, false, 0, "Time")
+}
+
+// AuditChange describes the change of a single field.
+type AuditChange struct {
+	// The BSON key of the changed field.
+	Field string `json:"field"`
+
+	// The value before the change.
+	Before interface{} `json:"before"`
+
+	// The value after the change.
+	After interface{} `json:"after"`
+}
+
+// Audit stores a single write operation performed through a controller.
+type Audit struct {
+	coal.Base `json:"-" bson:",inline" coal:"audits"`
+
+	// The time when the operation was performed.
+	Time time.Time `json:"time"`
+
+	// The performed operation e.g. "Create", "Update" or "Delete".
+	Operation string `json:"operation"`
+
+	// The resource type e.g. "posts".
+	Resource string `json:"resource"`
+
+	// The resource ID.
+	ResourceID coal.ID `json:"resource-id" bson:"resource_id"`
+
+	// The ID of the acting identity, if available.
+	Actor *coal.ID `json:"actor"`
+
+	// The type of the acting identity, if available.
+	ActorType string `json:"actor-type" bson:"actor_type"`
+
+	// The changed fields on updates.
+	Changes []AuditChange `json:"changes"`
+
+	// The resource snapshot on creates and deletes.
+	Snapshot stick.Map `json:"snapshot"`
+}
+
+// Validate will validate the model.
+func (a *Audit) Validate() error {
+	return stick.Validate(a, func(v *stick.Validator) {
+		v.Value("Time", false, stick.IsNotZero)
+		v.Value("Operation", false, stick.IsNotZero)
+		v.Value("Resource", false, stick.IsNotZero)
+		v.Value("ResourceID", false, stick.IsNotZero)
+		v.Value("Actor", true, stick.IsNotZero)
+	})
+}
+
+// AuditNotifier will record an Audit entry for every create, update and delete
+// operation. Updates record the changed fields while creates and deletes
+// record a snapshot of the resource. All stored fields are recorded using their
+// BSON keys, except the excluded fields which should be used to keep secrets
+// like password hashes out of the audit log. The entry is inserted using the
+// context store and is therefore part of the request transaction.
+//
+// The optional actor function may return the acting identity e.g. the
+// resource owner from the flame auth info or the ash identity:
+//
+//	fire.AuditNotifier(func(ctx *fire.Context) coal.Model {
+//		info, _ := ctx.Data[flame.AuthInfoDataKey].(*flame.AuthInfo)
+//		if info != nil && info.ResourceOwner != nil {
+//			return info.ResourceOwner
+//		}
+//		return nil
+//	}, "PasswordHash")
+func AuditNotifier(actor func(*Context) coal.Model, exclude ...string) *Callback {
+	return C("fire/AuditNotifier", Notifier, Only(Create|Update|Delete), func(ctx *Context) error {
+		// prepare audit
+		audit := &Audit{
+			Base:       coal.B(),
+			Time:       time.Now(),
+			Operation:  ctx.Operation.String(),
+			Resource:   coal.GetMeta(ctx.Model).PluralName,
+			ResourceID: ctx.Model.ID(),
+		}
+
+		// set actor
+		if actor != nil {
+			if model := actor(ctx); model != nil {
+				audit.Actor = stick.P(model.ID())
+				audit.ActorType = coal.GetMeta(model).PluralName
+			}
+		}
+
+		// record changes or snapshot
+		meta := coal.GetMeta(ctx.Model)
+		if ctx.Operation == Update {
+			for _, field := range meta.OrderedFields {
+				if field.BSONKey != "" && !stick.Contains(exclude, field.Name) && ctx.Modified(field.Name) {
+					audit.Changes = append(audit.Changes, AuditChange{
+						Field:  field.BSONKey,
+						Before: stick.MustGet(ctx.Original, field.Name),
+						After:  stick.MustGet(ctx.Model, field.Name),
+					})
+				}
+			}
+		} else {
+			snapshot := stick.MustMap(ctx.Model, stick.BSON)
+			audit.Snapshot = stick.Map{}
+			for _, field := range meta.OrderedFields {
+				if field.BSONKey != "" && !stick.Contains(exclude, field.Name) {
+					if value, ok := snapshot[field.BSONKey]; ok {
+						audit.Snapshot[field.BSONKey] = value
+					}
+				}
+			}
+		}
+
+		// insert audit
+		err := ctx.Store.M(audit).Insert(ctx, audit)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// AuditController returns a read-only controller for browsing audit entries.
+// Entries may be filtered by resource, actor and time range using the filter
+// operators e.g. "filter[actor][eq]=<id>" or "filter[time][gte]=2006-01-02".
+// Authorizers are required as the entries contain data of all audited
+// resources.
+func AuditController(store *coal.Store, authorizers []*Callback) *Controller {
+	// check authorizers
+	if len(authorizers) == 0 {
+		panic("fire: missing audit authorizers")
+	}
+
+	return &Controller{
+		Model:       &Audit{},
+		Store:       store,
+		Authorizers: authorizers,
+		Supported:   Only(List | Find),
+		Filters:     []string{"Operation", "Resource", "ResourceID", "Actor", "ActorType", "Time"},
+		Sorters:     []string{"Time"},
+	}
+}
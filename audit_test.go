@@ -0,0 +1,161 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func TestAuditNotifier(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		actor := coal.New()
+
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Authorizers: L{
+				C("TestAuditNotifier", Authorizer, All(), func(ctx *Context) error {
+					ctx.ReadableFields = stick.Subtract(ctx.ReadableFields, []string{"Published"})
+					return nil
+				}),
+			},
+			Notifiers: L{
+				AuditNotifier(func(*Context) coal.Model {
+					return &fooModel{Base: coal.B(actor)}
+				}, "TextBody"),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		}, AuditController(tester.Store, L{
+			C("TestAuditNotifier", Authorizer, All(), func(ctx *Context) error {
+				if ctx.HTTPRequest.Header.Get("Admin") == "" {
+					return xo.SF("access denied")
+				}
+				return nil
+			}),
+		}))
+
+		// create post
+		var id string
+		tester.Request("POST", "posts", `{
+			"data": {
+				"type": "posts",
+				"attributes": {
+					"title": "Hello",
+					"text-body": "secret"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			id = gjson.Get(r.Body.String(), "data.id").String()
+		})
+
+		// update post
+		tester.Request("PATCH", "posts/"+id, `{
+			"data": {
+				"type": "posts",
+				"id": "`+id+`",
+				"attributes": {
+					"title": "World",
+					"text-body": "other secret"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// delete post
+		tester.Request("DELETE", "posts/"+id, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		audits := *tester.FindAll(&Audit{}).(*[]*Audit)
+		assert.Len(t, audits, 3)
+
+		assert.Equal(t, "Create", audits[0].Operation)
+		assert.Equal(t, "posts", audits[0].Resource)
+		assert.Equal(t, id, audits[0].ResourceID.Hex())
+		assert.Equal(t, &actor, audits[0].Actor)
+		assert.Equal(t, "foos", audits[0].ActorType)
+		assert.Empty(t, audits[0].Changes)
+		assert.Equal(t, "Hello", audits[0].Snapshot["title"])
+		assert.Equal(t, false, audits[0].Snapshot["published"])
+		assert.Contains(t, audits[0].Snapshot, "deleted_at")
+		assert.NotContains(t, audits[0].Snapshot, "text_body")
+
+		assert.Equal(t, "Update", audits[1].Operation)
+		assert.Equal(t, []AuditChange{
+			{Field: "title", Before: "Hello", After: "World"},
+		}, audits[1].Changes)
+		assert.Nil(t, audits[1].Snapshot)
+
+		assert.Equal(t, "Delete", audits[2].Operation)
+		assert.Equal(t, "World", audits[2].Snapshot["title"])
+		assert.NotContains(t, audits[2].Snapshot, "text_body")
+
+		// list audits without authorization
+		tester.Request("GET", "audits", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnauthorized, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Header["Admin"] = "1"
+
+		// list audits
+		tester.Request("GET", "audits?filter[resource-id][eq]="+id+"&filter[operation]=Update", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(1), gjson.Get(r.Body.String(), "data.#").Int())
+			assert.JSONEq(t, `[
+				{
+					"field": "title",
+					"before": "Hello",
+					"after": "World"
+				}
+			]`, gjson.Get(r.Body.String(), "data.0.attributes.changes").Raw)
+		})
+
+		old := tester.Insert(&Audit{
+			Time:       time.Now().Add(-48 * time.Hour),
+			Operation:  "Create",
+			Resource:   "posts",
+			ResourceID: coal.New(),
+			Actor:      &actor,
+			ActorType:  "foos",
+		}).(*Audit)
+
+		// list audits by actor and time range
+		since := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+		tester.Request("GET", "audits?filter[actor][eq]="+actor.Hex()+"&filter[time][lt]="+since, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+old.ID().Hex()+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		tester.Request("GET", "audits?filter[actor][eq]="+actor.Hex()+"&filter[time][gte]="+since+"&sort=time", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(3), gjson.Get(r.Body.String(), "data.#").Int())
+		})
+
+		// create audit
+		tester.Request("POST", "audits", `{
+			"data": {
+				"type": "audits"
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusMethodNotAllowed, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.PanicsWithValue(t, "fire: missing audit authorizers", func() {
+			AuditController(tester.Store, nil)
+		})
+	})
+}
@@ -0,0 +1,205 @@
+package fire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/serve"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/stick"
+)
+
+var errBulkFailed = errors.New("bulk failed")
+
+func (c *Controller) parseBulk(prefix string, ctx *Context) *jsonapi.Document {
+	// check method
+	method := ctx.HTTPRequest.Method
+	if method != "POST" && method != "PATCH" && method != "DELETE" {
+		return nil
+	}
+
+	// check path
+	path := strings.Trim(ctx.HTTPRequest.URL.Path, "/")
+	path = strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if path != c.meta.PluralName {
+		return nil
+	}
+
+	// check content type header
+	contentType := ctx.HTTPRequest.Header.Get("Content-Type")
+	if contentType == "" {
+		xo.Abort(jsonapi.BadRequest("missing content type header"))
+	} else if contentType != jsonapi.MediaType {
+		xo.Abort(jsonapi.BadRequest("invalid content type header"))
+	}
+
+	// check accept header
+	accept := ctx.HTTPRequest.Header.Get("Accept")
+	if accept != "" && accept != "*/*" && accept != "application/*" && accept != "application/json" && accept != jsonapi.MediaType {
+		xo.Abort(jsonapi.ErrorFromStatus(http.StatusNotAcceptable, "invalid accept header"))
+	}
+
+	// limit request body size
+	serve.LimitBody(ctx.ResponseWriter, ctx.HTTPRequest, c.DocumentLimit)
+
+	// parse document
+	doc, err := jsonapi.ParseDocument(ctx.HTTPRequest.Body)
+	xo.AbortIf(err)
+
+	// set document for regular requests
+	if doc.Data == nil || doc.Data.Many == nil {
+		ctx.Request = doc
+		return nil
+	}
+
+	// preconditions cannot be applied to multiple resources, the update
+	// tokens must be provided using the document fields instead
+	if ctx.HTTPRequest.Header.Get("If-Match") != "" {
+		xo.Abort(jsonapi.BadRequest("preconditions are not supported for bulk requests"))
+	}
+
+	return doc
+}
+
+func (c *Controller) handleBulk(prefix string, ctx *Context, doc *jsonapi.Document) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.handleBulk")
+	defer ctx.Tracer.Pop()
+
+	// determine intent and status
+	var intent jsonapi.Intent
+	var status int
+	switch ctx.HTTPRequest.Method {
+	case "POST":
+		intent = jsonapi.CreateResource
+		status = http.StatusCreated
+	case "PATCH":
+		intent = jsonapi.UpdateResource
+		status = http.StatusOK
+	case "DELETE":
+		intent = jsonapi.DeleteResource
+		status = http.StatusNoContent
+	}
+
+	// prepare results
+	results := make([]*jsonapi.Resource, 0, len(doc.Data.Many))
+	var bulkError *jsonapi.Error
+
+	// run requests in transaction, stop at the first failed request as the
+	// transaction may not be used after a failed write
+	err := c.Store.T(ctx.Context, false, func(tc context.Context) error {
+		return ctx.With(tc, func() error {
+			for i, res := range doc.Data.Many {
+				result, err := c.runBulkRequest(prefix, ctx, intent, i, res)
+				if err != nil {
+					bulkError = err
+					return errBulkFailed
+				} else if result != nil {
+					results = append(results, result)
+				}
+			}
+
+			return nil
+		})
+	})
+	if bulkError != nil {
+		xo.AbortIf(jsonapi.WriteError(ctx.ResponseWriter, bulkError))
+		return
+	}
+	xo.AbortIf(err)
+
+	// write status for deletions
+	if intent == jsonapi.DeleteResource {
+		ctx.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	// prepare document
+	response := &jsonapi.Document{
+		Data: &jsonapi.HybridResource{
+			Many: results,
+		},
+	}
+
+	// transform outgoing resources if an older API version is requested
+	c.transformResponse(ctx, response)
+
+	// write results
+	xo.AbortIf(jsonapi.WriteResponse(ctx.ResponseWriter, status, response))
+}
+
+func (c *Controller) runBulkRequest(prefix string, ctx *Context, intent jsonapi.Intent, index int, res *jsonapi.Resource) (result *jsonapi.Resource, bulkError *jsonapi.Error) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.runBulkRequest")
+	defer ctx.Tracer.Pop()
+
+	// capture errors and point them to the resource
+	defer xo.Resume(func(err error) {
+		// re-abort other errors
+		var jsonapiError *jsonapi.Error
+		if !errors.As(err, &jsonapiError) {
+			xo.Abort(err)
+		}
+
+		// copy error and prefix pointer
+		bulkError = &jsonapi.Error{}
+		*bulkError = *jsonapiError
+		pointer := fmt.Sprintf("/data/%d", index)
+		if jsonapiError.Source != nil && strings.HasPrefix(jsonapiError.Source.Pointer, "/data/") {
+			pointer += strings.TrimPrefix(jsonapiError.Source.Pointer, "/data")
+		}
+		bulkError.Source = &jsonapi.ErrorSource{
+			Pointer: pointer,
+		}
+	})
+
+	// check resource type and ID
+	if res.Type != c.meta.PluralName {
+		xo.Abort(jsonapi.BadRequestPointer("resource type mismatch", "/data/type"))
+	} else if intent != jsonapi.CreateResource && res.ID == "" {
+		xo.Abort(jsonapi.BadRequestPointer("missing resource ID", "/data/id"))
+	}
+
+	// prepare request
+	req := &jsonapi.Request{
+		Prefix:       prefix,
+		Intent:       intent,
+		ResourceType: c.meta.PluralName,
+	}
+	if intent != jsonapi.CreateResource {
+		req.ResourceID = res.ID
+	}
+
+	// prepare context
+	subCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		HTTPRequest:    ctx.HTTPRequest,
+		Controller:     c,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: req,
+		Request: &jsonapi.Document{
+			Data: &jsonapi.HybridResource{
+				One: res,
+			},
+		},
+		tenant: ctx.tenant,
+	}
+
+	// handle request
+	c.handle(prefix, subCtx, nil, false)
+
+	// get result
+	if subCtx.Response != nil && subCtx.Response.Data != nil {
+		return subCtx.Response.Data.One, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,226 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestBulk(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Bulk:  true,
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create posts
+		var post1, post2 string
+		tester.Request("POST", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"attributes": {
+						"title": "Post 1"
+					}
+				},
+				{
+					"type": "posts",
+					"attributes": {
+						"title": "Post 2"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `["Post 1", "Post 2"]`, gjson.Get(r.Body.String(), "data.#.attributes.title").Raw)
+			post1 = gjson.Get(r.Body.String(), "data.0.id").String()
+			post2 = gjson.Get(r.Body.String(), "data.1.id").String()
+		})
+
+		assert.Equal(t, 2, tester.Count(&postModel{}))
+
+		// create single post
+		tester.Request("POST", "posts", `{
+			"data": {
+				"type": "posts",
+				"attributes": {
+					"title": "Post 3"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post 3", gjson.Get(r.Body.String(), "data.attributes.title").String())
+		})
+
+		assert.Equal(t, 3, tester.Count(&postModel{}))
+
+		// update posts
+		tester.Request("PATCH", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"id": "`+post1+`",
+					"attributes": {
+						"title": "Post 1!"
+					}
+				},
+				{
+					"type": "posts",
+					"id": "`+post2+`",
+					"attributes": {
+						"title": "Post 2!"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `["Post 1!", "Post 2!"]`, gjson.Get(r.Body.String(), "data.#.attributes.title").Raw)
+		})
+
+		// create invalid posts
+		tester.Request("POST", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"attributes": {
+						"title": "Post 4"
+					}
+				},
+				{
+					"type": "posts",
+					"attributes": {
+						"title": "error"
+					}
+				},
+				{
+					"type": "posts",
+					"attributes": {
+						"foo": "bar"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [
+					{
+						"status": "400",
+						"title": "bad request",
+						"detail": "validation error",
+						"source": {
+							"pointer": "/data/1"
+						}
+					}
+				]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 3, tester.Count(&postModel{}))
+
+		// create invalid post
+		tester.Request("POST", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"attributes": {
+						"foo": "bar"
+					}
+				},
+				{
+					"type": "posts",
+					"attributes": {
+						"title": "error"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Len(t, gjson.Get(r.Body.String(), "errors").Array(), 1, tester.DebugRequest(rq, r))
+			assert.Equal(t, "/data/0/attributes/foo", gjson.Get(r.Body.String(), "errors.0.source.pointer").String(), tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 3, tester.Count(&postModel{}))
+
+		// delete missing post
+		tester.Request("DELETE", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"id": "`+post1+`"
+				},
+				{
+					"type": "posts"
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [
+					{
+						"status": "400",
+						"title": "bad request",
+						"detail": "missing resource ID",
+						"source": {
+							"pointer": "/data/1/id"
+						}
+					}
+				]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 3, tester.Count(&postModel{}))
+
+		// delete posts
+		tester.Request("DELETE", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"id": "`+post1+`"
+				},
+				{
+					"type": "posts",
+					"id": "`+post2+`"
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Empty(t, r.Body.String())
+		})
+
+		assert.Equal(t, 1, tester.Count(&postModel{}))
+
+		// check content type before parsing
+		tester.Header["Content-Type"] = "text/plain"
+		tester.Request("POST", "posts", `{`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "invalid content type header", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+		delete(tester.Header, "Content-Type")
+
+		// reject preconditions
+		tester.Header["If-Match"] = `"foo"`
+		tester.Request("DELETE", "posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"id": "`+post1+`"
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "preconditions are not supported for bulk requests", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+		delete(tester.Header, "If-Match")
+
+		assert.Equal(t, 1, tester.Count(&postModel{}))
+	})
+}
@@ -0,0 +1,299 @@
+package fire
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// Cache is an in-process LRU cache for list and find responses. Cached
+// responses are invalidated using change streams that are lazily opened on
+// the models of the controllers using the cache. A cache may be shared by
+// multiple controllers.
+type Cache struct {
+	size       int
+	mutex      sync.Mutex
+	list       *list.List
+	entries    map[string]*list.Element
+	watchers   map[string]*cacheWatcher
+	generation uint64
+}
+
+type cacheWatcher struct {
+	stream *coal.Stream
+	ready  bool
+}
+
+type cacheEntry struct {
+	key   string
+	model string
+	id    coal.ID
+	deps  []string
+	code  int
+	body  []byte
+}
+
+type cacheMiss struct {
+	entry      *cacheEntry
+	generation uint64
+}
+
+// NewCache creates and returns a new cache that holds up to the specified
+// number of responses.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:     size,
+		list:     list.New(),
+		entries:  map[string]*list.Element{},
+		watchers: map[string]*cacheWatcher{},
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *Cache) Len() int {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.list.Len()
+}
+
+// Close will close all opened streams and remove all cached responses.
+func (c *Cache) Close() {
+	// acquire mutex
+	c.mutex.Lock()
+	watchers := c.watchers
+	c.watchers = map[string]*cacheWatcher{}
+	c.list.Init()
+	c.entries = map[string]*list.Element{}
+	c.generation++
+	c.mutex.Unlock()
+
+	// close streams
+	for _, watcher := range watchers {
+		watcher.stream.Close()
+	}
+}
+
+func (c *Cache) watch(store *coal.Store, model coal.Model, reporter func(error)) bool {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get name
+	name := coal.GetMeta(model).PluralName
+
+	// check watcher
+	watcher := c.watchers[name]
+	if watcher != nil {
+		return watcher.ready
+	}
+
+	// create watcher
+	watcher = &cacheWatcher{}
+	c.watchers[name] = watcher
+
+	// open stream
+	watcher.stream = coal.OpenStream(store, model, nil, func(event coal.Event, id coal.ID, _ coal.Model, err error, _ []byte) error {
+		// acquire mutex
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		// handle event
+		switch event {
+		case coal.Opened, coal.Resumed:
+			c.invalidate(name, nil)
+			watcher.ready = true
+		case coal.Created, coal.Updated, coal.Deleted:
+			c.invalidate(name, &id)
+		case coal.Errored:
+			c.invalidate(name, nil)
+			watcher.ready = false
+			if reporter != nil {
+				reporter(err)
+			}
+		case coal.Stopped:
+			watcher.ready = false
+		}
+
+		return nil
+	})
+
+	return false
+}
+
+func (c *Cache) get(key string) (*cacheEntry, uint64) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get entry
+	elem := c.entries[key]
+	if elem == nil {
+		return nil, c.generation
+	}
+
+	// mark as recently used
+	c.list.MoveToFront(elem)
+
+	return elem.Value.(*cacheEntry), c.generation
+}
+
+func (c *Cache) set(entry *cacheEntry, generation uint64) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// skip if changes have been observed since the lookup
+	if c.generation != generation {
+		return
+	}
+
+	// replace existing entry
+	if elem := c.entries[entry.key]; elem != nil {
+		c.list.Remove(elem)
+	}
+
+	// add entry
+	c.entries[entry.key] = c.list.PushFront(entry)
+
+	// evict least recently used entries
+	for c.list.Len() > c.size {
+		elem := c.list.Back()
+		c.list.Remove(elem)
+		delete(c.entries, elem.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *Cache) invalidate(name string, id *coal.ID) {
+	// increment generation
+	c.generation++
+
+	// remove affected entries
+	for elem := c.list.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cacheEntry)
+		if stick.Contains(entry.deps, name) || (entry.model == name && (id == nil || entry.id.IsZero() || entry.id == *id)) {
+			c.list.Remove(elem)
+			delete(c.entries, entry.key)
+		}
+		elem = next
+	}
+}
+
+func (c *Controller) lookupCache(ctx *Context) (*cacheMiss, bool) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.lookupCache")
+	defer ctx.Tracer.Pop()
+
+	// skip if no cache is configured or includes are requested
+	if c.Cache == nil || len(ctx.JSONAPIRequest.Include) > 0 {
+		return nil, false
+	}
+
+	// skip virtual sub-requests that require the loaded models
+	if ctx.ResponseWriter == nil {
+		return nil, false
+	}
+
+	// skip if readable fields or properties depend on the models
+	if ctx.GetReadableFields != nil || ctx.GetReadableProperties != nil {
+		return nil, false
+	}
+
+	// get reporter
+	var reporter func(error)
+	if ctx.Group != nil {
+		reporter = ctx.Group.reporter
+	}
+
+	// watch model
+	ready := c.Cache.watch(c.Store, c.Model, reporter)
+
+	// watch related models of readable has-one, has-many and to-many
+	// relationships as their data or counts depend on the related models
+	var deps []string
+	for _, field := range c.meta.Relationships {
+		if (field.HasOne || field.HasMany || field.ToMany) && stick.Contains(ctx.ReadableFields, field.Name) {
+			rc := c.relatedController(ctx, field)
+			if !c.Cache.watch(rc.Store, rc.Model, reporter) {
+				ready = false
+			}
+			deps = append(deps, field.RelType)
+		}
+	}
+
+	// compute key
+	buf, err := json.Marshal([]interface{}{
+		c.meta.PluralName,
+		ctx.JSONAPIRequest.Self(),
+		ctx.Selector,
+		ctx.Filters,
+		ctx.RelationshipFilters,
+		ctx.ReadableFields,
+		ctx.ReadableProperties,
+	})
+	xo.AbortIf(err)
+	sum := sha256.Sum256(buf)
+	key := hex.EncodeToString(sum[:])
+
+	// get entry
+	entry, generation := c.Cache.get(key)
+	if entry != nil {
+		// decode response
+		doc, err := jsonapi.ParseDocument(bytes.NewReader(entry.body))
+		xo.AbortIf(err)
+
+		// set response
+		ctx.Response = doc
+		ctx.ResponseCode = entry.code
+
+		return nil, true
+	}
+
+	// skip if streams are not yet ready
+	if !ready {
+		return nil, false
+	}
+
+	// get ID
+	var id coal.ID
+	if ctx.Operation == Find {
+		id, _ = ctx.Selector["_id"].(coal.ID)
+	}
+
+	return &cacheMiss{
+		entry: &cacheEntry{
+			key:   key,
+			model: c.meta.PluralName,
+			id:    id,
+			deps:  deps,
+		},
+		generation: generation,
+	}, false
+}
+
+func (c *Controller) storeCache(ctx *Context, miss *cacheMiss) {
+	// skip if not cacheable
+	if miss == nil || ctx.Response == nil {
+		return
+	}
+
+	// encode response
+	body, err := json.Marshal(ctx.Response)
+	xo.AbortIf(err)
+
+	// set entry
+	miss.entry.code = ctx.ResponseCode
+	miss.entry.body = body
+	c.Cache.set(miss.entry, miss.generation)
+}
@@ -0,0 +1,300 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+)
+
+func TestCache(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		cache := NewCache(10)
+		defer cache.Close()
+
+		var notified int
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Cache: cache,
+			Authorizers: L{
+				C("TestCache", Authorizer, All(), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Published") != "" {
+						ctx.Filters = append(ctx.Filters, bson.M{"Published": true})
+					}
+					if ctx.HTTPRequest.Header.Get("Getter") != "" {
+						ctx.GetReadableFields = func(model coal.Model) []string {
+							return ctx.ReadableFields
+						}
+					}
+					return nil
+				}),
+			},
+			Notifiers: L{
+				C("TestCache", Notifier, All(), func(ctx *Context) error {
+					notified++
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			Cache:            cache,
+			ConsistentUpdate: true,
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "Post 1",
+		}).ID()
+
+		// wait for streams
+		assert.Eventually(t, func() bool {
+			tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			})
+			return cache.Len() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		// get cached list
+		notified = 0
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post 1", gjson.Get(r.Body.String(), "data.0.attributes.title").String(), tester.DebugRequest(rq, r))
+			assert.NotEmpty(t, r.Header().Get("ETag"))
+		})
+		assert.Equal(t, 0, notified)
+
+		// get list with authorization filter
+		tester.Header["Published"] = "1"
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Len(t, gjson.Get(r.Body.String(), "data").Array(), 0, tester.DebugRequest(rq, r))
+		})
+		delete(tester.Header, "Published")
+		assert.Equal(t, 2, cache.Len())
+
+		// get resource
+		notified = 0
+		for i := 0; i < 2; i++ {
+			tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, "Post 1", gjson.Get(r.Body.String(), "data.attributes.title").String(), tester.DebugRequest(rq, r))
+			})
+		}
+		assert.Equal(t, 3, cache.Len())
+		assert.Equal(t, 1, notified)
+
+		// create other post
+		tester.Insert(&postModel{
+			Title: "Post 2",
+		})
+		assert.Eventually(t, func() bool {
+			return cache.Len() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		// update post
+		tester.Update(&postModel{Base: coal.B(post)}, bson.M{
+			"$set": bson.M{"Title": "Post 3"},
+		})
+		assert.Eventually(t, func() bool {
+			return cache.Len() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post 3", gjson.Get(r.Body.String(), "data.attributes.title").String(), tester.DebugRequest(rq, r))
+			assert.Empty(t, gjson.Get(r.Body.String(), "data.relationships.comments.data").Array(), tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, 1, cache.Len())
+
+		// create related comment
+		comment := tester.Insert(&commentModel{
+			Message: "Comment 1",
+			Post:    post,
+		}).ID()
+		assert.Eventually(t, func() bool {
+			return cache.Len() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, comment.Hex(), gjson.Get(r.Body.String(), "data.relationships.comments.data.0.id").String(), tester.DebugRequest(rq, r))
+		})
+
+		// skip includes
+		tester.Request("GET", "posts?include=comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, 1, cache.Len())
+
+		// skip getters
+		tester.Header["Getter"] = "1"
+		notified = 0
+		for i := 0; i < 2; i++ {
+			tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			})
+		}
+		delete(tester.Header, "Getter")
+		assert.Equal(t, 1, cache.Len())
+		assert.Equal(t, 2, notified)
+
+		// find consistent update resource
+		selection := tester.Insert(&selectionModel{
+			UpdateToken: "token",
+		}).ID()
+		assert.Eventually(t, func() bool {
+			return cache.Len() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		var etag string
+		assert.Eventually(t, func() bool {
+			tester.Request("GET", "selections/"+selection.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Regexp(t, `^W/"[0-9a-f]{40}"$`, r.Header().Get("ETag"))
+				etag = r.Header().Get("ETag")
+			})
+			return cache.Len() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		// check cached entity tag
+		tester.Header["If-None-Match"] = etag
+		tester.Request("GET", "selections/"+selection.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotModified, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, etag, r.Header().Get("ETag"))
+		})
+		delete(tester.Header, "If-None-Match")
+	})
+}
+
+func TestCacheCallbacks(t *testing.T) {
+	cache := NewCache(10)
+
+	assert.PanicsWithValue(t, `fire: cache cannot be combined with verifiers or decorators for model "fire.postModel"`, func() {
+		NewGroup(nil).Add(&Controller{
+			Model: &postModel{},
+			Store: lungoStore,
+			Cache: cache,
+			Verifiers: L{
+				C("TestCacheCallbacks", Verifier, All(), func(ctx *Context) error {
+					return nil
+				}),
+			},
+		})
+	})
+
+	assert.PanicsWithValue(t, `fire: cache cannot be combined with verifiers or decorators for model "fire.postModel"`, func() {
+		NewGroup(nil).Add(&Controller{
+			Model: &postModel{},
+			Store: lungoStore,
+			Cache: cache,
+			Decorators: L{
+				C("TestCacheCallbacks", Decorator, All(), func(ctx *Context) error {
+					return nil
+				}),
+			},
+		})
+	})
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache := NewCache(2)
+
+	for i := 0; i < 3; i++ {
+		cache.set(&cacheEntry{key: string(rune('a' + i))}, 0)
+	}
+	assert.Equal(t, 2, cache.Len())
+
+	entry, _ := cache.get("a")
+	assert.Nil(t, entry)
+
+	entry, generation := cache.get("b")
+	assert.NotNil(t, entry)
+
+	cache.set(&cacheEntry{key: "d"}, generation)
+	entry, _ = cache.get("b")
+	assert.NotNil(t, entry)
+	entry, _ = cache.get("c")
+	assert.Nil(t, entry)
+
+	cache.invalidate("posts", nil)
+	cache.set(&cacheEntry{key: "e"}, generation)
+	entry, _ = cache.get("e")
+	assert.Nil(t, entry)
+}
+
+func TestCacheRelationships(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		cache := NewCache(10)
+		defer cache.Close()
+
+		group := tester.Assign("", &Controller{
+			Model: &postModel{},
+			Cache: cache,
+		}, &Controller{
+			Model: &commentModel{},
+			Cache: cache,
+		}, &Controller{
+			Model:              &selectionModel{},
+			Cache:              cache,
+			RelationshipCounts: []string{"Posts"},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post1 := tester.Insert(&postModel{
+			Title: "Post 1",
+		})
+		post2 := tester.Insert(&postModel{
+			Title: "Post 2",
+		})
+		tester.Insert(&commentModel{
+			Message: "Comment 1",
+			Post:    post2.ID(),
+		})
+		selection := tester.Insert(&selectionModel{
+			Posts: []coal.ID{post1.ID(), post2.ID()},
+		}).ID()
+
+		// wait for streams
+		assert.Eventually(t, func() bool {
+			tester.Request("GET", "selections/"+selection.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, int64(2), gjson.Get(r.Body.String(), "data.relationships.posts.meta.count").Int(), tester.DebugRequest(rq, r))
+			})
+			return cache.Len() == 1
+		}, time.Second, 10*time.Millisecond)
+
+		// delete related post
+		tester.Delete(post1)
+		assert.Eventually(t, func() bool {
+			return cache.Len() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		tester.Request("GET", "selections/"+selection.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(1), gjson.Get(r.Body.String(), "data.relationships.posts.meta.count").Int(), tester.DebugRequest(rq, r))
+		})
+
+		// run GraphQL queries with sub-requests
+		tester.Handler = group.GraphQL(0, 0)
+		for i := 0; i < 3; i++ {
+			tester.Request("POST", "graphql", `{
+				"query": "{ posts { title comments { message } } }"
+			}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.False(t, gjson.Get(r.Body.String(), "errors").Exists(), tester.DebugRequest(rq, r))
+				assert.Equal(t, "Comment 1", gjson.Get(r.Body.String(), "data.posts.0.comments.0.message").String(), tester.DebugRequest(rq, r))
+			})
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
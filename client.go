@@ -1,30 +1,112 @@
 package fire
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
 	"github.com/256dpi/jsonapi/v2"
 	"github.com/256dpi/xo"
 
 	"github.com/256dpi/fire/coal"
 )
 
+// ClientError is returned by the client if the server responded with multiple
+// JSON:API errors. Single errors that do not match a known error are returned
+// as a *jsonapi.Error. The returned errors can be matched with errors.As.
+type ClientError struct {
+	// The returned errors.
+	Errors []*jsonapi.Error
+}
+
+// Error implements the error interface.
+func (e *ClientError) Error() string {
+	// collect messages
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the returned errors.
+func (e *ClientError) Unwrap() []error {
+	// convert errors
+	list := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		list = append(list, err)
+	}
+
+	return list
+}
+
+// Status returns the status of the first error.
+func (e *ClientError) Status() int {
+	return e.Errors[0].Status
+}
+
+// Pointer returns the first error with the specified source pointer.
+func (e *ClientError) Pointer(pointer string) *jsonapi.Error {
+	for _, err := range e.Errors {
+		if err.Source != nil && err.Source.Pointer == pointer {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Parameter returns the first error with the specified source parameter.
+func (e *ClientError) Parameter(parameter string) *jsonapi.Error {
+	for _, err := range e.Errors {
+		if err.Source != nil && err.Source.Parameter == parameter {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Client wraps a jsonapi.Client to directly interact with models.
 type Client struct {
 	client *jsonapi.Client
+	config jsonapi.ClientConfig
+	http   *http.Client
 }
 
 // NewClient will create and return a new client.
+//
+// Note: Calling actions requires a client created using NewClientWithConfig.
 func NewClient(client *jsonapi.Client) *Client {
 	return &Client{
 		client: client,
 	}
 }
 
+// NewClientWithConfig will create and return a new client using the provided
+// config and HTTP client.
+func NewClientWithConfig(config jsonapi.ClientConfig, client *http.Client) *Client {
+	// cleanup config
+	config.BaseURI = strings.TrimSuffix(config.BaseURI, "/")
+
+	return &Client{
+		client: jsonapi.NewClientWithClient(config, client),
+		config: config,
+		http:   client,
+	}
+}
+
 // List will list the provided models.
 func (c *Client) List(model coal.Model, reqs ...jsonapi.Request) ([]coal.Model, *jsonapi.Document, error) {
 	// list resources
 	doc, err := c.client.List(c.getType(model), reqs...)
 	if err != nil {
-		return nil, doc, c.rewriteError(err)
+		return nil, doc, c.rewriteError(doc, err)
 	}
 
 	// get listed models
@@ -46,7 +128,7 @@ func (c *Client) Find(model coal.Model, reqs ...jsonapi.Request) (coal.Model, *j
 	// find resource
 	doc, err := c.client.Find(c.getType(model), model.ID().Hex(), reqs...)
 	if err != nil {
-		return nil, doc, c.rewriteError(err)
+		return nil, doc, c.rewriteError(doc, err)
 	}
 
 	// get found model
@@ -73,7 +155,7 @@ func (c *Client) Create(model coal.Model) (coal.Model, *jsonapi.Document, error)
 	// create resource
 	doc, err := c.client.Create(resource)
 	if err != nil {
-		return nil, doc, c.rewriteError(err)
+		return nil, doc, c.rewriteError(doc, err)
 	}
 
 	// get created model
@@ -97,7 +179,7 @@ func (c *Client) Update(model coal.Model) (coal.Model, *jsonapi.Document, error)
 	// update resource
 	doc, err := c.client.Update(resource)
 	if err != nil {
-		return nil, doc, c.rewriteError(err)
+		return nil, doc, c.rewriteError(doc, err)
 	}
 
 	// get updated model
@@ -113,19 +195,242 @@ func (c *Client) Update(model coal.Model) (coal.Model, *jsonapi.Document, error)
 // Delete will delete the provided model.
 func (c *Client) Delete(model coal.Model) error {
 	// delete resource
-	err := c.client.Delete(c.getType(model), model.ID().Hex())
+	doc, err := c.client.Do(jsonapi.Request{
+		Intent:       jsonapi.DeleteResource,
+		ResourceType: c.getType(model),
+		ResourceID:   model.ID().Hex(),
+	}, nil)
 	if err != nil {
-		return c.rewriteError(err)
+		return c.rewriteError(doc, err)
 	}
 
 	return nil
 }
 
+// Iterate will return an iterator that lists the provided models and follows
+// the "next" links of the returned documents to load all pages.
+func (c *Client) Iterate(model coal.Model, reqs ...jsonapi.Request) *Iterator {
+	return &Iterator{
+		client: c,
+		model:  model,
+		req:    jsonapi.Request{}.Merge(reqs...),
+		index:  -1,
+	}
+}
+
+// GetRelationship will return the IDs of the resources linked by the specified
+// to-many or has-many relationship. The additional requests may be used to
+// paginate the linkage.
+func (c *Client) GetRelationship(model coal.Model, rel string, reqs ...jsonapi.Request) ([]coal.ID, *jsonapi.Document, error) {
+	// check relationship
+	_, err := c.getRelationship(model, rel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// get relationship
+	doc, err := c.client.Do(jsonapi.Request{
+		Intent:       jsonapi.GetRelationship,
+		ResourceType: c.getType(model),
+		ResourceID:   model.ID().Hex(),
+		Relationship: rel,
+	}.Merge(reqs...), nil)
+	if err != nil {
+		return nil, doc, c.rewriteError(doc, err)
+	}
+
+	// get IDs
+	ids, err := c.getLinkage(doc)
+	if err != nil {
+		return nil, doc, err
+	}
+
+	return ids, doc, nil
+}
+
+// SetRelationship will replace the linkage of the specified to-many
+// relationship and return the new linkage.
+func (c *Client) SetRelationship(model coal.Model, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.modifyRelationship(jsonapi.SetRelationship, model, rel, ids)
+}
+
+// AppendToRelationship will add the provided IDs to the linkage of the
+// specified to-many relationship and return the new linkage.
+func (c *Client) AppendToRelationship(model coal.Model, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.modifyRelationship(jsonapi.AppendToRelationship, model, rel, ids)
+}
+
+// RemoveFromRelationship will remove the provided IDs from the linkage of the
+// specified to-many relationship and return the new linkage.
+func (c *Client) RemoveFromRelationship(model coal.Model, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.modifyRelationship(jsonapi.RemoveFromRelationship, model, rel, ids)
+}
+
+// CollectionAction will call the specified collection action using the
+// provided method. The input is encoded as JSON if present and the response
+// is decoded into the output if present.
+func (c *Client) CollectionAction(model coal.Model, method, name string, in, out interface{}) error {
+	return c.action(method, jsonapi.Request{
+		ResourceType:     c.getType(model),
+		CollectionAction: name,
+	}, in, out)
+}
+
+// ResourceAction will call the specified resource action using the provided
+// method. The input is encoded as JSON if present and the response is decoded
+// into the output if present.
+func (c *Client) ResourceAction(model coal.Model, method, name string, in, out interface{}) error {
+	return c.action(method, jsonapi.Request{
+		ResourceType:   c.getType(model),
+		ResourceID:     model.ID().Hex(),
+		ResourceAction: name,
+	}, in, out)
+}
+
 func (c *Client) getType(model coal.Model) string {
 	return coal.GetMeta(model).PluralName
 }
 
-func (c *Client) rewriteError(err error) error {
+func (c *Client) getRelationship(model coal.Model, rel string) (*coal.Field, error) {
+	// get field
+	field := coal.GetMeta(model).Relationships[rel]
+	if field == nil || (!field.ToMany && !field.HasMany) {
+		return nil, xo.F("invalid to-many relationship %q", rel)
+	}
+
+	return field, nil
+}
+
+func (c *Client) getLinkage(doc *jsonapi.Document) ([]coal.ID, error) {
+	// check data
+	if doc == nil || doc.Data == nil {
+		return nil, xo.F("missing relationship data")
+	}
+
+	// convert references
+	ids := make([]coal.ID, 0, len(doc.Data.Many))
+	for _, resource := range doc.Data.Many {
+		id, err := coal.FromHex(resource.ID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (c *Client) modifyRelationship(intent jsonapi.Intent, model coal.Model, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	// check relationship
+	field, err := c.getRelationship(model, rel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// prepare references
+	references := make([]*jsonapi.Resource, 0, len(ids))
+	for _, id := range ids {
+		references = append(references, &jsonapi.Resource{
+			Type: field.RelType,
+			ID:   id.Hex(),
+		})
+	}
+
+	// modify relationship
+	doc, err := c.client.Do(jsonapi.Request{
+		Intent:       intent,
+		ResourceType: c.getType(model),
+		ResourceID:   model.ID().Hex(),
+		Relationship: rel,
+	}, &jsonapi.Document{
+		Data: &jsonapi.HybridResource{
+			Many: references,
+		},
+	})
+	if err != nil {
+		return nil, doc, c.rewriteError(doc, err)
+	}
+
+	// get IDs
+	ids, err = c.getLinkage(doc)
+	if err != nil {
+		return nil, doc, err
+	}
+
+	return ids, doc, nil
+}
+
+func (c *Client) action(method string, req jsonapi.Request, in, out interface{}) error {
+	// check client
+	if c.http == nil {
+		return xo.F("actions require a client created using NewClientWithConfig")
+	}
+
+	// prepare body
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// create request
+	r, err := http.NewRequest(method, c.config.BaseURI+req.Self(), body)
+	if err != nil {
+		return err
+	}
+
+	// set content type if body is set
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	// authorize request if available
+	if c.config.Authorizer != nil {
+		c.config.Authorizer(r)
+	}
+
+	// perform request
+	res, err := c.http.Do(r)
+	if err != nil {
+		return err
+	}
+
+	// ensure body is closed
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	// prepare reader
+	var reader io.Reader = res.Body
+	if c.config.ResponseLimit > 0 {
+		reader = io.LimitReader(res.Body, c.config.ResponseLimit)
+	}
+
+	// handle errors
+	if res.StatusCode >= 400 {
+		var doc jsonapi.Document
+		err = json.NewDecoder(reader).Decode(&doc)
+		if err != nil || len(doc.Errors) == 0 {
+			return xo.F("unexpected status code: %s", res.Status)
+		}
+		return c.rewriteError(&doc, doc.Errors[0])
+	}
+
+	// decode output if available
+	if out != nil && res.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(reader).Decode(out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) rewriteError(doc *jsonapi.Document, err error) error {
 	// get error
 	je, ok := err.(*jsonapi.Error)
 	if !ok {
@@ -144,7 +449,117 @@ func (c *Client) rewriteError(err error) error {
 		}
 	}
 
-	return err
+	// wrap multiple returned errors
+	if doc != nil && len(doc.Errors) > 1 {
+		return &ClientError{
+			Errors: doc.Errors,
+		}
+	}
+
+	return je
+}
+
+// Iterator iterates over the models of a paginated list.
+type Iterator struct {
+	client *Client
+	model  coal.Model
+	req    jsonapi.Request
+	doc    *jsonapi.Document
+	models []coal.Model
+	index  int
+	done   bool
+	error  error
+}
+
+// Next will advance to the next model and load the next page if required. If
+// it returns false the iteration must be stopped due to the list being
+// exhausted or an error.
+func (i *Iterator) Next() bool {
+	// check error
+	if i.error != nil {
+		return false
+	}
+
+	// load pages until a model is available
+	for i.index+1 >= len(i.models) {
+		// check if done
+		if i.done {
+			return false
+		}
+
+		// load page
+		i.error = i.load()
+		if i.error != nil {
+			return false
+		}
+	}
+
+	// increment
+	i.index++
+
+	return true
+}
+
+// Model returns the current model.
+func (i *Iterator) Model() coal.Model {
+	return i.models[i.index]
+}
+
+// Document returns the document of the current page.
+func (i *Iterator) Document() *jsonapi.Document {
+	return i.doc
+}
+
+// Error returns the first error encountered during iteration. It should always
+// be checked when done to ensure there have been no errors.
+func (i *Iterator) Error() error {
+	return i.error
+}
+
+func (i *Iterator) load() error {
+	// list models
+	models, doc, err := i.client.List(i.model, i.req)
+	if err != nil {
+		return err
+	}
+
+	// set page
+	i.doc = doc
+	i.models = models
+	i.index = -1
+
+	// stop if there is no next page
+	if len(models) == 0 || doc.Links == nil || doc.Links.Next == "" || doc.Links.Next == jsonapi.NullLink {
+		i.done = true
+		return nil
+	}
+
+	// parse next link
+	link, err := url.Parse(string(doc.Links.Next))
+	if err != nil {
+		return err
+	}
+
+	// apply page parameters
+	query := link.Query()
+	for key, value := range map[string]*int64{
+		"page[number]": &i.req.PageNumber,
+		"page[size]":   &i.req.PageSize,
+		"page[offset]": &i.req.PageOffset,
+		"page[limit]":  &i.req.PageLimit,
+	} {
+		*value = 0
+		if query.Has(key) {
+			*value, err = strconv.ParseInt(query.Get(key), 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	i.req.PageBefore = query.Get("page[before]")
+	i.req.PageAfter = query.Get("page[after]")
+
+	return nil
 }
 
 // ModelClient is model specific client.
@@ -181,11 +596,9 @@ func (c *ModelClient[M]) List(reqs ...jsonapi.Request) ([]M, *jsonapi.Document,
 // Find will find and return the model with the provided ID.
 func (c *ModelClient[M]) Find(id coal.ID, reqs ...jsonapi.Request) (M, *jsonapi.Document, error) {
 	// perform find
-	var zero M
-	model := coal.GetMeta(zero).Make().(M)
-	model.GetBase().DocID = id
-	m, doc, err := c.Client.Find(model, reqs...)
+	m, doc, err := c.Client.Find(c.model(id), reqs...)
 	if err != nil {
+		var zero M
 		return zero, doc, err
 	}
 
@@ -219,13 +632,100 @@ func (c *ModelClient[M]) Update(model M) (M, *jsonapi.Document, error) {
 // Delete will delete the model with the provided ID.
 func (c *ModelClient[M]) Delete(id coal.ID) error {
 	// perform delete
-	var zero M
-	model := coal.GetMeta(zero).Make().(M)
-	model.GetBase().DocID = id
-	err := c.Client.Delete(model)
+	err := c.Client.Delete(c.model(id))
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Iterate will return an iterator that lists the models and follows the
+// "next" links of the returned documents to load all pages.
+func (c *ModelClient[M]) Iterate(reqs ...jsonapi.Request) *ModelIterator[M] {
+	var zero M
+	return &ModelIterator[M]{
+		Iterator: c.Client.Iterate(zero, reqs...),
+	}
+}
+
+// GetRelationship will return the IDs of the resources linked by the specified
+// to-many or has-many relationship of the model with the provided ID.
+func (c *ModelClient[M]) GetRelationship(id coal.ID, rel string, reqs ...jsonapi.Request) ([]coal.ID, *jsonapi.Document, error) {
+	return c.Client.GetRelationship(c.model(id), rel, reqs...)
+}
+
+// SetRelationship will replace the linkage of the specified to-many
+// relationship of the model with the provided ID.
+func (c *ModelClient[M]) SetRelationship(id coal.ID, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.Client.SetRelationship(c.model(id), rel, ids)
+}
+
+// AppendToRelationship will add the provided IDs to the linkage of the
+// specified to-many relationship of the model with the provided ID.
+func (c *ModelClient[M]) AppendToRelationship(id coal.ID, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.Client.AppendToRelationship(c.model(id), rel, ids)
+}
+
+// RemoveFromRelationship will remove the provided IDs from the linkage of the
+// specified to-many relationship of the model with the provided ID.
+func (c *ModelClient[M]) RemoveFromRelationship(id coal.ID, rel string, ids []coal.ID) ([]coal.ID, *jsonapi.Document, error) {
+	return c.Client.RemoveFromRelationship(c.model(id), rel, ids)
+}
+
+// CollectionAction will call the specified collection action.
+func (c *ModelClient[M]) CollectionAction(method, name string, in, out interface{}) error {
+	var zero M
+	return c.Client.CollectionAction(zero, method, name, in, out)
+}
+
+// ResourceAction will call the specified resource action of the model with the
+// provided ID.
+func (c *ModelClient[M]) ResourceAction(id coal.ID, method, name string, in, out interface{}) error {
+	return c.Client.ResourceAction(c.model(id), method, name, in, out)
+}
+
+func (c *ModelClient[M]) model(id coal.ID) M {
+	var zero M
+	model := coal.GetMeta(zero).Make().(M)
+	model.GetBase().DocID = id
+	return model
+}
+
+// ModelIterator is a model specific iterator.
+type ModelIterator[M coal.Model] struct {
+	*Iterator
+}
+
+// Model returns the current model.
+func (i *ModelIterator[M]) Model() M {
+	return i.Iterator.Model().(M)
+}
+
+// Included will return the included models of the specified type from the
+// provided document.
+func Included[M coal.Model](doc *jsonapi.Document) ([]M, error) {
+	// get meta
+	var zero M
+	meta := coal.GetMeta(zero)
+
+	// collect models
+	list := make([]M, 0)
+	for _, resource := range doc.Included {
+		// check type
+		if resource.Type != meta.PluralName {
+			continue
+		}
+
+		// assign resource
+		model := meta.Make().(M)
+		err := AssignResource(model, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, model)
+	}
+
+	return list, nil
+}
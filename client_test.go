@@ -1,7 +1,10 @@
 package fire
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"testing"
 
 	"github.com/256dpi/jsonapi/v2"
@@ -10,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
 )
 
 func TestClient(t *testing.T) {
@@ -155,3 +159,214 @@ func TestModelClient(t *testing.T) {
 		assert.NotNil(t, doc)
 	})
 }
+
+func TestClientIterate(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Store: tester.Store,
+			Model: &testModel{},
+		})
+
+		client := ClientFor[*testModel](NewClient(jsonapi.NewClientWithClient(jsonapi.ClientConfig{}, &http.Client{
+			Transport: serve.Local(group.Endpoint("")),
+		})))
+
+		var ids []coal.ID
+		for i := 0; i < 5; i++ {
+			model, _, err := client.Create(&testModel{
+				String: strconv.Itoa(i),
+			})
+			assert.NoError(t, err)
+			ids = append(ids, model.ID())
+		}
+
+		for _, pagination := range []string{"", "cursor"} {
+			iter := client.Iterate(jsonapi.Request{
+				PageSize:   2,
+				Pagination: pagination,
+			})
+
+			var list []coal.ID
+			for iter.Next() {
+				list = append(list, iter.Model().ID())
+				assert.NotNil(t, iter.Document())
+			}
+			assert.NoError(t, iter.Error())
+			assert.Equal(t, ids, list, pagination)
+		}
+
+		iter := client.Iterate(jsonapi.Request{
+			PageSize:   2,
+			PageNumber: 4,
+		})
+		assert.False(t, iter.Next())
+		assert.NoError(t, iter.Error())
+
+		iter = client.Iterate(jsonapi.Request{
+			Sorting: []string{"foo"},
+		})
+		assert.False(t, iter.Next())
+		assert.Error(t, iter.Error())
+	})
+}
+
+func TestClientRelationships(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Store: tester.Store,
+			Model: &testModel{},
+		})
+
+		client := ClientFor[*testModel](NewClient(jsonapi.NewClientWithClient(jsonapi.ClientConfig{}, &http.Client{
+			Transport: serve.Local(group.Endpoint("")),
+		})))
+
+		var ids []coal.ID
+		for i := 0; i < 3; i++ {
+			model, _, err := client.Create(&testModel{
+				String: strconv.Itoa(i),
+			})
+			assert.NoError(t, err)
+			ids = append(ids, model.ID())
+		}
+
+		list, _, err := client.GetRelationship(ids[0], "many")
+		assert.NoError(t, err)
+		assert.Equal(t, []coal.ID{}, list)
+
+		list, _, err = client.SetRelationship(ids[0], "many", ids[1:])
+		assert.NoError(t, err)
+		assert.Equal(t, ids[1:], list)
+
+		list, _, err = client.AppendToRelationship(ids[0], "many", ids[:1])
+		assert.NoError(t, err)
+		assert.Equal(t, []coal.ID{ids[1], ids[2], ids[0]}, list)
+
+		list, _, err = client.RemoveFromRelationship(ids[0], "many", ids[1:2])
+		assert.NoError(t, err)
+		assert.Equal(t, []coal.ID{ids[2], ids[0]}, list)
+
+		list, doc, err := client.GetRelationship(ids[0], "many", jsonapi.Request{
+			PageNumber: 1,
+			PageSize:   1,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.NotEmpty(t, doc.Links.Next)
+
+		list, _, err = client.GetRelationship(ids[0], "one")
+		assert.Error(t, err)
+		assert.Nil(t, list)
+
+		_, doc, err = client.Find(ids[0], jsonapi.Request{
+			Include: []string{"many"},
+		})
+		assert.NoError(t, err)
+
+		included, err := Included[*testModel](doc)
+		assert.NoError(t, err)
+		assert.Len(t, included, 1)
+		assert.Equal(t, ids[2], included[0].ID())
+		assert.Equal(t, "2", included[0].String)
+	})
+}
+
+func TestClientActions(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Store: tester.Store,
+			Model: &testModel{},
+			Validators: L{
+				C("TestClientActions", Validator, All(), func(ctx *Context) error {
+					if ctx.Model.(*testModel).String == "invalid" {
+						return jsonapi.BadRequestPointer("invalid string", "/data/attributes/string")
+					}
+					return nil
+				}),
+			},
+			CollectionActions: M{
+				"echo": A("echo", []string{"POST"}, 0, 0, func(ctx *Context) error {
+					var in map[string]string
+					err := json.NewDecoder(ctx.HTTPRequest.Body).Decode(&in)
+					if err != nil {
+						return err
+					}
+					if in["fail"] != "" {
+						return jsonapi.BadRequestParam(in["fail"], "fail")
+					}
+					return ctx.Respond(stick.Map{"echo": in["value"]})
+				}),
+				"errors": A("errors", []string{"POST"}, 0, 0, func(ctx *Context) error {
+					return jsonapi.WriteErrorList(ctx.ResponseWriter,
+						jsonapi.BadRequestPointer("invalid string", "/data/attributes/string"),
+						jsonapi.BadRequestParam("invalid param", "param"),
+					)
+				}),
+			},
+			ResourceActions: M{
+				"string": A("string", []string{"GET"}, 0, 0, func(ctx *Context) error {
+					return ctx.Respond(stick.Map{"string": ctx.Model.(*testModel).String})
+				}),
+			},
+		})
+
+		client := ClientFor[*testModel](NewClientWithConfig(jsonapi.ClientConfig{
+			BaseURI: "http://localhost/",
+		}, &http.Client{
+			Transport: serve.Local(group.Endpoint("")),
+		}))
+
+		var out struct {
+			Echo string `json:"echo"`
+		}
+		err := client.CollectionAction("POST", "echo", map[string]string{"value": "Hello"}, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", out.Echo)
+
+		err = client.CollectionAction("POST", "echo", map[string]string{"fail": "failed"}, nil)
+		assert.Error(t, err)
+		jsonapiErr, ok := err.(*jsonapi.Error)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, jsonapiErr.Status)
+		assert.Equal(t, "failed", jsonapiErr.Detail)
+		assert.Equal(t, "fail", jsonapiErr.Source.Parameter)
+
+		err = client.CollectionAction("POST", "errors", nil, nil)
+		assert.Error(t, err)
+		var clientErr *ClientError
+		assert.True(t, errors.As(err, &clientErr))
+		assert.Equal(t, http.StatusBadRequest, clientErr.Status())
+		assert.Equal(t, "invalid param", clientErr.Parameter("param").Detail)
+		assert.Equal(t, "invalid string", clientErr.Pointer("/data/attributes/string").Detail)
+		assert.Nil(t, clientErr.Pointer("/data/attributes/bool"))
+		assert.True(t, errors.As(err, &jsonapiErr))
+		assert.Equal(t, "invalid string", jsonapiErr.Detail)
+
+		model, _, err := client.Create(&testModel{
+			String: "string",
+		})
+		assert.NoError(t, err)
+
+		var res map[string]string
+		err = client.ResourceAction(model.ID(), "GET", "string", nil, &res)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"string": "string"}, res)
+
+		err = client.ResourceAction(coal.New(), "GET", "string", nil, &res)
+		assert.True(t, ErrResourceNotFound.Is(err))
+
+		_, _, err = client.Create(&testModel{
+			String: "invalid",
+		})
+		jsonapiErr, ok = err.(*jsonapi.Error)
+		assert.True(t, ok)
+		assert.Equal(t, "invalid string", jsonapiErr.Detail)
+		assert.Equal(t, "/data/attributes/string", jsonapiErr.Source.Pointer)
+
+		err = ClientFor[*testModel](NewClient(jsonapi.NewClient(jsonapi.ClientConfig{}))).CollectionAction("POST", "echo", nil, nil)
+		assert.Error(t, err)
+	})
+}
@@ -150,6 +150,22 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 	ctx, span := xo.Trace(ctx, "coal/Manager.FindFirst")
 	defer span.End()
 
+	return m.findFirst(ctx, model, filter, nil, sort, skip, lock, flags...)
+}
+
+// FindFirstPartial works like FindFirst but will only load the specified
+// fields. The base fields are always loaded.
+//
+// Note: Partially loaded models are not validated.
+func (m *Manager) FindFirstPartial(ctx context.Context, model Model, filter bson.M, fields, sort []string, skip int64, lock bool, flags ...Flags) (bool, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.FindFirstPartial")
+	defer span.End()
+
+	return m.findFirst(ctx, model, filter, fields, sort, skip, lock, flags...)
+}
+
+func (m *Manager) findFirst(ctx context.Context, model Model, filter bson.M, fields, sort []string, skip int64, lock bool, flags ...Flags) (bool, error) {
 	// check lock
 	if lock && !HasTransaction(ctx) {
 		return false, ErrTransactionRequired.Wrap()
@@ -185,6 +201,15 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		}
 	}
 
+	// translate projection
+	var projectionDoc bson.M
+	if fields != nil {
+		projectionDoc, err = m.projection(fields)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	// find document
 	if lock {
 		// prepare options
@@ -192,6 +217,9 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		if sortDoc != nil {
 			opts.SetSort(sortDoc)
 		}
+		if projectionDoc != nil {
+			opts.SetProjection(projectionDoc)
+		}
 
 		// find and update
 		err = m.coll.FindOneAndUpdate(ctx, filterDoc, incrementLock, returnAfterUpdate, opts).Decode(model)
@@ -204,6 +232,9 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		if skip > 0 {
 			opts.SetSkip(skip)
 		}
+		if projectionDoc != nil {
+			opts.SetProjection(projectionDoc)
+		}
 
 		// find
 		err = m.coll.FindOne(ctx, filterDoc, opts).Decode(model)
@@ -214,8 +245,8 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		return false, err
 	}
 
-	// validate model
-	if !Merge(flags).Has(NoValidation) {
+	// validate model if fully loaded
+	if fields == nil && !Merge(flags).Has(NoValidation) {
 		err = model.Validate()
 		if err != nil {
 			return false, xo.W(err)
@@ -241,6 +272,22 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	ctx, span := xo.Trace(ctx, "coal/Manager.FindAll")
 	defer span.End()
 
+	return m.findAll(ctx, list, filter, nil, sort, skip, limit, lock, flags...)
+}
+
+// FindAllPartial works like FindAll but will only load the specified fields.
+// The base fields are always loaded.
+//
+// Note: Partially loaded models are not validated.
+func (m *Manager) FindAllPartial(ctx context.Context, list interface{}, filter bson.M, fields, sort []string, skip, limit int64, lock bool, flags ...Flags) error {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.FindAllPartial")
+	defer span.End()
+
+	return m.findAll(ctx, list, filter, fields, sort, skip, limit, lock, flags...)
+}
+
+func (m *Manager) findAll(ctx context.Context, list interface{}, filter bson.M, fields, sort []string, skip, limit int64, lock bool, flags ...Flags) error {
 	// check list
 	if list == nil {
 		return xo.F("missing list")
@@ -291,12 +338,24 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 		opts.SetLimit(limit)
 	}
 
+	// set projection
+	var projectionDoc bson.M
+	if fields != nil {
+		projectionDoc, err = m.projection(fields)
+		if err != nil {
+			return err
+		}
+		opts.SetProjection(projectionDoc)
+	}
+
 	// handle text score sort
 	if Merge(flags).Has(TextScoreSort) {
-		// set projection
-		opts.SetProjection(bson.M{
-			"_sc": metaTextScore,
-		})
+		// set or extend projection
+		if projectionDoc == nil {
+			projectionDoc = bson.M{}
+		}
+		projectionDoc["_sc"] = metaTextScore
+		opts.SetProjection(projectionDoc)
 
 		// prepend score sort
 		rawSort, _ := opts.Sort.(bson.D)
@@ -329,8 +388,8 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	// get models
 	models := Slice(list)
 
-	// validate models
-	if !Merge(flags).Has(NoValidation) {
+	// validate models if fully loaded
+	if fields == nil && !Merge(flags).Has(NoValidation) {
 		for _, model := range models {
 			err = model.Validate()
 			if err != nil {
@@ -347,6 +406,27 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	return nil
 }
 
+func (m *Manager) projection(fields []string) (bson.M, error) {
+	// prepare projection with base fields
+	projection := bson.M{
+		"_id": 1,
+		"_lk": 1,
+		"_tk": 1,
+		"_tg": 1,
+	}
+
+	// add fields
+	for _, field := range fields {
+		key, err := m.trans.Field(field)
+		if err != nil {
+			return nil, err
+		}
+		projection[key] = 1
+	}
+
+	return projection, nil
+}
+
 // FindEach will find all documents that match the specified filter. Lock can be
 // set to true to force a write lock on the documents and prevent a stale read
 // during a transaction.
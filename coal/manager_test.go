@@ -139,6 +139,52 @@ func TestManagerFindFirst(t *testing.T) {
 	})
 }
 
+func TestManagerFindFirstPartial(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		post := *tester.Insert(&postModel{
+			Title:     "Hello World!",
+			Published: true,
+			TextBody:  "Hello",
+		}).(*postModel)
+
+		m := tester.Store.M(&postModel{})
+
+		// partial
+		var partial postModel
+		found, err := m.FindFirstPartial(nil, &partial, bson.M{
+			"Title": "Hello World!",
+		}, []string{"Title"}, nil, 0, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, postModel{
+			Base:  post.Base,
+			Title: "Hello World!",
+		}, partial)
+
+		// invalid field
+		found, err = m.FindFirstPartial(nil, &partial, nil, []string{"Foo"}, nil, 0, false)
+		assert.Error(t, err)
+		assert.False(t, found)
+		assert.Equal(t, `unknown field "Foo"`, err.Error())
+
+		// lock
+		_ = tester.Store.T(nil, false, func(ctx context.Context) error {
+			post.Lock++
+			var partial postModel
+			found, err := m.FindFirstPartial(ctx, &partial, bson.M{
+				"Title": "Hello World!",
+			}, []string{"Published"}, nil, 0, true)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, postModel{
+				Base:      post.Base,
+				Published: true,
+			}, partial)
+			return nil
+		})
+	})
+}
+
 func TestManagerFindAll(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		post1 := *tester.Insert(&postModel{
@@ -240,6 +286,44 @@ func TestManagerFindAll(t *testing.T) {
 	})
 }
 
+func TestManagerFindAllPartial(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		post1 := *tester.Insert(&postModel{
+			Title:    "Hello World!",
+			TextBody: "Foo",
+		}).(*postModel)
+
+		post2 := *tester.Insert(&postModel{
+			Title:    "Hello Space!",
+			TextBody: "Bar",
+		}).(*postModel)
+
+		m := tester.Store.M(&postModel{})
+
+		// partial
+		var list []postModel
+		err := m.FindAllPartial(nil, &list, nil, []string{"TextBody"}, []string{"Title"}, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []postModel{
+			{Base: post2.Base, TextBody: "Bar"},
+			{Base: post1.Base, TextBody: "Foo"},
+		}, list)
+
+		// base only
+		list = nil
+		err = m.FindAllPartial(nil, &list, nil, []string{}, nil, 0, 1, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []postModel{
+			{Base: post1.Base},
+		}, list)
+
+		// invalid field
+		err = m.FindAllPartial(nil, &list, nil, []string{"Foo"}, nil, 0, 0, false, NoTransaction)
+		assert.Error(t, err)
+		assert.Equal(t, `unknown field "Foo"`, err.Error())
+	})
+}
+
 func TestManagerFindAllTextScoreSort(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		if tester.Store.Lungo() {
@@ -277,6 +361,19 @@ func TestManagerFindAllTextScoreSort(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, []postModel{post2, post1}, list)
 
+		// sort by score with partial load
+		list = nil
+		err = m.FindAllPartial(nil, &list, bson.M{
+			"$text": bson.M{
+				"$search": "hello spaces",
+			},
+		}, []string{"Title"}, nil, 0, 0, false, NoTransaction|TextScoreSort)
+		assert.NoError(t, err)
+		assert.Equal(t, []postModel{
+			{Base: post2.Base, Title: post2.Title},
+			{Base: post1.Base, Title: post1.Title},
+		}, list)
+
 		// remove index
 		_, err = m.C().Native().Indexes().DropOne(nil, name)
 		assert.NoError(t, err)
@@ -281,10 +281,22 @@ type Context struct {
 	// Usage: Read only
 	Group *Group
 
+	// The API version requested by the client. It is only set if the group
+	// has API versions configured.
+	//
+	// Usage: Read only
+	APIVersion string
+
 	// The current tracer.
 	//
 	// Usage: Read only
 	Tracer *xo.Tracer
+
+	// whether the request document already uses the current API version
+	currentRequest bool
+
+	// the tenant resolved for the request
+	tenant coal.ID
 }
 
 // With will run the provided function with the specified context temporarily
@@ -3,13 +3,18 @@ package fire
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/bits"
 	"net/http"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +32,10 @@ const blankCursor = "*"
 
 var cursorEncoding = base64.URLEncoding.WithPadding(base64.NoPadding)
 
+var idType = reflect.TypeOf(coal.ID{})
+var timeType = reflect.TypeOf(time.Time{})
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
 // Stage defines a controller callback stage.
 type Stage int
 
@@ -66,12 +75,20 @@ func (s Stage) Split() []Stage {
 // expression.
 type FilterHandler func(ctx *Context, values []string) (bson.M, error)
 
+// BatchProperty defines a function that computes the values of a property for
+// multiple models at once. The values should be returned keyed by model ID.
+type BatchProperty func(ctx *Context, models []coal.Model) (map[coal.ID]interface{}, error)
+
 // A Controller provides a JSON API based interface to a model.
 //
 // Database transactions are automatically used for list, find, create, update
 // and delete operations. The created session can be accessed through the
 // context to use it in callbacks.
 //
+// Read responses include a weak entity tag derived from the response in the
+// "ETag" header. Requests with a matching "If-None-Match" header are answered
+// with a "Not Modified" status.
+//
 // Note: A controller must not be modified after being added to a group.
 type Controller struct {
 	// The model that this controller should provide (e.g. &Foo{}).
@@ -93,7 +110,15 @@ type Controller struct {
 	// Filters is a list of fields that are filterable. Only fields that are
 	// exposed and indexed should be made filterable.
 	//
-	// Note: The filter[field] query parameters are used for filtering.
+	// Note: The filter[field] query parameters are used for filtering. The
+	// filter[field][op] query parameters may be used to filter using the "eq",
+	// "ne", "gt", "gte", "lt", "lte", "in", "nin" and "exists" operators. The
+	// values are parsed according to the field type. Filter handlers are not
+	// run for operator filters. Listed to-one relationships may also be
+	// filtered by the attributes of the related resource using the
+	// filter[rel.field] query parameters. The related resources are looked up
+	// using the related controller, which applies its own filters and
+	// authorizers.
 	Filters []string
 
 	// FilterHandlers is a map of custom filter handlers that convert filter
@@ -104,14 +129,97 @@ type Controller struct {
 	// Sorters is a list of fields that are sortable. Only fields that are
 	// exposed and indexed should be made sortable.
 	//
-	// Note: The "sort" query parameters is used for sorting.
+	// Note: The "sort" query parameters is used for sorting. Listed to-one
+	// relationships may also be sorted by the attributes of the related
+	// resource using "rel.field" sorters that must precede other sorters.
+	// Since these sorters are applied in memory, they do not support cursor
+	// pagination and should only be used with small collections.
 	Sorters []string
 
+	// Aggregations is a list of fields that may be used to group and aggregate
+	// resources using the builtin "stats" collection action. The action
+	// supports the same filters and search as the list operation and runs the
+	// list authorizers to restrict the aggregated documents. Only readable
+	// attributes and to-one relationships may be listed.
+	//
+	// Note: The "group-by" query parameter selects the comma separated fields
+	// to group by and the "metrics" query parameter the comma separated
+	// "count", "sum(field)", "avg(field)", "min(field)" and "max(field)"
+	// metrics to compute. Aggregations are not supported by lungo stores.
+	Aggregations []string
+
+	// RelationshipCounts is a list of to-many and has-many relationships for
+	// which the number of related resources is added as "count" meta to the
+	// relationship objects of returned resources. This allows clients to show
+	// counts without loading the related resources. The counts exclude soft
+	// deleted resources and apply the relationship filters. The references of
+	// counted has-many relationships are only loaded if the relationship is
+	// requested or included.
+	//
+	// Note: The relationship and related resource endpoints of to-many and
+	// has-many relationships support offset and cursor pagination using the
+	// "page[...]" query parameters. Paginated relationships are listed using
+	// the related controller, which applies its own filters and authorizers.
+	RelationshipCounts []string
+
+	// Cache may be set to cache the responses of list and find operations.
+	// Responses are cached by the request, the selector and filters set by
+	// authorizers and the readable fields and properties. Cached responses are
+	// invalidated using change streams on the model and the models of readable
+	// has-one, has-many and to-many relationships. Requests that include
+	// related resources and virtual sub-requests are not cached.
+	//
+	// Requests for which authorizers set readable field or property getters
+	// are not cached as the getters may depend on the loaded models. The
+	// cache cannot be combined with verifiers and decorators.
+	//
+	// Note: Notifiers are not run for cached responses. All identity specific
+	// restrictions must therefore be applied by authorizers. Properties and
+	// batch properties must only depend on the model.
+	Cache *Cache
+
+	// Projection can be set to true to only load the fields required to
+	// construct the response of list and find operations from the database.
+	// The projection includes the readable fields, the fields of readable
+	// properties listed in PropertyFields, the sort fields and the fields used
+	// by the soft delete, tenancy, idempotent create and consistent update
+	// mechanisms. The full document is loaded if a readable property has no
+	// listed fields, verifiers are configured or authorizers set readable
+	// field or property getters.
+	//
+	// Note: Decorators and notifiers only see the partially loaded models
+	// during list and find operations. Partially loaded models are not
+	// validated.
+	Projection bool
+
+	// PropertyFields is a mapping of properties and batch properties to the
+	// model fields they depend on. It is used to compute the projection if
+	// Projection is enabled.
+	PropertyFields map[string][]string
+
+	// Transformers are used to convert resources between older API versions
+	// of the group and the current version. Incoming resources are
+	// transformed up to the current version before they are assigned to the
+	// model. Outgoing resources are transformed down to the requested version
+	// after the response has been constructed. Included resources are
+	// transformed using the transformers of their controllers.
+	//
+	// Note: Cached responses are stored in the current version and
+	// transformed when they are served.
+	Transformers []*Transformer
+
 	// Properties is a mapping of model properties to attribute keys. These
 	// properties are called and their result set as attributes before returning
 	// the response.
 	Properties map[string]string
 
+	// BatchProperties is a mapping of attribute keys to batch properties.
+	// Unlike regular properties, a batch property is called once per response
+	// with all models that have the property readable. This allows properties
+	// to load additional data without issuing a query per model. The attribute
+	// key is also used as the property name for readable properties.
+	BatchProperties map[string]BatchProperty
+
 	// Authorizers authorize the requested operation on the requested resource
 	// and are run before any models are loaded from the store. Returned "safe"
 	// errors will cause the abortion of the request with an unauthorized status.
@@ -182,6 +290,15 @@ type Controller struct {
 	// offset based pagination.
 	ListLimit int64
 
+	// RelatedLimit defines the maximum number of documents that are loaded to
+	// filter or sort by related fields. Related sorting loads all matching
+	// documents to sort them in memory and related filtering loads the IDs of
+	// all matching related documents. Requests that exceed the limit are
+	// rejected with a bad request status.
+	//
+	// Default: 1000.
+	RelatedLimit int64
+
 	// CursorPagination can be set to require cursor based pagination. It can be
 	// enforced by also setting ListLimit. Cursor pagination will use the sort
 	// fields (including _id as the tiebreaker) to return a cursor that can be
@@ -236,7 +353,11 @@ type Controller struct {
 	// send it with the update in the defined update token field. The controller
 	// will then check if the stored document still has the same token. The
 	// controller will determine the token field from the provided model using
-	// the "fire-consistent-update" flag.
+	// the "fire-consistent-update" flag. The token is also returned as the
+	// "ETag" header of create and update responses if the field is readable
+	// and may be provided using the "If-Match" header instead of the document
+	// field. Weak entity tags never match the "If-Match" header. Read
+	// responses always use a weak digest of the response as entity tag.
 	ConsistentUpdate bool
 
 	// SoftDelete can be set to true to enable the soft delete mechanism. If
@@ -247,6 +368,67 @@ type Controller struct {
 	// a TTL index to delete the documents automatically after some timeout.
 	SoftDelete bool
 
+	// Bulk can be set to true to enable bulk requests. Documents with multiple
+	// resources may then be sent to the collection endpoint using POST, PATCH
+	// and DELETE requests to create, update and delete multiple resources at
+	// once. Every resource is processed as a separate request within a single
+	// transaction. If a resource fails, the transaction is aborted and the
+	// error is returned with a pointer to the failed resource.
+	Bulk bool
+
+	// Trash can be set to true to provide access to soft deleted documents if
+	// SoftDelete is enabled. Soft deleted documents may then be listed using
+	// the "filter[deleted]=true" and "filter[deleted]=only" query parameters,
+	// restored using the "restore" resource action and permanently deleted
+	// using the "purge" resource action. Validators matching the
+	// ResourceAction operation are run before restoring or purging a
+	// document. Restored documents are notified like regular updates using
+	// the Update operation. Purged documents are versioned and notified like
+	// regular deletions using the Delete operation.
+	Trash bool
+
+	// TrashAccess is called after running the authorizers to check whether
+	// the caller may access the trash. Requests using the "deleted" filter are
+	// rejected with an "Unauthorized" status and soft deleted documents are
+	// hidden from the "restore" and "purge" resource actions if it returns
+	// false. It is required if Trash is enabled.
+	TrashAccess func(ctx *Context) bool
+
+	// Versioning can be set to true to enable resource versioning. If enabled,
+	// the controller will store the previous revision of a document in the
+	// "<collection>_versions" collection on every update and delete. The stored
+	// revisions are available using the "versions" resource action, which
+	// supports page based pagination, and "/<type>/<id>/versions/<number>"
+	// requests. Revisions are limited to the fields readable by a find
+	// operation. The "restore" resource action re-applies the writable fields
+	// of a previous revision using a regular update when requested with a JSON
+	// body like {"version": 2}. The indexes registered with the Version model
+	// can be ensured using EnsureVersionIndexes.
+	Versioning bool
+
+	// IndexGuard can be set to check list queries against the indexes
+	// registered with the model using coal.AddIndex. Queries that are not
+	// supported by a non-partial index or the "_id" field are either reported
+	// using the group reporter or rejected with a bad request error. An index
+	// supports a query if its prefix covers all client filters and the
+	// sorting follows the prefix. The soft delete and tenant fields may be
+	// part of the prefix but do not make an index supporting on their own.
+	// Search queries are not checked.
+	IndexGuard GuardMode
+
+	// Tenancy can be set to enable multi-tenancy. The resolver is called after
+	// the authorizers have been run and should return the tenant of the
+	// request. The controller will determine the tenant field from the
+	// provided model using the "fire-tenant" flag. All queries, including
+	// the preloading of relationships, are then limited to documents of the
+	// returned tenant. The tenant field is set on created documents and may
+	// not be changed during updates. Referenced resources of related
+	// controllers that also use tenancy must belong to the same tenant. The
+	// tenant is resolved once per request and reused by all hooks and
+	// sub-requests. Returned "safe" errors will cause the abortion of the
+	// request with an unauthorized status.
+	Tenancy func(ctx *Context) (coal.ID, error)
+
 	parser     jsonapi.Parser
 	meta       *coal.Meta
 	properties map[string]func(coal.Model) (interface{}, error)
@@ -267,6 +449,9 @@ func (c *Controller) prepare() {
 	// cache meta
 	c.meta = coal.GetMeta(c.Model)
 
+	// add builtin actions
+	c.addBuiltinActions()
+
 	// add collection actions
 	for name, action := range c.CollectionActions {
 		// check collision
@@ -309,6 +494,11 @@ func (c *Controller) prepare() {
 		c.parser.ResourceActions[name] = action.Methods
 	}
 
+	// ensure related limit
+	if c.RelatedLimit == 0 {
+		c.RelatedLimit = 1000
+	}
+
 	// ensure document limit
 	if c.DocumentLimit == 0 {
 		c.DocumentLimit = serve.MustByteSize("8M")
@@ -330,6 +520,29 @@ func (c *Controller) prepare() {
 		}
 	}
 
+	// check trash
+	if c.Trash && !c.SoftDelete {
+		panic(fmt.Sprintf(`fire: trash requires soft delete for model "%s"`, c.meta.Name))
+	}
+
+	// check trash access
+	if c.Trash && c.TrashAccess == nil {
+		panic(fmt.Sprintf(`fire: trash requires trash access for model "%s"`, c.meta.Name))
+	}
+
+	// check cache
+	if c.Cache != nil && (len(c.Verifiers) > 0 || len(c.Decorators) > 0) {
+		panic(fmt.Sprintf(`fire: cache cannot be combined with verifiers or decorators for model "%s"`, c.meta.Name))
+	}
+
+	// check tenant field
+	if c.Tenancy != nil {
+		fieldName := coal.L(c.Model, "fire-tenant", true)
+		if c.meta.Fields[fieldName].Type != idType {
+			panic(fmt.Sprintf(`fire: tenant field "%s" for model "%s" is not of type "coal.ID"`, fieldName, c.meta.Name))
+		}
+	}
+
 	// check idempotent create field
 	if c.IdempotentCreate {
 		fieldName := coal.L(c.Model, "fire-idempotent-create", true)
@@ -346,6 +559,34 @@ func (c *Controller) prepare() {
 		}
 	}
 
+	// check aggregation fields
+	for _, name := range c.Aggregations {
+		field := c.meta.Fields[name]
+		if field == nil || (field.JSONKey == "" && !field.ToOne) {
+			panic(fmt.Sprintf(`fire: invalid aggregation field "%s"`, name))
+		}
+	}
+
+	// check property fields
+	for name, fields := range c.PropertyFields {
+		if c.Properties[name] == "" && c.BatchProperties[name] == nil {
+			panic(fmt.Sprintf(`fire: invalid property "%s"`, name))
+		}
+		for _, field := range fields {
+			if c.meta.Fields[field] == nil || c.meta.Fields[field].BSONKey == "" {
+				panic(fmt.Sprintf(`fire: invalid property field "%s"`, field))
+			}
+		}
+	}
+
+	// check relationship count fields
+	for _, name := range c.RelationshipCounts {
+		field := c.meta.Fields[name]
+		if field == nil || (!field.ToMany && !field.HasMany) {
+			panic(fmt.Sprintf(`fire: invalid relationship count field "%s"`, name))
+		}
+	}
+
 	// check filter handlers
 	for name := range c.FilterHandlers {
 		if !stick.Contains(c.Filters, name) {
@@ -358,6 +599,74 @@ func (c *Controller) prepare() {
 	for name := range c.Properties {
 		c.properties[name] = P(c.Model, name)
 	}
+
+	// check property keys
+	keys := map[string]bool{}
+	for _, key := range c.Properties {
+		if c.meta.Attributes[key] != nil || c.meta.Relationships[key] != nil || keys[key] {
+			panic(fmt.Sprintf(`fire: property "%s" collides with existing attribute`, key))
+		}
+		keys[key] = true
+	}
+
+	// check batch property keys
+	for key := range c.BatchProperties {
+		if c.meta.Attributes[key] != nil || c.meta.Relationships[key] != nil || keys[key] || c.Properties[key] != "" {
+			panic(fmt.Sprintf(`fire: batch property "%s" collides with existing attribute`, key))
+		}
+	}
+}
+
+func (c *Controller) addBuiltinActions() {
+	// collect actions
+	actions := M{}
+	if c.Versioning {
+		actions["versions"] = A("fire/Controller.versions", []string{"GET"}, 0, 0, c.listVersions)
+	}
+	if c.Trash {
+		actions["purge"] = A("fire/Controller.purge", []string{"DELETE"}, 0, 0, c.purgeResource)
+	}
+	if c.Versioning || c.Trash {
+		actions["restore"] = A("fire/Controller.restore", []string{"POST"}, 0, 0, c.restoreResource)
+	}
+
+	// add stats action
+	if len(c.Aggregations) > 0 {
+		// copy map to not modify shared maps
+		actions := M{}
+		for name, action := range c.CollectionActions {
+			actions[name] = action
+		}
+		c.CollectionActions = actions
+
+		// check collision
+		if c.CollectionActions["stats"] != nil {
+			panic(`fire: collection action "stats" is reserved`)
+		}
+
+		// add action
+		c.CollectionActions["stats"] = A("fire/Controller.stats", []string{"GET"}, 0, 0, c.computeStats)
+	}
+
+	// copy map to not modify shared maps
+	if len(actions) > 0 {
+		resourceActions := M{}
+		for name, action := range c.ResourceActions {
+			resourceActions[name] = action
+		}
+		c.ResourceActions = resourceActions
+	}
+
+	// add actions
+	for name, action := range actions {
+		// check collision
+		if c.ResourceActions[name] != nil {
+			panic(fmt.Sprintf(`fire: resource action "%s" is reserved`, name))
+		}
+
+		// add action
+		c.ResourceActions[name] = action
+	}
 }
 
 func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write bool) {
@@ -369,6 +678,19 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 	parser := c.parser
 	parser.Prefix = prefix
 
+	// handle bulk requests
+	if c.Bulk && ctx.JSONAPIRequest == nil && ctx.Request == nil {
+		if doc := c.parseBulk(prefix, ctx); doc != nil {
+			c.handleBulk(prefix, ctx, doc)
+			return
+		}
+	}
+
+	// handle version requests
+	if c.Versioning && ctx.JSONAPIRequest == nil {
+		c.parseVersion(prefix, ctx)
+	}
+
 	// parse incoming JSON-API request if not yet present
 	if ctx.JSONAPIRequest == nil {
 		req, err := parser.ParseRequest(ctx.HTTPRequest)
@@ -424,19 +746,14 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 		))
 	}
 
-	// ensure selector
-	if selector == nil {
-		selector = bson.M{}
+	// transform incoming resource if an older API version is requested
+	switch ctx.JSONAPIRequest.Intent {
+	case jsonapi.CreateResource, jsonapi.UpdateResource:
+		c.transformRequest(ctx)
 	}
 
 	// prepare context
-	ctx.Store = c.Store
-	ctx.Selector = selector
-	ctx.Filters = []bson.M{}
-	ctx.ReadableFields = c.initialFields(false, ctx.JSONAPIRequest)
-	ctx.WritableFields = c.initialFields(true, nil)
-	ctx.ReadableProperties = c.initialProperties(ctx.JSONAPIRequest)
-	ctx.RelationshipFilters = map[string][]bson.M{}
+	c.prepareContext(ctx, selector)
 
 	// run operation with transaction if not an action
 	if !ctx.Operation.Action() {
@@ -450,10 +767,40 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 		c.runOperation(ctx)
 	}
 
-	// write response if available
+	// write response or status if available
 	if write && ctx.Response != nil {
+		// transform outgoing resources if an older API version is requested
+		c.transformResponse(ctx, ctx.Response)
+
+		// set entity tag and check precondition
+		if etag := c.entityTag(ctx); etag != "" {
+			ctx.ResponseWriter.Header().Set("ETag", etag)
+			if ctx.Operation.Read() && matchEntityTag(ctx.HTTPRequest.Header.Get("If-None-Match"), etag, false) {
+				ctx.ResponseWriter.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
 		xo.AbortIf(jsonapi.WriteResponse(ctx.ResponseWriter, ctx.ResponseCode, ctx.Response))
+	} else if write && ctx.ResponseCode != 0 {
+		ctx.ResponseWriter.WriteHeader(ctx.ResponseCode)
+	}
+}
+
+func (c *Controller) prepareContext(ctx *Context, selector bson.M) {
+	// ensure selector
+	if selector == nil {
+		selector = bson.M{}
 	}
+
+	// prepare context
+	ctx.Store = c.Store
+	ctx.Selector = selector
+	ctx.Filters = []bson.M{}
+	ctx.ReadableFields = c.initialFields(false, ctx.JSONAPIRequest)
+	ctx.WritableFields = c.initialFields(true, nil)
+	ctx.ReadableProperties = c.initialProperties(ctx.JSONAPIRequest)
+	ctx.RelationshipFilters = map[string][]bson.M{}
 }
 
 func (c *Controller) runOperation(ctx *Context) {
@@ -498,8 +845,17 @@ func (c *Controller) listResources(ctx *Context) {
 	// replace context
 	ctx.Context = ct
 
-	// load models
-	c.loadModels(ctx)
+	// prepare query
+	q := c.prepareQuery(ctx, true)
+
+	// lookup cached response
+	miss, hit := c.lookupCache(ctx)
+	if hit {
+		return
+	}
+
+	// find models
+	c.findModels(ctx, q)
 
 	// run decorators
 	c.runCallbacks(ctx, Decorator, c.Decorators, http.StatusInternalServerError)
@@ -516,8 +872,14 @@ func (c *Controller) listResources(ctx *Context) {
 	}
 	ctx.ResponseCode = http.StatusOK
 
+	// include related resources
+	ctx.Response.Included = c.includeResources(ctx, ctx.Response.Data.Many)
+
 	// run notifiers
 	c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
+
+	// cache response
+	c.storeCache(ctx, miss)
 }
 
 func (c *Controller) findResource(ctx *Context) {
@@ -532,8 +894,17 @@ func (c *Controller) findResource(ctx *Context) {
 	// replace context
 	ctx.Context = ct
 
-	// load model
-	c.loadModel(ctx)
+	// prepare model
+	c.prepareModel(ctx)
+
+	// lookup cached response
+	miss, hit := c.lookupCache(ctx)
+	if hit {
+		return
+	}
+
+	// find model
+	c.findModel(ctx)
 
 	// run decorators
 	c.runCallbacks(ctx, Decorator, c.Decorators, http.StatusInternalServerError)
@@ -552,8 +923,14 @@ func (c *Controller) findResource(ctx *Context) {
 	}
 	ctx.ResponseCode = http.StatusOK
 
+	// include related resources
+	ctx.Response.Included = c.includeResources(ctx, []*jsonapi.Resource{ctx.Response.Data.One})
+
 	// run notifiers
 	c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
+
+	// cache response
+	c.storeCache(ctx, miss)
 }
 
 func (c *Controller) createResource(ctx *Context) {
@@ -596,6 +973,12 @@ func (c *Controller) createResource(ctx *Context) {
 	// assign attributes
 	c.assignData(ctx, ctx.Request.Data.One)
 
+	// set tenant if configured
+	if c.Tenancy != nil {
+		tenantField := coal.L(c.Model, "fire-tenant", true)
+		stick.MustSet(ctx.Model, tenantField, c.resolveTenant(ctx))
+	}
+
 	// run modifiers
 	c.runCallbacks(ctx, Modifier, c.Modifiers, http.StatusBadRequest)
 
@@ -610,6 +993,11 @@ func (c *Controller) createResource(ctx *Context) {
 	// run validators
 	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
 
+	// verify tenancy if configured
+	if c.Tenancy != nil {
+		c.verifyTenancy(ctx)
+	}
+
 	// set initial update token if consistent update is enabled
 	if c.ConsistentUpdate {
 		consistentUpdateField := coal.L(ctx.Model, "fire-consistent-update", true)
@@ -717,9 +1105,20 @@ func (c *Controller) updateResource(ctx *Context) {
 		stick.MustSet(ctx.Model, consistentUpdateField, "")
 	}
 
+	// check precondition
+	preconditioned := c.checkPrecondition(ctx, storedConsistentUpdateToken)
+
 	// assign attributes
 	c.assignData(ctx, ctx.Request.Data.One)
 
+	// use stored consistent update token if missing and preconditioned
+	if preconditioned {
+		consistentUpdateField := coal.L(ctx.Model, "fire-consistent-update", true)
+		if stick.MustGet(ctx.Model, consistentUpdateField).(string) == "" {
+			stick.MustSet(ctx.Model, consistentUpdateField, storedConsistentUpdateToken)
+		}
+	}
+
 	// run modifiers
 	c.runCallbacks(ctx, Modifier, c.Modifiers, http.StatusBadRequest)
 
@@ -734,6 +1133,11 @@ func (c *Controller) updateResource(ctx *Context) {
 	// run validators
 	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
 
+	// verify tenancy if configured
+	if c.Tenancy != nil {
+		c.verifyTenancy(ctx)
+	}
+
 	// check if idempotent create token has been changed
 	if c.IdempotentCreate {
 		idempotentCreateField := coal.L(ctx.Model, "fire-idempotent-create", true)
@@ -785,6 +1189,11 @@ func (c *Controller) updateResource(ctx *Context) {
 		}
 	}
 
+	// store previous revision if enabled
+	if c.Versioning {
+		c.storeVersion(ctx, ctx.Original)
+	}
+
 	// run decorators
 	c.runCallbacks(ctx, Decorator, c.Decorators, http.StatusInternalServerError)
 
@@ -821,6 +1230,12 @@ func (c *Controller) deleteResource(ctx *Context) {
 	// load model
 	c.loadModel(ctx)
 
+	// check precondition
+	if c.ConsistentUpdate {
+		consistentUpdateField := coal.L(ctx.Model, "fire-consistent-update", true)
+		c.checkPrecondition(ctx, stick.MustGet(ctx.Model, consistentUpdateField).(string))
+	}
+
 	// run modifiers
 	c.runCallbacks(ctx, Modifier, c.Modifiers, http.StatusBadRequest)
 
@@ -863,11 +1278,16 @@ func (c *Controller) deleteResource(ctx *Context) {
 		}
 	}
 
+	// store last revision if enabled
+	if c.Versioning {
+		c.storeVersion(ctx, ctx.Model)
+	}
+
 	// run notifiers
 	c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
 
 	// set status
-	ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+	ctx.ResponseCode = http.StatusNoContent
 }
 
 func (c *Controller) getRelatedResources(ctx *Context) {
@@ -911,7 +1331,9 @@ func (c *Controller) getRelatedResources(ctx *Context) {
 		ResponseWriter: nil,
 		Controller:     rc,
 		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
 		Tracer:         ctx.Tracer,
+		tenant:         ctx.tenant,
 	}
 
 	// copy and prepare request
@@ -1066,6 +1488,18 @@ func (c *Controller) getRelationship(ctx *Context) {
 	// run decorators
 	c.runCallbacks(ctx, Decorator, c.Decorators, http.StatusInternalServerError)
 
+	// handle paginated relationships
+	if (field.ToMany || field.HasMany) && ctx.JSONAPIRequest.PageSize > 0 {
+		// get relationship page
+		ctx.Response = c.relationshipPage(ctx, field)
+		ctx.ResponseCode = http.StatusOK
+
+		// run notifiers
+		c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
+
+		return
+	}
+
 	// preload relationships
 	relationships := c.preloadRelationships(ctx, []coal.Model{ctx.Model})
 
@@ -1080,6 +1514,96 @@ func (c *Controller) getRelationship(ctx *Context) {
 	c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
 }
 
+func (c *Controller) relationshipPage(ctx *Context, field *coal.Field) *jsonapi.Document {
+	// trace
+	ctx.Tracer.Push("fire/Controller.relationshipPage")
+	defer ctx.Tracer.Pop()
+
+	// get related controller
+	rc := c.relatedController(ctx, field)
+
+	// prepare selector
+	var selector bson.M
+	if field.ToMany {
+		selector = bson.M{
+			"_id": bson.M{
+				"$in": stick.MustGet(ctx.Model, field.Name).([]coal.ID),
+			},
+		}
+	} else {
+		// find inverse relationship
+		inverse := rc.meta.Relationships[field.RelInverse]
+		if inverse == nil {
+			xo.Abort(xo.F("no relationship matching the inverse name %s", field.RelInverse))
+		}
+
+		selector = bson.M{
+			inverse.Name: bson.M{
+				"$in": []coal.ID{ctx.Model.ID()},
+			},
+		}
+	}
+
+	// copy and prepare request
+	req := *ctx.JSONAPIRequest
+	req.Intent = jsonapi.ListResources
+	req.ResourceType = field.RelType
+	req.ResourceID = ""
+	req.Relationship = ""
+	req.Include = nil
+
+	// prepare sub context
+	subCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		Parent:         ctx.Model,
+		HTTPRequest:    ctx.HTTPRequest,
+		Controller:     rc,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: &req,
+		tenant:         ctx.tenant,
+	}
+
+	// handle virtual request
+	rc.handle("", subCtx, selector, false)
+
+	// prepare references
+	references := make([]*jsonapi.Resource, 0, len(subCtx.Response.Data.Many))
+	for _, resource := range subCtx.Response.Data.Many {
+		references = append(references, &jsonapi.Resource{
+			Type: resource.Type,
+			ID:   resource.ID,
+		})
+	}
+
+	// prepare related link
+	relatedLink := jsonapi.Request{
+		Prefix:          ctx.JSONAPIRequest.Prefix,
+		ResourceType:    c.meta.PluralName,
+		ResourceID:      ctx.Model.ID().Hex(),
+		RelatedResource: field.RelName,
+	}
+
+	// rewrite links
+	from, to := req.Path(), ctx.JSONAPIRequest.Path()
+	links := subCtx.Response.Links
+	links.Self = jsonapi.Link(strings.Replace(string(links.Self), from, to, 1))
+	links.Related = jsonapi.Link(relatedLink.Self())
+	links.First = jsonapi.Link(strings.Replace(string(links.First), from, to, 1))
+	links.Previous = jsonapi.Link(strings.Replace(string(links.Previous), from, to, 1))
+	links.Next = jsonapi.Link(strings.Replace(string(links.Next), from, to, 1))
+	links.Last = jsonapi.Link(strings.Replace(string(links.Last), from, to, 1))
+
+	return &jsonapi.Document{
+		Links: links,
+		Data: &jsonapi.HybridResource{
+			Many: references,
+		},
+	}
+}
+
 func (c *Controller) setRelationship(ctx *Context) {
 	// trace
 	ctx.Tracer.Push("fire/Controller.setRelationship")
@@ -1128,6 +1652,11 @@ func (c *Controller) setRelationship(ctx *Context) {
 	// run validators
 	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
 
+	// verify tenancy if configured
+	if c.Tenancy != nil {
+		c.verifyTenancy(ctx)
+	}
+
 	// replace model
 	found, err := ctx.Store.M(c.Model).Replace(ctx, ctx.Model, false)
 	if coal.IsDuplicate(err) {
@@ -1233,6 +1762,11 @@ func (c *Controller) appendToRelationship(ctx *Context) {
 	// run validators
 	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
 
+	// verify tenancy if configured
+	if c.Tenancy != nil {
+		c.verifyTenancy(ctx)
+	}
+
 	// replace model
 	found, err := ctx.Store.M(c.Model).Replace(ctx, ctx.Model, false)
 	if coal.IsDuplicate(err) {
@@ -1345,6 +1879,11 @@ func (c *Controller) removeFromRelationship(ctx *Context) {
 	// run validators
 	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
 
+	// verify tenancy if configured
+	if c.Tenancy != nil {
+		c.verifyTenancy(ctx)
+	}
+
 	// replace model
 	found, err := ctx.Store.M(c.Model).Replace(ctx, ctx.Model, false)
 	if coal.IsDuplicate(err) {
@@ -1474,13 +2013,18 @@ func (c *Controller) initialFields(write bool, r *jsonapi.Request) []string {
 
 func (c *Controller) initialProperties(r *jsonapi.Request) []string {
 	// prepare list
-	list := make([]string, 0, len(c.Properties))
+	list := make([]string, 0, len(c.Properties)+len(c.BatchProperties))
 
 	// add properties
 	for name := range c.Properties {
 		list = append(list, name)
 	}
 
+	// add batch properties
+	for key := range c.BatchProperties {
+		list = append(list, key)
+	}
+
 	// check if a field whitelist has been provided
 	if r != nil && len(r.Fields[c.meta.PluralName]) > 0 {
 		// convert requested fields list
@@ -1498,6 +2042,11 @@ func (c *Controller) initialProperties(r *jsonapi.Request) []string {
 			if found {
 				requested = append(requested, name)
 			}
+
+			// add batch property
+			if c.BatchProperties[field] != nil {
+				requested = append(requested, field)
+			}
 		}
 
 		// whitelist requested fields
@@ -1515,11 +2064,21 @@ func (c *Controller) loadModel(ctx *Context) {
 	ctx.Tracer.Push("fire/Controller.loadModel")
 	defer ctx.Tracer.Pop()
 
+	// prepare and find model
+	c.prepareModel(ctx)
+	c.findModel(ctx)
+}
+
+func (c *Controller) prepareModel(ctx *Context) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.prepareModel")
+	defer ctx.Tracer.Pop()
+
 	// set selector query (id has been validated earlier)
 	ctx.Selector["_id"] = coal.MustFromHex(ctx.JSONAPIRequest.ResourceID)
 
-	// filter out deleted documents if configured
-	if c.SoftDelete {
+	// filter out deleted documents if configured and not accessing the trash
+	if c.SoftDelete && !c.trashAction(ctx) {
 		// get soft delete field
 		softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
 
@@ -1530,12 +2089,40 @@ func (c *Controller) loadModel(ctx *Context) {
 	// run authorizers
 	c.runCallbacks(ctx, Authorizer, c.Authorizers, http.StatusUnauthorized)
 
+	// hide deleted documents if the trash is not accessible
+	if c.SoftDelete && c.trashAction(ctx) && !c.TrashAccess(ctx) {
+		ctx.Selector[coal.L(c.Model, "fire-soft-delete", true)] = nil
+	}
+
+	// limit to tenant if configured
+	if c.Tenancy != nil {
+		c.applyTenancy(ctx)
+	}
+}
+
+func (c *Controller) findModel(ctx *Context) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.findModel")
+	defer ctx.Tracer.Pop()
+
 	// lock document if a write operation is expected
 	lock := ctx.Operation.Write()
 
+	// get projection for find operations
+	var fields []string
+	if ctx.Operation == Find {
+		fields = c.projectedFields(ctx, nil, nil)
+	}
+
 	// find model
+	var found bool
+	var err error
 	model := c.meta.Make()
-	found, err := ctx.Store.M(c.Model).FindFirst(ctx, model, ctx.Query(), nil, 0, lock)
+	if fields != nil {
+		found, err = ctx.Store.M(c.Model).FindFirstPartial(ctx, model, ctx.Query(), fields, nil, 0, lock)
+	} else {
+		found, err = ctx.Store.M(c.Model).FindFirst(ctx, model, ctx.Query(), nil, 0, lock)
+	}
 	xo.AbortIf(err)
 
 	// check if missing
@@ -1557,40 +2144,226 @@ func (c *Controller) loadModel(ctx *Context) {
 	c.runCallbacks(ctx, Verifier, c.Verifiers, http.StatusUnauthorized)
 }
 
-func (c *Controller) loadModels(ctx *Context) {
+type relatedFilter struct {
+	field   *coal.Field
+	filters map[string][]string
+}
+
+type listQuery struct {
+	filter         bson.M
+	sorting        []string
+	relatedSorters []string
+	skip           int64
+	limit          int64
+	reverse        bool
+	flags          coal.Flags
+}
+
+func (c *Controller) loadModels(ctx *Context, enforceLimit bool) {
 	// trace
 	ctx.Tracer.Push("fire/Controller.loadModels")
 	defer ctx.Tracer.Pop()
 
-	// filter out deleted documents if configured
-	if c.SoftDelete {
-		// get soft delete field
-		softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+	// prepare query and find models
+	c.findModels(ctx, c.prepareQuery(ctx, enforceLimit))
+}
 
-		// set filter
-		ctx.Selector[softDeleteField] = nil
+func (c *Controller) findModels(ctx *Context, q listQuery) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.findModels")
+	defer ctx.Tracer.Pop()
+
+	// get projection
+	fields := c.projectedFields(ctx, q.sorting, q.relatedSorters)
+
+	// get skip and limit, related sorters are applied in memory
+	skip, limit := q.skip, q.limit
+	if len(q.relatedSorters) > 0 {
+		skip, limit = 0, c.RelatedLimit+1
 	}
 
-	// add filters
-	for name, values := range ctx.JSONAPIRequest.Filters {
-		// get field
-		field := c.meta.RequestFields[name]
-		if field == nil {
-			xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, name)))
-		}
+	// load documents
+	models := c.meta.MakeSlice()
+	if fields != nil {
+		xo.AbortIf(ctx.Store.M(c.Model).FindAllPartial(ctx, models, q.filter, fields, q.sorting, skip, limit, false, q.flags))
+	} else {
+		xo.AbortIf(ctx.Store.M(c.Model).FindAll(ctx, models, q.filter, q.sorting, skip, limit, false, q.flags))
+	}
 
-		// handle filter handlers
-		if handler := c.FilterHandlers[field.Name]; handler != nil {
-			expression, err := handler(ctx, values)
-			if xo.IsSafe(err) {
-				xo.Abort(jsonapi.BadRequest(err.Error()))
-			} else if err != nil {
-				xo.Abort(err)
-			}
-			if len(expression) > 0 {
-				ctx.Filters = append(ctx.Filters, expression)
-				continue
-			}
+	// set models
+	ctx.Models = coal.Slice(models)
+
+	// sort and paginate documents by related sorters
+	if len(q.relatedSorters) > 0 {
+		if int64(len(ctx.Models)) > c.RelatedLimit {
+			xo.Abort(jsonapi.BadRequest("too many resources to sort by related fields"))
+		}
+		c.sortRelated(ctx, q.relatedSorters)
+		ctx.Models = ctx.Models[min(q.skip, int64(len(ctx.Models))):]
+		if q.limit > 0 {
+			ctx.Models = ctx.Models[:min(q.limit, int64(len(ctx.Models)))]
+		}
+	}
+
+	// undo reversion
+	if q.reverse {
+		for i, j := 0, len(ctx.Models)-1; i < j; i, j = i+1, j-1 {
+			ctx.Models[i], ctx.Models[j] = ctx.Models[j], ctx.Models[i]
+		}
+	}
+
+	// run verifiers
+	c.runCallbacks(ctx, Verifier, c.Verifiers, http.StatusUnauthorized)
+}
+
+func (c *Controller) projectedFields(ctx *Context, sorting, relatedSorters []string) []string {
+	// check projection
+	if !c.Projection {
+		return nil
+	}
+
+	// load full documents for verifiers and getters
+	if len(c.Verifiers) > 0 || ctx.GetReadableFields != nil || ctx.GetReadableProperties != nil {
+		return nil
+	}
+
+	// add stored readable fields
+	fields := make([]string, 0, len(ctx.ReadableFields))
+	for _, name := range ctx.ReadableFields {
+		if c.meta.Fields[name].BSONKey != "" {
+			fields = append(fields, name)
+		}
+	}
+
+	// add property fields, load all fields if not listed
+	for _, name := range ctx.ReadableProperties {
+		list, ok := c.PropertyFields[name]
+		if !ok {
+			return nil
+		}
+		fields = append(fields, list...)
+	}
+
+	// add sort fields
+	for _, sorter := range sorting {
+		if name := strings.TrimPrefix(sorter, "-"); name != "_id" {
+			fields = append(fields, name)
+		}
+	}
+
+	// add related sorter fields
+	for _, sorter := range relatedSorters {
+		path, _, _ := strings.Cut(strings.TrimPrefix(sorter, "-"), ".")
+		fields = append(fields, c.meta.Relationships[path].Name)
+	}
+
+	// add mechanism fields
+	if c.SoftDelete {
+		fields = append(fields, coal.L(c.Model, "fire-soft-delete", true))
+	}
+	if c.Tenancy != nil {
+		fields = append(fields, coal.L(c.Model, "fire-tenant", true))
+	}
+	if c.IdempotentCreate {
+		fields = append(fields, coal.L(c.Model, "fire-idempotent-create", true))
+	}
+	if c.ConsistentUpdate {
+		fields = append(fields, coal.L(c.Model, "fire-consistent-update", true))
+	}
+
+	return stick.Unique(fields)
+}
+
+func (c *Controller) prepareQuery(ctx *Context, enforceLimit bool) listQuery {
+	// trace
+	ctx.Tracer.Push("fire/Controller.prepareQuery")
+	defer ctx.Tracer.Pop()
+
+	// filter out deleted documents if configured
+	if c.SoftDelete {
+		// get soft delete field
+		softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+
+		// set filter unless soft deleted documents are requested
+		switch c.trashFilter(ctx) {
+		case "true":
+		case "only":
+			ctx.Selector[softDeleteField] = bson.M{"$ne": nil}
+		default:
+			ctx.Selector[softDeleteField] = nil
+		}
+	}
+
+	// prepare related filters
+	var relatedFilters []relatedFilter
+
+	// add filters
+	for name, values := range ctx.JSONAPIRequest.Filters {
+		// skip trash filter
+		if c.Trash && name == "deleted" {
+			continue
+		}
+
+		// split operator
+		key, operator, _ := strings.Cut(name, "][")
+
+		// handle related filters
+		if path, subKey, ok := strings.Cut(key, "."); ok {
+			// get field
+			field := c.meta.Relationships[path]
+			if field == nil || !field.ToOne || !stick.Contains(c.Filters, field.Name) {
+				xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, name)))
+			}
+
+			// readability is checked after running authorizers
+
+			// prepare related filter
+			if operator != "" {
+				subKey += "][" + operator
+			}
+
+			// related resources are looked up after running authorizers
+			relatedFilters = append(relatedFilters, relatedFilter{
+				field:   field,
+				filters: map[string][]string{subKey: values},
+			})
+
+			continue
+		}
+
+		// get field
+		field := c.meta.RequestFields[key]
+		if field == nil {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, name)))
+		}
+
+		// handle operator filters
+		if operator != "" {
+			// check whitelist
+			if field.HasOne || field.HasMany || !stick.Contains(c.Filters, field.Name) {
+				xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, name)))
+			}
+
+			// readability is checked after running authorizers
+
+			// add filter
+			ctx.Filters = append(ctx.Filters, c.operatorFilter(field, name, operator, values))
+
+			continue
+		}
+
+		// handle filter handlers
+		if handler := c.FilterHandlers[field.Name]; handler != nil {
+			expression, err := handler(ctx, values)
+			if xo.IsSafe(err) {
+				xo.Abort(jsonapi.BadRequest(err.Error()))
+			} else if err != nil {
+				xo.Abort(err)
+			}
+			if len(expression) > 0 {
+				ctx.Filters = append(ctx.Filters, expression)
+				continue
+			}
 		}
 
 		// handle attributes filter
@@ -1691,6 +2464,7 @@ func (c *Controller) loadModels(ctx *Context) {
 	}
 
 	// add sorting
+	var relatedSorters []string
 	for _, sorter := range ctx.JSONAPIRequest.Sorting {
 		// get direction
 		descending := strings.HasPrefix(sorter, "-")
@@ -1698,6 +2472,32 @@ func (c *Controller) loadModels(ctx *Context) {
 		// normalize sorter
 		normalizedSorter := strings.TrimPrefix(sorter, "-")
 
+		// handle related sorters
+		if path, _, ok := strings.Cut(normalizedSorter, "."); ok {
+			// check order
+			if len(ctx.Sorting) > 0 {
+				xo.Abort(jsonapi.BadRequest("related sorters must precede other sorters"))
+			}
+
+			// get field
+			field := c.meta.Relationships[path]
+			if field == nil || !field.ToOne {
+				xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid sorter "%s"`, normalizedSorter)))
+			}
+
+			// check whitelist
+			if !stick.Contains(c.Sorters, field.Name) {
+				xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`unsupported sorter "%s"`, normalizedSorter)))
+			}
+
+			// readability is checked after running authorizers
+
+			// add sorter
+			relatedSorters = append(relatedSorters, sorter)
+
+			continue
+		}
+
 		// find field
 		field := c.meta.Attributes[normalizedSorter]
 		if field == nil {
@@ -1729,8 +2529,13 @@ func (c *Controller) loadModels(ctx *Context) {
 	// determine pagination
 	cursorPagination := c.CursorPagination || ctx.JSONAPIRequest.Pagination == "cursor"
 
+	// check related sorting
+	if cursorPagination && len(relatedSorters) > 0 {
+		xo.Abort(jsonapi.BadRequest("cursor pagination not supported with related sorters"))
+	}
+
 	// apply list limit
-	if c.ListLimit > 0 && ctx.JSONAPIRequest.PageSize <= 0 {
+	if enforceLimit && c.ListLimit > 0 && ctx.JSONAPIRequest.PageSize <= 0 {
 		ctx.JSONAPIRequest.PageSize = c.ListLimit
 	}
 
@@ -1752,11 +2557,30 @@ func (c *Controller) loadModels(ctx *Context) {
 	// run authorizers
 	c.runCallbacks(ctx, Authorizer, c.Authorizers, http.StatusUnauthorized)
 
+	// check trash access
+	if c.trashFilter(ctx) != "" && !c.TrashAccess(ctx) {
+		xo.Abort(jsonapi.ErrorFromStatus(http.StatusUnauthorized, "trash access denied"))
+	}
+
+	// limit to tenant if configured
+	if c.Tenancy != nil {
+		c.applyTenancy(ctx)
+	}
+
 	// get readable fields
 	readableFields := c.readableFields(ctx, nil)
 
 	// check filter readability
-	for name := range ctx.JSONAPIRequest.Filters {
+	for key := range ctx.JSONAPIRequest.Filters {
+		// skip trash filter
+		if c.Trash && key == "deleted" {
+			continue
+		}
+
+		// strip operator and path
+		name, _, _ := strings.Cut(key, "][")
+		name, _, _ = strings.Cut(name, ".")
+
 		// handle attributes filter
 		if field := c.meta.Attributes[name]; field != nil {
 			if !stick.Contains(readableFields, field.Name) {
@@ -1774,7 +2598,7 @@ func (c *Controller) loadModels(ctx *Context) {
 		}
 
 		// raise an error on a unsupported filter
-		xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, name)))
+		xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid filter "%s"`, key)))
 	}
 
 	// check sorting readability
@@ -1784,6 +2608,9 @@ func (c *Controller) loadModels(ctx *Context) {
 
 		// find field
 		field := c.meta.Attributes[normalizedSorter]
+		if path, _, ok := strings.Cut(normalizedSorter, "."); ok {
+			field = c.meta.Relationships[path]
+		}
 		if field == nil {
 			xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid sorter "%s"`, normalizedSorter)))
 		}
@@ -1794,6 +2621,16 @@ func (c *Controller) loadModels(ctx *Context) {
 		}
 	}
 
+	// add related filters
+	for _, filter := range relatedFilters {
+		// lookup matching related resources
+		rc := c.relatedController(ctx, filter.field)
+		ids := rc.lookupIDs(ctx, nil, filter.filters, nil)
+
+		// add filter
+		ctx.Filters = append(ctx.Filters, bson.M{filter.field.Name: bson.M{"$in": ids}})
+	}
+
 	// prepare
 	query := ctx.Query()
 	sorting := append([]string{}, ctx.Sorting...)
@@ -1908,22 +2745,214 @@ func (c *Controller) loadModels(ctx *Context) {
 		flags |= coal.TextScoreSort
 	}
 
-	// load documents
-	models := c.meta.MakeSlice()
-	xo.AbortIf(ctx.Store.M(c.Model).FindAll(ctx, models, query, sorting, skip, limit, false, flags))
+	// guard query if configured
+	if c.IndexGuard != 0 {
+		c.guardQuery(ctx, query, sorting)
+	}
 
-	// set models
-	ctx.Models = coal.Slice(models)
+	return listQuery{
+		filter:         query,
+		sorting:        sorting,
+		relatedSorters: relatedSorters,
+		skip:           skip,
+		limit:          limit,
+		reverse:        reverse,
+		flags:          flags,
+	}
+}
 
-	// undo reversion
-	if reverse {
-		for i, j := 0, len(ctx.Models)-1; i < j; i, j = i+1, j-1 {
-			ctx.Models[i], ctx.Models[j] = ctx.Models[j], ctx.Models[i]
+func (c *Controller) sortRelated(ctx *Context, sorters []string) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.sortRelated")
+	defer ctx.Tracer.Pop()
+
+	// stable sort models by sorters in reverse order
+	for i := len(sorters) - 1; i >= 0; i-- {
+		// parse sorter
+		direction := ""
+		sorter := sorters[i]
+		if strings.HasPrefix(sorter, "-") {
+			direction = "-"
+			sorter = sorter[1:]
 		}
+		path, subSorter, _ := strings.Cut(sorter, ".")
+
+		// get field
+		field := c.meta.Relationships[path]
+
+		// collect references
+		refs := make([]coal.ID, len(ctx.Models))
+		for j, model := range ctx.Models {
+			switch ref := stick.MustGet(model, field.Name).(type) {
+			case coal.ID:
+				refs[j] = ref
+			case *coal.ID:
+				if ref != nil {
+					refs[j] = *ref
+				}
+			}
+		}
+
+		// lookup sorted related resources
+		rc := c.relatedController(ctx, field)
+		ids := rc.lookupIDs(ctx, bson.M{
+			"_id": bson.M{
+				"$in": stick.Unique(refs),
+			},
+		}, nil, []string{direction + subSorter})
+
+		// prepare ranks, unknown references are sorted last
+		ranks := make(map[coal.ID]int, len(ids))
+		for j, id := range ids {
+			ranks[id] = j
+		}
+		rank := func(id coal.ID) int {
+			if r, ok := ranks[id]; ok {
+				return r
+			}
+			return len(ids)
+		}
+
+		// sort order
+		order := make([]int, len(ctx.Models))
+		for j := range order {
+			order[j] = j
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return rank(refs[order[a]]) < rank(refs[order[b]])
+		})
+
+		// apply order
+		models := make([]coal.Model, 0, len(order))
+		for _, j := range order {
+			models = append(models, ctx.Models[j])
+		}
+		ctx.Models = models
 	}
+}
 
-	// run verifiers
-	c.runCallbacks(ctx, Verifier, c.Verifiers, http.StatusUnauthorized)
+func (c *Controller) lookupIDs(ctx *Context, selector bson.M, filters map[string][]string, sorting []string) []coal.ID {
+	// trace
+	ctx.Tracer.Push("fire/Controller.lookupIDs")
+	defer ctx.Tracer.Pop()
+
+	// prepare sub context
+	subCtx := &Context{
+		Context:     ctx,
+		Data:        stick.Map{},
+		Operation:   List,
+		HTTPRequest: ctx.HTTPRequest,
+		Controller:  c,
+		Group:       ctx.Group,
+		APIVersion:  ctx.APIVersion,
+		Tracer:      ctx.Tracer,
+		JSONAPIRequest: &jsonapi.Request{
+			Intent:       jsonapi.ListResources,
+			ResourceType: c.meta.PluralName,
+			Filters:      filters,
+			Sorting:      sorting,
+		},
+		tenant: ctx.tenant,
+	}
+
+	// prepare context
+	c.prepareContext(subCtx, selector)
+
+	// load models with the related limit
+	query := c.prepareQuery(subCtx, false)
+	query.limit = c.RelatedLimit + 1
+	c.findModels(subCtx, query)
+
+	// check limit
+	if int64(len(subCtx.Models)) > c.RelatedLimit {
+		xo.Abort(jsonapi.BadRequest("too many related resources"))
+	}
+
+	// collect IDs
+	ids := make([]coal.ID, 0, len(subCtx.Models))
+	for _, model := range subCtx.Models {
+		ids = append(ids, model.ID())
+	}
+
+	return ids
+}
+
+func (c *Controller) checkPrecondition(ctx *Context, token string) bool {
+	// check if consistent update is enabled
+	if !c.ConsistentUpdate {
+		return false
+	}
+
+	// get header
+	ifMatch := ctx.HTTPRequest.Header.Get("If-Match")
+	if ifMatch == "" {
+		return false
+	}
+
+	// check entity tag using the strong comparison (RFC 9110, Section 13.1.1)
+	if !matchEntityTag(ifMatch, `"`+token+`"`, true) {
+		xo.Abort(jsonapi.ErrorFromStatus(http.StatusPreconditionFailed, "precondition failed"))
+	}
+
+	return true
+}
+
+func (c *Controller) entityTag(ctx *Context) string {
+	// use response digest for reads
+	if ctx.Operation.Read() {
+		buf, err := json.Marshal(ctx.Response)
+		xo.AbortIf(err)
+		sum := sha1.Sum(buf)
+		return `W/"` + hex.EncodeToString(sum[:]) + `"`
+	}
+
+	// use readable consistent update token for created and updated resources
+	switch ctx.JSONAPIRequest.Intent {
+	case jsonapi.CreateResource, jsonapi.UpdateResource:
+		if c.ConsistentUpdate && ctx.Model != nil {
+			consistentUpdateField := coal.L(ctx.Model, "fire-consistent-update", true)
+			if stick.Contains(c.readableFields(ctx, ctx.Model), consistentUpdateField) {
+				return `"` + stick.MustGet(ctx.Model, consistentUpdateField).(string) + `"`
+			}
+		}
+	}
+
+	return ""
+}
+
+func matchEntityTag(header, etag string, strong bool) bool {
+	// check header
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	} else if header == "*" {
+		return true
+	}
+
+	// compare tags
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strong {
+			// weak tags never match strongly (RFC 9110, Section 8.8.3.2)
+			if !strings.HasPrefix(tag, "W/") && !strings.HasPrefix(etag, "W/") && tag == etag {
+				return true
+			}
+		} else if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Controller) relatedController(ctx *Context, field *coal.Field) *Controller {
+	// get related controller
+	rc := ctx.Group.controllers[field.RelType]
+	if rc == nil {
+		xo.Abort(xo.F("missing related controller for %s", field.RelType))
+	}
+
+	return rc
 }
 
 func (c *Controller) assignData(ctx *Context, res *jsonapi.Resource) {
@@ -1957,10 +2986,13 @@ func (c *Controller) assignData(ctx *Context, res *jsonapi.Resource) {
 	verifyReadOnly := make([]string, 0, len(res.Attributes)+len(res.Relationships))
 
 	// collect properties
-	properties := make([]string, 0, len(c.Properties))
+	properties := make([]string, 0, len(c.Properties)+len(c.BatchProperties))
 	for _, key := range c.Properties {
 		properties = append(properties, key)
 	}
+	for key := range c.BatchProperties {
+		properties = append(properties, key)
+	}
 
 	// whitelist attributes
 	attributes := make(jsonapi.Map)
@@ -2096,11 +3128,20 @@ func (c *Controller) assignRelationship(ctx *Context, rel *jsonapi.Document, fie
 	}
 }
 
-func (c *Controller) preloadRelationships(ctx *Context, models []coal.Model) map[string]map[coal.ID][]coal.ID {
+func (c *Controller) preloadRelationships(ctx *Context, models []coal.Model, includes ...string) map[string]map[coal.ID][]coal.ID {
 	// trace
 	ctx.Tracer.Push("fire/Controller.preloadRelationships")
 	defer ctx.Tracer.Pop()
 
+	// get included relationships
+	included := map[string]bool{
+		ctx.JSONAPIRequest.Relationship: true,
+	}
+	for _, path := range append(includes, ctx.JSONAPIRequest.Include...) {
+		name, _, _ := strings.Cut(path, ".")
+		included[name] = true
+	}
+
 	// get readable fields
 	readableFields := c.readableFields(ctx, ctx.Model)
 
@@ -2136,6 +3177,11 @@ func (c *Controller) preloadRelationships(ctx *Context, models []coal.Model) map
 			continue
 		}
 
+		// skip counted relationships unless requested or included
+		if stick.Contains(c.RelationshipCounts, field.Name) && !included[field.RelName] {
+			continue
+		}
+
 		// get related controller
 		rc := ctx.Group.controllers[field.RelType]
 		if rc == nil {
@@ -2155,31 +3201,14 @@ func (c *Controller) preloadRelationships(ctx *Context, models []coal.Model) map
 		}
 
 		// prepare query
-		query := bson.M{
+		query := rc.relatedQuery(ctx, field, bson.M{
 			rel.Name: bson.M{
 				"$in": modelIDs,
 			},
-		}
-
-		// exclude soft deleted documents
-		if rc.SoftDelete {
-			// get soft delete field
-			softDeleteField := coal.L(rc.Model, "fire-soft-delete", true)
-
-			// set filter
-			query[softDeleteField] = nil
-		}
-
-		// prepare filters
-		filters := []bson.M{query}
-
-		// add relationship filters
-		filters = append(filters, ctx.RelationshipFilters[field.Name]...)
+		})
 
 		// project references
-		references, err := ctx.Store.M(rc.Model).ProjectAll(ctx, bson.M{
-			"$and": filters,
-		}, rel.Name, nil, 0, 0, false)
+		references, err := ctx.Store.M(rc.Model).ProjectAll(ctx, query, rel.Name, nil, 0, 0, false)
 		xo.AbortIf(err)
 
 		// prepare entry
@@ -2237,6 +3266,12 @@ func (c *Controller) resourceForModel(ctx *Context, model coal.Model, relationsh
 	// construct resource
 	resource := c.constructResource(ctx, model, relationships)
 
+	// apply relationship counts
+	c.applyRelationshipCounts(ctx, []coal.Model{model}, []*jsonapi.Resource{resource})
+
+	// apply batch properties
+	c.applyBatchProperties(ctx, []coal.Model{model}, []*jsonapi.Resource{resource})
+
 	return resource
 }
 
@@ -2254,9 +3289,59 @@ func (c *Controller) resourcesForModels(ctx *Context, models []coal.Model, relat
 		resources[i] = c.constructResource(ctx, model, relationships)
 	}
 
+	// apply relationship counts
+	c.applyRelationshipCounts(ctx, models, resources)
+
+	// apply batch properties
+	c.applyBatchProperties(ctx, models, resources)
+
 	return resources
 }
 
+func (c *Controller) applyBatchProperties(ctx *Context, models []coal.Model, resources []*jsonapi.Resource) {
+	// skip if no batch properties are configured
+	if len(c.BatchProperties) == 0 {
+		return
+	}
+
+	// trace
+	ctx.Tracer.Push("fire/Controller.applyBatchProperties")
+	defer ctx.Tracer.Pop()
+
+	// get readable properties
+	readableProperties := make([][]string, len(models))
+	for i, model := range models {
+		readableProperties[i] = c.readableProperties(ctx, model)
+	}
+
+	// call batch properties
+	for key, property := range c.BatchProperties {
+		// collect models with readable property
+		var list []coal.Model
+		var indexes []int
+		for i, model := range models {
+			if stick.Contains(readableProperties[i], key) {
+				list = append(list, model)
+				indexes = append(indexes, i)
+			}
+		}
+
+		// skip if no model has the property readable
+		if len(list) == 0 {
+			continue
+		}
+
+		// call property
+		values, err := property(ctx, list)
+		xo.AbortIf(err)
+
+		// set attributes
+		for _, i := range indexes {
+			resources[i].Attributes[key] = values[models[i].ID()]
+		}
+	}
+}
+
 func (c *Controller) constructResource(ctx *Context, model coal.Model, relationships map[string]map[coal.ID][]coal.ID) *jsonapi.Resource {
 	// do not trace this call
 
@@ -2429,8 +3514,18 @@ func (c *Controller) constructResource(ctx *Context, model coal.Model, relations
 				continue
 			}
 
+			// set only links if not preloaded
+			entry, ok := relationships[field.RelName]
+			if !ok {
+				resource.Relationships[field.RelName] = &jsonapi.Document{
+					Links: links,
+				}
+
+				continue
+			}
+
 			// get preloaded references
-			refs := relationships[field.RelName][model.ID()]
+			refs := entry[model.ID()]
 
 			// prepare references
 			references := make([]*jsonapi.Resource, len(refs))
@@ -2481,6 +3576,390 @@ func (c *Controller) constructResource(ctx *Context, model coal.Model, relations
 	return resource
 }
 
+func (c *Controller) applyRelationshipCounts(ctx *Context, models []coal.Model, resources []*jsonapi.Resource) {
+	// skip if no relationships are counted
+	if len(c.RelationshipCounts) == 0 {
+		return
+	}
+
+	// trace
+	ctx.Tracer.Push("fire/Controller.applyRelationshipCounts")
+	defer ctx.Tracer.Pop()
+
+	// count relationships
+	for _, name := range c.RelationshipCounts {
+		// get field
+		field := c.meta.Fields[name]
+
+		// collect models with readable relationship
+		var list []coal.Model
+		var indexes []int
+		for i, model := range models {
+			if resources[i].Relationships[field.RelName] != nil {
+				list = append(list, model)
+				indexes = append(indexes, i)
+			}
+		}
+
+		// skip if no model has the relationship readable
+		if len(list) == 0 {
+			continue
+		}
+
+		// count related documents
+		counts := c.countRelated(ctx, field, list)
+
+		// set meta
+		for _, i := range indexes {
+			resources[i].Relationships[field.RelName].Meta = jsonapi.Map{
+				"count": counts[models[i].ID()],
+			}
+		}
+	}
+}
+
+func (c *Controller) countRelated(ctx *Context, field *coal.Field, models []coal.Model) map[coal.ID]int64 {
+	// get related controller
+	rc := c.relatedController(ctx, field)
+
+	// prepare counts
+	counts := make(map[coal.ID]int64, len(models))
+
+	// handle to-many relationships
+	if field.ToMany {
+		// collect referenced IDs
+		var ids []coal.ID
+		for _, model := range models {
+			ids = append(ids, stick.MustGet(model, field.Name).([]coal.ID)...)
+		}
+		if len(ids) == 0 {
+			return counts
+		}
+
+		// find existing related documents
+		existing, err := ctx.Store.M(rc.Model).ProjectAll(ctx, rc.relatedQuery(ctx, field, bson.M{
+			"_id": bson.M{
+				"$in": stick.Unique(ids),
+			},
+		}), "_id", nil, 0, 0, false)
+		xo.AbortIf(err)
+
+		// count existing references
+		for _, model := range models {
+			for _, id := range stick.Unique(stick.MustGet(model, field.Name).([]coal.ID)) {
+				if _, ok := existing[id]; ok {
+					counts[model.ID()]++
+				}
+			}
+		}
+
+		return counts
+	}
+
+	// find inverse relationship
+	rel := rc.meta.Relationships[field.RelInverse]
+	if rel == nil {
+		xo.Abort(xo.F("no relationship matching the inverse name %s", field.RelInverse))
+	}
+
+	// collect model IDs
+	ids := make([]coal.ID, 0, len(models))
+	for _, model := range models {
+		ids = append(ids, model.ID())
+	}
+
+	// prepare query
+	query := rc.relatedQuery(ctx, field, bson.M{
+		rel.Name: bson.M{
+			"$in": ids,
+		},
+	})
+
+	// count references in memory as lungo does not support aggregations
+	if ctx.Store.Lungo() {
+		references, err := ctx.Store.M(rc.Model).ProjectAll(ctx, query, rel.Name, nil, 0, 0, false)
+		xo.AbortIf(err)
+		for _, value := range references {
+			if rel.ToOne {
+				rid, _ := value.(coal.ID)
+				counts[rid]++
+			} else {
+				rids, _ := value.(bson.A)
+				for _, rid := range rids {
+					rid, _ := rid.(coal.ID)
+					counts[rid]++
+				}
+			}
+		}
+
+		return counts
+	}
+
+	// translate query
+	filter, err := coal.NewTranslator(rc.Model).Document(query)
+	xo.AbortIf(err)
+
+	// prepare pipeline
+	key := "$" + rel.BSONKey
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+	}
+	if rel.ToMany {
+		pipeline = append(pipeline,
+			bson.M{"$unwind": key},
+			bson.M{"$match": bson.M{rel.BSONKey: bson.M{"$in": ids}}},
+		)
+	}
+	pipeline = append(pipeline, bson.M{"$group": bson.M{
+		"_id":   key,
+		"count": bson.M{"$sum": 1},
+	}})
+
+	// run aggregation
+	iter, err := ctx.Store.C(rc.Model).Aggregate(ctx, pipeline)
+	xo.AbortIf(err)
+	defer iter.Close()
+
+	// collect counts
+	for iter.Next() {
+		var doc struct {
+			ID    coal.ID `bson:"_id"`
+			Count int64   `bson:"count"`
+		}
+		xo.AbortIf(iter.Decode(&doc))
+		counts[doc.ID] = doc.Count
+	}
+	xo.AbortIf(iter.Error())
+
+	return counts
+}
+
+func (c *Controller) relatedQuery(ctx *Context, field *coal.Field, query bson.M) bson.M {
+	// exclude soft deleted documents
+	if c.SoftDelete {
+		// get soft delete field
+		softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+
+		// set filter
+		query[softDeleteField] = nil
+	}
+
+	// limit to tenant if configured
+	if c.Tenancy != nil {
+		tenantField := coal.L(c.Model, "fire-tenant", true)
+		query[tenantField] = c.resolveTenant(ctx)
+	}
+
+	// prepare filters
+	filters := []bson.M{query}
+
+	// add relationship filters
+	filters = append(filters, ctx.RelationshipFilters[field.Name]...)
+
+	return bson.M{
+		"$and": filters,
+	}
+}
+
+func (c *Controller) includeResources(ctx *Context, resources []*jsonapi.Resource) []*jsonapi.Resource {
+	// skip if no includes have been requested
+	if len(ctx.JSONAPIRequest.Include) == 0 {
+		return nil
+	}
+
+	// trace
+	ctx.Tracer.Push("fire/Controller.includeResources")
+	defer ctx.Tracer.Pop()
+
+	// index primary resources
+	index := make(map[string]*jsonapi.Resource, len(resources))
+	primary := make(map[string]bool, len(resources))
+	for _, res := range resources {
+		index[res.Type+"/"+res.ID] = res
+		primary[res.Type+"/"+res.ID] = true
+	}
+
+	// load included resources
+	c.loadIncludes(ctx, resources, ctx.JSONAPIRequest.Include, index)
+
+	// collect included resources
+	included := make([]*jsonapi.Resource, 0, len(index))
+	for key, res := range index {
+		if !primary[key] {
+			included = append(included, res)
+		}
+	}
+
+	// sort included resources
+	sort.Slice(included, func(i, j int) bool {
+		if included[i].Type != included[j].Type {
+			return included[i].Type < included[j].Type
+		}
+		return included[i].ID < included[j].ID
+	})
+
+	return included
+}
+
+func (c *Controller) loadIncludes(ctx *Context, resources []*jsonapi.Resource, paths []string, index map[string]*jsonapi.Resource) {
+	// group paths by relationship
+	tree := make(map[string][]string)
+	for _, path := range paths {
+		name, rest, nested := strings.Cut(path, ".")
+		if nested {
+			tree[name] = append(tree[name], rest)
+		} else if _, ok := tree[name]; !ok {
+			tree[name] = nil
+		}
+	}
+
+	// sort names
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// handle relationships
+	for _, name := range names {
+		// get relationship
+		rel := c.meta.Relationships[name]
+		if rel == nil {
+			xo.Abort(jsonapi.BadRequestParam(fmt.Sprintf(`invalid include path "%s"`, name), "include"))
+		}
+
+		// get related controller
+		rc := ctx.Group.controllers[rel.RelType]
+		if rc == nil {
+			xo.Abort(xo.F("missing related controller %s", rel.RelType))
+		}
+
+		// collect referenced IDs from readable relationships
+		var ids []coal.ID
+		for _, res := range resources {
+			doc := res.Relationships[name]
+			if doc == nil || doc.Data == nil {
+				continue
+			}
+			if doc.Data.One != nil {
+				ids = append(ids, coal.MustFromHex(doc.Data.One.ID))
+			}
+			for _, ref := range doc.Data.Many {
+				ids = append(ids, coal.MustFromHex(ref.ID))
+			}
+		}
+
+		// ensure list is unique
+		ids = stick.Unique(ids)
+
+		// split already included resources
+		var related []*jsonapi.Resource
+		missing := make([]coal.ID, 0, len(ids))
+		for _, id := range ids {
+			if res := index[rel.RelType+"/"+id.Hex()]; res != nil {
+				related = append(related, res)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+
+		// load missing resources in batches that respect the list limit
+		for len(missing) > 0 {
+			// get batch
+			batch := missing
+			if rc.ListLimit > 0 && int64(len(batch)) > rc.ListLimit {
+				batch = batch[:rc.ListLimit]
+			}
+			missing = missing[len(batch):]
+
+			// prepare sub context
+			subCtx := &Context{
+				Context:        ctx,
+				Data:           stick.Map{},
+				HTTPRequest:    ctx.HTTPRequest,
+				ResponseWriter: nil,
+				Controller:     rc,
+				Group:          ctx.Group,
+				APIVersion:     ctx.APIVersion,
+				Tracer:         ctx.Tracer,
+				tenant:         ctx.tenant,
+			}
+
+			// prepare request
+			subCtx.JSONAPIRequest = &jsonapi.Request{
+				Intent:       jsonapi.ListResources,
+				Prefix:       ctx.JSONAPIRequest.Prefix,
+				ResourceType: rel.RelType,
+				Fields:       ctx.JSONAPIRequest.Fields,
+			}
+
+			// prepare selector
+			selector := bson.M{
+				"_id": bson.M{"$in": batch},
+			}
+
+			// handle virtual request
+			rc.handle("", subCtx, selector, false)
+
+			// complete counted relationships of nested includes
+			if len(tree[name]) > 0 {
+				rc.completeCounted(subCtx, subCtx.Response.Data.Many, tree[name])
+			}
+
+			// add resources
+			for _, res := range subCtx.Response.Data.Many {
+				index[res.Type+"/"+res.ID] = res
+				related = append(related, res)
+			}
+		}
+
+		// include nested relationships
+		if len(tree[name]) > 0 {
+			rc.loadIncludes(ctx, related, tree[name], index)
+		}
+	}
+}
+
+func (c *Controller) completeCounted(ctx *Context, resources []*jsonapi.Resource, paths []string) {
+	// check for included counted relationships
+	var found bool
+	for _, path := range paths {
+		name, _, _ := strings.Cut(path, ".")
+		field := c.meta.Relationships[name]
+		found = found || (field != nil && field.HasMany && stick.Contains(c.RelationshipCounts, field.Name))
+	}
+	if !found {
+		return
+	}
+
+	// preload relationships
+	relationships := c.preloadRelationships(ctx, ctx.Models, paths...)
+
+	// set missing references
+	for i, res := range resources {
+		for name, entry := range relationships {
+			// check relationship
+			doc := res.Relationships[name]
+			if doc == nil || doc.Data != nil {
+				continue
+			}
+
+			// set references
+			refs := entry[ctx.Models[i].ID()]
+			references := make([]*jsonapi.Resource, len(refs))
+			for j, id := range refs {
+				references[j] = &jsonapi.Resource{
+					Type: c.meta.Relationships[name].RelType,
+					ID:   id.Hex(),
+				}
+			}
+			doc.Data = &jsonapi.HybridResource{
+				Many: references,
+			}
+		}
+	}
+}
+
 func (c *Controller) listLinks(ctx *Context) *jsonapi.DocumentLinks {
 	// trace
 	ctx.Tracer.Push("fire/Controller.listLinks")
@@ -2708,3 +4187,99 @@ func (c *Controller) readableProperties(ctx *Context, model coal.Model) []string
 
 	return properties
 }
+
+func (c *Controller) operatorFilter(field *coal.Field, name, operator string, values []string) bson.M {
+	// split values
+	var items []string
+	for _, value := range values {
+		items = append(items, strings.Split(value, ",")...)
+	}
+
+	// handle existence operator
+	if operator == "exists" {
+		if len(items) != 1 || items[0] != "true" && items[0] != "false" {
+			xo.Abort(jsonapi.BadRequestParam("invalid filter value", "filter["+name+"]"))
+		}
+		if items[0] == "true" {
+			return bson.M{field.Name: bson.M{"$ne": nil}}
+		}
+		return bson.M{field.Name: nil}
+	}
+
+	// check operator
+	switch operator {
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		if len(items) != 1 {
+			xo.Abort(jsonapi.BadRequestParam("invalid filter value", "filter["+name+"]"))
+		}
+	case "in", "nin":
+	default:
+		xo.Abort(jsonapi.BadRequestParam(fmt.Sprintf(`invalid filter operator "%s"`, operator), "filter["+name+"]"))
+	}
+
+	// parse values
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		value, ok := c.parseFilterValue(field.Type, item)
+		if !ok {
+			xo.Abort(jsonapi.BadRequestParam("invalid filter value", "filter["+name+"]"))
+		}
+		list = append(list, value)
+	}
+
+	// handle list operators
+	if operator == "in" || operator == "nin" {
+		return bson.M{field.Name: bson.M{"$" + operator: list}}
+	}
+
+	return bson.M{field.Name: bson.M{"$" + operator: list[0]}}
+}
+
+func (c *Controller) parseFilterValue(typ reflect.Type, value string) (interface{}, bool) {
+	// unwrap pointers and slices
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+
+	// handle special types
+	switch typ {
+	case idType:
+		id, err := coal.FromHex(value)
+		return id, err == nil
+	case timeType:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			t, err := time.Parse(layout, value)
+			if err == nil {
+				return t, true
+			}
+		}
+		return nil, false
+	}
+
+	// handle text unmarshaler (e.g. coal.Date, coal.Decimal)
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		ptr := reflect.New(typ)
+		err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return ptr.Elem().Interface(), err == nil
+	}
+
+	// handle basic kinds
+	switch typ.Kind() {
+	case reflect.String:
+		return value, true
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, typ.Bits())
+		return i, err == nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, typ.Bits())
+		return int64(u), err == nil && u <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, typ.Bits())
+		return f, err == nil
+	}
+
+	return nil, false
+}
@@ -5,11 +5,14 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/256dpi/jsonapi/v2"
 	"github.com/256dpi/serve"
 	"github.com/256dpi/xo"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
 	"go.mongodb.org/mongo-driver/bson"
@@ -2657,6 +2660,148 @@ func TestFilterHandlers(t *testing.T) {
 	})
 }
 
+func TestFilterOperators(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:   &productModel{},
+			Filters: []string{"Name", "Price", "Stock", "ReleaseDate", "PublishedAt"},
+		}, &Controller{
+			Model:   &commentModel{},
+			Filters: []string{"Parent"},
+		})
+
+		// create products
+		product1 := tester.Insert(&productModel{
+			Name:        "foo",
+			Price:       decimal.RequireFromString("5.5"),
+			Stock:       5,
+			ReleaseDate: coal.Date{Year: 2020, Month: 1, Day: 1},
+			PublishedAt: stick.P(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}).ID().Hex()
+		product2 := tester.Insert(&productModel{
+			Name:        "bar",
+			Price:       decimal.RequireFromString("10"),
+			Stock:       10,
+			ReleaseDate: coal.Date{Year: 2021, Month: 6, Day: 15},
+		}).ID().Hex()
+		product3 := tester.Insert(&productModel{
+			Name:        "baz",
+			Price:       decimal.RequireFromString("20.25"),
+			Stock:       20,
+			ReleaseDate: coal.Date{Year: 2022, Month: 12, Day: 31},
+			PublishedAt: stick.P(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}).ID().Hex()
+
+		// create comments
+		comment1 := tester.Insert(&commentModel{
+			Message: "foo",
+		}).ID()
+		comment2 := tester.Insert(&commentModel{
+			Message: "bar",
+			Parent:  &comment1,
+		}).ID().Hex()
+
+		for _, item := range []struct {
+			filter string
+			ids    []string
+		}{
+			{"filter[stock][gte]=10", []string{product2, product3}},
+			{"filter[stock][gt]=10", []string{product3}},
+			{"filter[stock][lt]=10", []string{product1}},
+			{"filter[stock][lte]=10", []string{product1, product2}},
+			{"filter[stock][eq]=10", []string{product2}},
+			{"filter[stock][ne]=10", []string{product1, product3}},
+			{"filter[stock][in]=5,20", []string{product1, product3}},
+			{"filter[stock][nin]=5,20", []string{product2}},
+			{"filter[stock][gte]=10&filter[stock][lt]=20", []string{product2}},
+			{"filter[name][ne]=foo", []string{product2, product3}},
+			{"filter[price][gte]=10", []string{product2, product3}},
+			{"filter[release-date][lt]=2021-06-15", []string{product1}},
+			{"filter[published-at][gt]=2021-01-01", []string{product3}},
+			{"filter[published-at][lt]=2021-01-01T00:00:00Z", []string{product1}},
+			{"filter[published-at][exists]=true", []string{product1, product3}},
+			{"filter[published-at][exists]=false", []string{product2}},
+		} {
+			tester.Request("GET", "products?"+item.filter, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				var ids []string
+				for _, id := range gjson.Get(r.Body.String(), "data.#.id").Array() {
+					ids = append(ids, id.String())
+				}
+
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.ElementsMatch(t, item.ids, ids, item.filter)
+			})
+		}
+
+		// test relationship operator
+		tester.Request("GET", "comments?filter[parent][exists]=true", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[
+				"`+comment2+`"
+			]`, gjson.Get(r.Body.String(), "data.#.id").Raw, tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "comments?filter[parent][ne]="+comment1.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[
+				"`+comment1.Hex()+`"
+			]`, gjson.Get(r.Body.String(), "data.#.id").Raw, tester.DebugRequest(rq, r))
+		})
+
+		// test invalid operator
+		tester.Request("GET", "products?filter[stock][foo]=1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid filter operator \"foo\"",
+					"source": {
+						"parameter": "filter[stock][foo]"
+					}
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test invalid value
+		tester.Request("GET", "products?filter[stock][gt]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid filter value",
+					"source": {
+						"parameter": "filter[stock][gt]"
+					}
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test multiple values
+		tester.Request("GET", "products?filter[stock][gt]=1,2", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// test invalid existence value
+		tester.Request("GET", "products?filter[stock][exists]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// test unlisted field
+		tester.Request("GET", "comments?filter[message][ne]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid filter \"message][ne\""
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
 func TestSorting(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		tester.Assign("", &Controller{
@@ -3239,6 +3384,247 @@ func TestSorting(t *testing.T) {
 	})
 }
 
+func TestRelatedFilteringAndSorting(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:   &postModel{},
+			Filters: []string{"Title"},
+			Sorters: []string{"Title"},
+			Authorizers: L{
+				C("TestRelatedFilteringAndSorting", Authorizer, All(), func(ctx *Context) error {
+					ctx.Filters = append(ctx.Filters, bson.M{
+						"Title": bson.M{"$ne": "hidden"},
+					})
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model:   &commentModel{},
+			Filters: []string{"Post"},
+			Sorters: []string{"Post", "Message"},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create posts
+		post1 := tester.Insert(&postModel{
+			Title: "a",
+		}).ID()
+		post2 := tester.Insert(&postModel{
+			Title: "b",
+		}).ID()
+		post3 := tester.Insert(&postModel{
+			Title: "hidden",
+		}).ID()
+
+		// create comments
+		comment1 := tester.Insert(&commentModel{
+			Message: "1",
+			Post:    post2,
+		}).ID().Hex()
+		comment2 := tester.Insert(&commentModel{
+			Message: "2",
+			Post:    post1,
+		}).ID().Hex()
+		comment3 := tester.Insert(&commentModel{
+			Message: "3",
+			Post:    post3,
+		}).ID().Hex()
+		comment4 := tester.Insert(&commentModel{
+			Message: "4",
+			Post:    post1,
+		}).ID().Hex()
+
+		for _, item := range []struct {
+			query string
+			ids   []string
+		}{
+			{"filter[post.title]=a", []string{comment2, comment4}},
+			{"filter[post.title]=a,b", []string{comment1, comment2, comment4}},
+			{"filter[post.title][ne]=a", []string{comment1}},
+			{"filter[post.title]=hidden", nil},
+			{"sort=post.title,message", []string{comment2, comment4, comment1, comment3}},
+			{"sort=-post.title,-message", []string{comment1, comment4, comment2, comment3}},
+			{"sort=post.title,message&page[number]=2&page[size]=2", []string{comment1, comment3}},
+			{"sort=post.title,message&page[number]=3&page[size]=2", nil},
+			{"filter[post.title]=a,b&sort=-post.title,message", []string{comment1, comment2, comment4}},
+		} {
+			tester.Request("GET", "comments?"+item.query, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				var ids []string
+				for _, id := range gjson.Get(r.Body.String(), "data.#.id").Array() {
+					ids = append(ids, id.String())
+				}
+
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, item.ids, ids, item.query)
+			})
+		}
+
+		// test unsupported related filter
+		tester.Request("GET", "comments?filter[post.text-body]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid filter \"text-body\""
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test unlisted relationship filter
+		tester.Request("GET", "comments?filter[parent.message]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid filter \"parent.message\""
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test unsupported related sorter
+		tester.Request("GET", "comments?sort=post.text-body", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "unsupported sorter \"text-body\""
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test sorter order
+		tester.Request("GET", "comments?sort=message,post.title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "related sorters must precede other sorters"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// test cursor pagination
+		tester.Request("GET", "comments?sort=post.title&pagination=cursor&page[size]=1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "cursor pagination not supported with related sorters"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestRelatedFilteringAuthorization(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var lookups int
+		tester.Assign("", &Controller{
+			Model:   &postModel{},
+			Filters: []string{"Title"},
+			Authorizers: L{
+				C("TestRelatedFilteringAuthorization", Authorizer, All(), func(ctx *Context) error {
+					lookups++
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model:   &commentModel{},
+			Filters: []string{"Post"},
+			Authorizers: L{
+				C("TestRelatedFilteringAuthorization", Authorizer, All(), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Denied") != "" {
+						return ErrAccessDenied.Wrap()
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "a",
+		}).ID()
+		comment := tester.Insert(&commentModel{
+			Message: "1",
+			Post:    post,
+		}).ID().Hex()
+
+		// filter without access
+		tester.Header["Denied"] = "true"
+		tester.Request("GET", "comments?filter[post.title]=a", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnauthorized, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		delete(tester.Header, "Denied")
+		assert.Equal(t, 0, lookups)
+
+		// filter with access
+		tester.Request("GET", "comments?filter[post.title]=a", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+comment+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+		assert.Equal(t, 1, lookups)
+	})
+}
+
+func TestRelatedLimit(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:        &postModel{},
+			Filters:      []string{"Title"},
+			Sorters:      []string{"Title"},
+			RelatedLimit: 2,
+		}, &Controller{
+			Model:        &commentModel{},
+			Filters:      []string{"Post"},
+			Sorters:      []string{"Post"},
+			RelatedLimit: 2,
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create posts and comments
+		for _, title := range []string{"a", "b", "c"} {
+			post := tester.Insert(&postModel{
+				Title: title,
+			}).ID()
+			tester.Insert(&commentModel{
+				Message: title,
+				Post:    post,
+			})
+		}
+
+		for _, item := range []struct {
+			query  string
+			status int
+			detail string
+		}{
+			{"filter[post.title]=a,b", http.StatusOK, ""},
+			{"filter[post.title]=a,b,c", http.StatusBadRequest, "too many related resources"},
+			{"filter[post.title]=a,b&sort=post.title", http.StatusOK, ""},
+			{"sort=post.title", http.StatusBadRequest, "too many resources to sort by related fields"},
+		} {
+			tester.Request("GET", "comments?"+item.query, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, item.status, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, item.detail, gjson.Get(r.Body.String(), "errors.0.detail").String(), item.query)
+			})
+		}
+	})
+}
+
 func TestSearching(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		if tester.Store.Lungo() {
@@ -3358,6 +3744,27 @@ func TestProperties(t *testing.T) {
 			})
 		})
 
+		// attribute collision
+		assert.PanicsWithValue(t, `fire: property "title" collides with existing attribute`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Properties: map[string]string{
+					"Virtual": "title",
+				},
+			})
+		})
+
+		// property collision
+		assert.PanicsWithValue(t, `fire: property "virtual" collides with existing attribute`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Properties: map[string]string{
+					"Virtual":      "virtual",
+					"VirtualError": "virtual",
+				},
+			})
+		})
+
 		group := tester.Assign("", &Controller{
 			Model: &postModel{},
 			Properties: map[string]string{
@@ -5455,36 +5862,230 @@ func TestReadableProperties(t *testing.T) {
 	})
 }
 
-func TestReadablePropertiesGetter(t *testing.T) {
+func TestProjection(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, `fire: invalid property "Foo"`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				PropertyFields: map[string][]string{
+					"Foo": {"Title"},
+				},
+			})
+		})
+
+		assert.PanicsWithValue(t, `fire: invalid property field "Comments"`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Properties: map[string]string{
+					"Virtual": "virtual",
+				},
+				PropertyFields: map[string][]string{
+					"Virtual": {"Comments"},
+				},
+			})
+		})
+
+		var loaded []*postModel
 		tester.Assign("", &Controller{
-			Model: &postModel{},
+			Model:      &postModel{},
+			Projection: true,
+			SoftDelete: true,
 			Properties: map[string]string{
 				"Virtual":      "virtual",
 				"VirtualError": "virtual-error",
 			},
+			PropertyFields: map[string][]string{
+				"Virtual": {},
+			},
 			Authorizers: L{
-				C("TestReadablePropertiesGetter", Authorizer, All(), func(ctx *Context) error {
-					ctx.ReadableFields = []string{"Title"}
-					ctx.GetReadableProperties = func(model coal.Model) []string {
-						if model != nil {
-							if model.(*postModel).Title == "post1" {
-								return []string{"Virtual", "VirtualError"}
+				C("TestProjection", Authorizer, All(), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Getter") != "" {
+						ctx.GetReadableFields = func(model coal.Model) []string {
+							if model != nil && model.(*postModel).TextBody == "" {
+								return nil
 							}
+							return ctx.ReadableFields
 						}
-						return []string{"Virtual"}
 					}
 					return nil
 				}),
 			},
-		})
-
-		// create posts
-		post1 := tester.Insert(&postModel{
-			Title: "post1",
-		}).ID().Hex()
-		post2 := tester.Insert(&postModel{
-			Title: "post2",
+			Decorators: L{
+				C("TestProjection", Decorator, All(), func(ctx *Context) error {
+					loaded = nil
+					if ctx.Model != nil {
+						loaded = append(loaded, ctx.Model.(*postModel))
+					}
+					for _, model := range ctx.Models {
+						loaded = append(loaded, model.(*postModel))
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title:     "Post 1",
+			Published: true,
+			TextBody:  "Hello",
+		}).ID()
+
+		// list title
+		tester.Request("GET", "posts?fields[posts]=title,virtual", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": [
+					{
+						"type": "posts",
+						"id": "`+post.Hex()+`",
+						"attributes": {
+							"title": "Post 1",
+							"virtual": 42
+						}
+					}
+				],
+				"links": {
+					"self": "/posts?fields%5Bposts%5D=title%2Cvirtual"
+				}
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+		assert.Len(t, loaded, 1)
+		assert.Equal(t, "Post 1", loaded[0].Title)
+		assert.False(t, loaded[0].Published)
+		assert.Empty(t, loaded[0].TextBody)
+
+		// find published
+		tester.Request("GET", "posts/"+post.Hex()+"?fields[posts]=published", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.True(t, gjson.Get(r.Body.String(), "data.attributes.published").Bool(), tester.DebugRequest(rq, r))
+		})
+		assert.Len(t, loaded, 1)
+		assert.Empty(t, loaded[0].Title)
+		assert.True(t, loaded[0].Published)
+
+		// find with unlisted property
+		tester.Request("GET", "posts/"+post.Hex()+"?fields[posts]=published,virtual-error", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Len(t, loaded, 1)
+		assert.Equal(t, "Post 1", loaded[0].Title)
+		assert.Equal(t, "Hello", loaded[0].TextBody)
+
+		// list with getter
+		tester.Header["Getter"] = "1"
+		tester.Request("GET", "posts?fields[posts]=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post 1", gjson.Get(r.Body.String(), "data.0.attributes.title").String(), tester.DebugRequest(rq, r))
+		})
+		delete(tester.Header, "Getter")
+		assert.Len(t, loaded, 1)
+		assert.Equal(t, "Hello", loaded[0].TextBody)
+
+		// update loads full model
+		tester.Request("PATCH", "posts/"+post.Hex()+"?fields[posts]=published", `{
+			"data": {
+				"type": "posts",
+				"id": "`+post.Hex()+`",
+				"attributes": {
+					"published": false
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Len(t, loaded, 1)
+		assert.Equal(t, "Post 1", loaded[0].Title)
+		assert.Equal(t, "Hello", loaded[0].TextBody)
+
+		updated := tester.FindLast(&postModel{}).(*postModel)
+		assert.Equal(t, "Post 1", updated.Title)
+		assert.Equal(t, "Hello", updated.TextBody)
+		assert.False(t, updated.Published)
+	})
+}
+
+func TestProjectionVerifiers(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var verified []*postModel
+		tester.Assign("", &Controller{
+			Model:      &postModel{},
+			Projection: true,
+			Verifiers: L{
+				C("TestProjectionVerifiers", Verifier, All(), func(ctx *Context) error {
+					if ctx.Model != nil {
+						verified = append(verified, ctx.Model.(*postModel))
+					}
+					for _, model := range ctx.Models {
+						verified = append(verified, model.(*postModel))
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title:    "Post 1",
+			TextBody: "Hello",
+		}).ID()
+
+		tester.Request("GET", "posts?fields[posts]=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "posts/"+post.Hex()+"?fields[posts]=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Len(t, verified, 2)
+		for _, model := range verified {
+			assert.Equal(t, "Post 1", model.Title)
+			assert.Equal(t, "Hello", model.TextBody)
+		}
+	})
+}
+
+func TestReadablePropertiesGetter(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Properties: map[string]string{
+				"Virtual":      "virtual",
+				"VirtualError": "virtual-error",
+			},
+			Authorizers: L{
+				C("TestReadablePropertiesGetter", Authorizer, All(), func(ctx *Context) error {
+					ctx.ReadableFields = []string{"Title"}
+					ctx.GetReadableProperties = func(model coal.Model) []string {
+						if model != nil {
+							if model.(*postModel).Title == "post1" {
+								return []string{"Virtual", "VirtualError"}
+							}
+						}
+						return []string{"Virtual"}
+					}
+					return nil
+				}),
+			},
+		})
+
+		// create posts
+		post1 := tester.Insert(&postModel{
+			Title: "post1",
+		}).ID().Hex()
+		post2 := tester.Insert(&postModel{
+			Title: "post2",
 		}).ID().Hex()
 
 		// get posts
@@ -5518,6 +6119,164 @@ func TestReadablePropertiesGetter(t *testing.T) {
 	})
 }
 
+func TestBatchProperties(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, `fire: batch property "title" collides with existing attribute`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				BatchProperties: map[string]BatchProperty{
+					"title": func(ctx *Context, models []coal.Model) (map[coal.ID]interface{}, error) {
+						return nil, nil
+					},
+				},
+			})
+		})
+
+		assert.PanicsWithValue(t, `fire: batch property "virtual" collides with existing attribute`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Properties: map[string]string{
+					"Virtual": "virtual",
+				},
+				BatchProperties: map[string]BatchProperty{
+					"virtual": func(ctx *Context, models []coal.Model) (map[coal.ID]interface{}, error) {
+						return nil, nil
+					},
+				},
+			})
+		})
+
+		assert.PanicsWithValue(t, `fire: batch property "Virtual" collides with existing attribute`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Properties: map[string]string{
+					"Virtual": "virtual",
+				},
+				BatchProperties: map[string]BatchProperty{
+					"Virtual": func(ctx *Context, models []coal.Model) (map[coal.ID]interface{}, error) {
+						return nil, nil
+					},
+				},
+			})
+		})
+
+		var calls [][]coal.ID
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			BatchProperties: map[string]BatchProperty{
+				"comment-count": func(ctx *Context, models []coal.Model) (map[coal.ID]interface{}, error) {
+					// collect IDs
+					ids := make([]coal.ID, 0, len(models))
+					for _, model := range models {
+						ids = append(ids, model.ID())
+					}
+					calls = append(calls, ids)
+
+					// load comments
+					var comments []commentModel
+					err := ctx.Store.M(&commentModel{}).FindAll(ctx, &comments, bson.M{
+						"Post": bson.M{"$in": ids},
+					}, nil, 0, 0, false)
+					if err != nil {
+						return nil, err
+					}
+
+					// count comments
+					values := map[coal.ID]interface{}{}
+					for _, id := range ids {
+						values[id] = 0
+					}
+					for _, comment := range comments {
+						values[comment.Post] = values[comment.Post].(int) + 1
+					}
+
+					return values, nil
+				},
+			},
+			Authorizers: L{
+				C("TestBatchProperties", Authorizer, All(), func(ctx *Context) error {
+					ctx.GetReadableProperties = func(model coal.Model) []string {
+						if model != nil && model.(*postModel).Title == "hidden" {
+							return []string{}
+						}
+						return ctx.ReadableProperties
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post1 := tester.Insert(&postModel{
+			Title: "post-1",
+		}).ID()
+		post2 := tester.Insert(&postModel{
+			Title: "post-2",
+		}).ID()
+		post3 := tester.Insert(&postModel{
+			Title: "hidden",
+		}).ID()
+		for _, post := range []coal.ID{post1, post1, post3} {
+			tester.Insert(&commentModel{
+				Message: "Hello",
+				Post:    post,
+			})
+		}
+
+		// list
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[2, 0]`, gjson.Get(r.Body.String(), `data.#.attributes.comment-count`).Raw)
+			assert.False(t, gjson.Get(r.Body.String(), `data.2.attributes.comment-count`).Exists())
+		})
+
+		assert.Equal(t, [][]coal.ID{{post1, post2}}, calls)
+
+		// find
+		tester.Request("GET", "posts/"+post1.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(2), gjson.Get(r.Body.String(), `data.attributes.comment-count`).Int())
+		})
+
+		assert.Equal(t, [][]coal.ID{{post1, post2}, {post1}}, calls)
+
+		// list with sparse fieldset
+		tester.Request("GET", "posts?fields[posts]=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), `data.0.attributes.comment-count`).Exists())
+		})
+
+		// list with property
+		tester.Request("GET", "posts?fields[posts]=comment-count", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[2, 0]`, gjson.Get(r.Body.String(), `data.#.attributes.comment-count`).Raw)
+		})
+
+		assert.Len(t, calls, 3)
+
+		// update with property
+		tester.Request("PATCH", "posts/"+post2.Hex(), `{
+			"data": {
+				"type": "posts",
+				"id": "`+post2.Hex()+`",
+				"attributes": {
+					"title": "post-2b",
+					"comment-count": 5
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "post-2b", gjson.Get(r.Body.String(), `data.attributes.title`).String())
+			assert.Equal(t, int64(0), gjson.Get(r.Body.String(), `data.attributes.comment-count`).Int())
+		})
+	})
+}
+
 func TestRelationshipFilters(t *testing.T) {
 	// TODO: Support to one relationships?
 	// TODO: Support to many relationships?
@@ -5774,6 +6533,194 @@ func TestRelationshipFilters(t *testing.T) {
 	})
 }
 
+func TestIncludes(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model:     &commentModel{},
+			ListLimit: 1,
+			Authorizers: L{
+				C("TestIncludes", Authorizer, All(), func(ctx *Context) error {
+					ctx.Filters = append(ctx.Filters, bson.M{
+						"Message": bson.M{"$ne": "hidden"},
+					})
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+			Authorizers: L{
+				C("TestIncludes", Authorizer, All(), func(ctx *Context) error {
+					ctx.ReadableFields = []string{"Title"}
+					return nil
+				}),
+			},
+		})
+
+		// create post
+		post := tester.Insert(&postModel{
+			Title: "post",
+		}).ID().Hex()
+
+		// create comments
+		comment1 := tester.Insert(&commentModel{
+			Message: "foo",
+			Post:    coal.MustFromHex(post),
+		}).ID().Hex()
+		comment2 := tester.Insert(&commentModel{
+			Message: "bar",
+			Parent:  stick.P(coal.MustFromHex(comment1)),
+			Post:    coal.MustFromHex(post),
+		}).ID().Hex()
+		tester.Insert(&commentModel{
+			Message: "hidden",
+			Post:    coal.MustFromHex(post),
+		})
+
+		// create note
+		note := tester.Insert(&noteModel{
+			Title: "note",
+			Post:  coal.MustFromHex(post),
+		}).ID().Hex()
+
+		// find post with comments and note
+		tester.Request("GET", "posts/"+post+"?include=comments,note", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[
+				{
+					"type": "comments",
+					"id": "`+comment1+`",
+					"attributes": {
+						"message": "foo"
+					},
+					"relationships": {
+						"parent": {
+							"data": null,
+							"links": {
+								"self": "/comments/`+comment1+`/relationships/parent",
+								"related": "/comments/`+comment1+`/parent"
+							}
+						},
+						"post": {
+							"data": {
+								"type": "posts",
+								"id": "`+post+`"
+							},
+							"links": {
+								"self": "/comments/`+comment1+`/relationships/post",
+								"related": "/comments/`+comment1+`/post"
+							}
+						}
+					}
+				},
+				{
+					"type": "comments",
+					"id": "`+comment2+`",
+					"attributes": {
+						"message": "bar"
+					},
+					"relationships": {
+						"parent": {
+							"data": {
+								"type": "comments",
+								"id": "`+comment1+`"
+							},
+							"links": {
+								"self": "/comments/`+comment2+`/relationships/parent",
+								"related": "/comments/`+comment2+`/parent"
+							}
+						},
+						"post": {
+							"data": {
+								"type": "posts",
+								"id": "`+post+`"
+							},
+							"links": {
+								"self": "/comments/`+comment2+`/relationships/post",
+								"related": "/comments/`+comment2+`/post"
+							}
+						}
+					}
+				},
+				{
+					"type": "notes",
+					"id": "`+note+`",
+					"attributes": {
+						"title": "note"
+					}
+				}
+			]`, gjson.Get(r.Body.String(), "included").Raw, tester.DebugRequest(rq, r))
+		})
+
+		// list comments with nested includes
+		tester.Request("GET", "comments?include=parent,post.note", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, 1, int(gjson.Get(r.Body.String(), "data.#").Int()), tester.DebugRequest(rq, r))
+			var included []string
+			for _, res := range gjson.Get(r.Body.String(), "included").Array() {
+				included = append(included, res.Get("type").String()+"/"+res.Get("id").String())
+			}
+			assert.Equal(t, []string{
+				"notes/" + note,
+				"posts/" + post,
+			}, included, tester.DebugRequest(rq, r))
+		})
+
+		// find comment with nested includes
+		tester.Request("GET", "comments/"+comment2+"?include=parent,post.note", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			var included []string
+			for _, res := range gjson.Get(r.Body.String(), "included").Array() {
+				included = append(included, res.Get("type").String()+"/"+res.Get("id").String())
+			}
+			assert.Equal(t, []string{
+				"comments/" + comment1,
+				"notes/" + note,
+				"posts/" + post,
+			}, included, tester.DebugRequest(rq, r))
+		})
+
+		// find post with sparse fields
+		tester.Request("GET", "posts/"+post+"?include=comments&fields[comments]=message", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `[
+				{
+					"type": "comments",
+					"id": "`+comment1+`",
+					"attributes": {
+						"message": "foo"
+					}
+				},
+				{
+					"type": "comments",
+					"id": "`+comment2+`",
+					"attributes": {
+						"message": "bar"
+					}
+				}
+			]`, gjson.Get(r.Body.String(), "included").Raw, tester.DebugRequest(rq, r))
+		})
+
+		// invalid include path
+		tester.Request("GET", "posts/"+post+"?include=comments.foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid include path \"foo\"",
+					"source": {
+						"parameter": "include"
+					}
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
 func TestDeferredCallbacks(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		tester.Assign("", &Controller{
@@ -6733,6 +7680,237 @@ func TestListLimit(t *testing.T) {
 	})
 }
 
+func TestRelationshipPagination(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			CursorPagination: true,
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create post with comments
+		post := tester.Insert(&postModel{
+			Title: "Post 1",
+		}).ID()
+		var comments []string
+		for i := 0; i < 3; i++ {
+			comments = append(comments, tester.Insert(&commentModel{
+				Message: fmt.Sprintf("Comment %d", i+1),
+				Post:    post,
+			}).ID().Hex())
+		}
+
+		// get first page of comments relationship
+		tester.Request("GET", "posts/"+post.Hex()+"/relationships/comments?page[number]=1&page[size]=2", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": [
+					{
+						"type": "comments",
+						"id": "`+comments[0]+`"
+					},
+					{
+						"type": "comments",
+						"id": "`+comments[1]+`"
+					}
+				],
+				"links": {
+					"self": "/posts/`+post.Hex()+`/relationships/comments?page[number]=1&page[size]=2",
+					"related": "/posts/`+post.Hex()+`/comments",
+					"first": "/posts/`+post.Hex()+`/relationships/comments?page[number]=1&page[size]=2",
+					"last": "/posts/`+post.Hex()+`/relationships/comments?page[number]=2&page[size]=2",
+					"next": "/posts/`+post.Hex()+`/relationships/comments?page[number]=2&page[size]=2"
+				}
+			}`, linkUnescape(r.Body.String()), tester.DebugRequest(rq, r))
+		})
+
+		// get second page of related comments
+		tester.Request("GET", "posts/"+post.Hex()+"/comments?page[number]=2&page[size]=2", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			list := gjson.Get(r.Body.String(), "data").Array()
+			links := gjson.Get(r.Body.String(), "links").Raw
+
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, 1, len(list), tester.DebugRequest(rq, r))
+			assert.Equal(t, comments[2], list[0].Get("id").String(), tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"self": "/posts/`+post.Hex()+`/comments?page[number]=2&page[size]=2",
+				"first": "/posts/`+post.Hex()+`/comments?page[number]=1&page[size]=2",
+				"last": "/posts/`+post.Hex()+`/comments?page[number]=2&page[size]=2",
+				"prev": "/posts/`+post.Hex()+`/comments?page[number]=1&page[size]=2"
+			}`, linkUnescape(links))
+		})
+
+		// create posts and selection
+		var posts []coal.ID
+		for i := 0; i < 3; i++ {
+			posts = append(posts, tester.Insert(&postModel{
+				Title: fmt.Sprintf("Post %d", i+2),
+			}).ID())
+		}
+		selection := tester.Insert(&selectionModel{
+			Posts: posts,
+		}).ID()
+
+		// get first page of posts relationship
+		var next string
+		tester.Request("GET", "selections/"+selection.Hex()+"/relationships/posts?page[size]=2", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{posts[0].Hex(), posts[1].Hex()}, gjson.Get(r.Body.String(), "data.#.id").Value(), tester.DebugRequest(rq, r))
+			assert.Equal(t, "/selections/"+selection.Hex()+"/posts", gjson.Get(r.Body.String(), "links.related").String(), tester.DebugRequest(rq, r))
+			next = gjson.Get(r.Body.String(), "links.next").String()
+			assert.True(t, strings.HasPrefix(next, "/selections/"+selection.Hex()+"/relationships/posts?"), next)
+		})
+
+		// get second page of posts relationship
+		tester.Request("GET", strings.TrimPrefix(next, "/"), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{posts[2].Hex()}, gjson.Get(r.Body.String(), "data.#.id").Value(), tester.DebugRequest(rq, r))
+		})
+
+		// get unpaginated posts relationship
+		tester.Request("GET", "selections/"+selection.Hex()+"/relationships/posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Len(t, gjson.Get(r.Body.String(), "data").Array(), 3, tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "links.next").Exists(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestRelationshipCounts(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, `fire: invalid relationship count field "Note"`, func() {
+			tester.Assign("", &Controller{
+				Model:              &postModel{},
+				RelationshipCounts: []string{"Note"},
+			})
+		})
+
+		tester.Assign("", &Controller{
+			Model:              &postModel{},
+			SoftDelete:         true,
+			RelationshipCounts: []string{"Comments"},
+			Authorizers: L{
+				C("TestRelationshipCounts", Authorizer, All(), func(ctx *Context) error {
+					ctx.RelationshipFilters = map[string][]bson.M{
+						"Comments": {
+							{"Message": bson.M{"$ne": "Hidden"}},
+						},
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model:      &commentModel{},
+			SoftDelete: true,
+		}, &Controller{
+			Model:              &selectionModel{},
+			RelationshipCounts: []string{"Posts"},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create post with comments
+		post := tester.Insert(&postModel{
+			Title: "Post 1",
+		}).ID()
+		var comments []string
+		for i := 0; i < 2; i++ {
+			comments = append(comments, tester.Insert(&commentModel{
+				Message: fmt.Sprintf("Comment %d", i+1),
+				Post:    post,
+			}).ID().Hex())
+		}
+		tester.Insert(&commentModel{
+			Message: "Deleted",
+			Post:    post,
+			Deleted: stick.P(time.Now()),
+		})
+		tester.Insert(&commentModel{
+			Message: "Hidden",
+			Post:    post,
+		})
+
+		// create deleted post
+		deleted := tester.Insert(&postModel{
+			Title:   "Post 2",
+			Deleted: stick.P(time.Now()),
+		}).ID()
+
+		// create selection
+		selection := tester.Insert(&selectionModel{
+			Posts: []coal.ID{post, deleted},
+		}).ID()
+
+		// get post
+		tester.Request("GET", "posts/"+post.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"count": 2
+			}`, gjson.Get(r.Body.String(), "data.relationships.comments.meta").Raw, tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "data.relationships.comments.data").Exists(), tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "data.relationships.selections.meta").Exists(), tester.DebugRequest(rq, r))
+		})
+
+		// list posts
+		other := tester.Insert(&postModel{
+			Title: "Post 3",
+		}).ID()
+		tester.Insert(&commentModel{
+			Message: "Comment 3",
+			Post:    other,
+		})
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{2.0, 1.0}, gjson.Get(r.Body.String(), "data.#.relationships.comments.meta.count").Value(), tester.DebugRequest(rq, r))
+		})
+		tester.Delete(&postModel{Base: coal.B(other)})
+
+		// get post with included comments
+		tester.Request("GET", "posts/"+post.Hex()+"?include=comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(2), gjson.Get(r.Body.String(), "data.relationships.comments.meta.count").Int(), tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{comments[0], comments[1]}, gjson.Get(r.Body.String(), "data.relationships.comments.data.#.id").Value(), tester.DebugRequest(rq, r))
+			assert.Len(t, gjson.Get(r.Body.String(), "included").Array(), 2, tester.DebugRequest(rq, r))
+		})
+
+		// get post comments relationship
+		tester.Request("GET", "posts/"+post.Hex()+"/relationships/comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(2), gjson.Get(r.Body.String(), "meta.count").Int(), tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{comments[0], comments[1]}, gjson.Get(r.Body.String(), "data.#.id").Value(), tester.DebugRequest(rq, r))
+		})
+
+		// list selections
+		tester.Request("GET", "selections", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"count": 1
+			}`, gjson.Get(r.Body.String(), "data.0.relationships.posts.meta").Raw, tester.DebugRequest(rq, r))
+			assert.Len(t, gjson.Get(r.Body.String(), "data.0.relationships.posts.data").Array(), 2, tester.DebugRequest(rq, r))
+		})
+
+		// list selections with nested included comments
+		tester.Request("GET", "selections?include=posts.comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{"comments", "comments", "posts"}, gjson.Get(r.Body.String(), "included.#.type").Value(), tester.DebugRequest(rq, r))
+			assert.Equal(t, []interface{}{comments[0], comments[1]}, gjson.Get(r.Body.String(), "included.2.relationships.comments.data.#.id").Value(), tester.DebugRequest(rq, r))
+		})
+
+		// get selection posts relationship
+		tester.Request("GET", "selections/"+selection.Hex()+"/relationships/posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"count": 1
+			}`, gjson.Get(r.Body.String(), "meta").Raw, tester.DebugRequest(rq, r))
+		})
+	})
+}
+
 func TestCollectionActions(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		assert.PanicsWithValue(t, `fire: invalid collection action ""`, func() {
@@ -7412,6 +8590,202 @@ func TestConsistentUpdate(t *testing.T) {
 	})
 }
 
+func TestConditionalRequests(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			ConsistentUpdate: true,
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "foo",
+		}).(*postModel)
+
+		// get post
+		var etag string
+		tester.Request("GET", "posts/"+post.ID().Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			etag = r.Header().Get("ETag")
+			assert.Regexp(t, `^W/"[0-9a-f]{40}"$`, etag)
+		})
+
+		// get unmodified post
+		tester.Header["If-None-Match"] = etag
+		tester.Request("GET", "posts/"+post.ID().Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotModified, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, etag, r.Header().Get("ETag"))
+			assert.Empty(t, r.Body.String())
+		})
+
+		// get posts
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.NotEqual(t, etag, r.Header().Get("ETag"))
+			assert.NotEmpty(t, r.Header().Get("ETag"))
+		})
+
+		// modify post
+		post.Title = "bar"
+		tester.Replace(post)
+
+		// get modified post
+		tester.Request("GET", "posts/"+post.ID().Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.NotEqual(t, etag, r.Header().Get("ETag"))
+		})
+
+		delete(tester.Header, "If-None-Match")
+
+		// create selection
+		var selection string
+		tester.Request("POST", "selections", `{
+			"data": {
+				"type": "selections",
+				"attributes": {
+					"name": "foo"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			selection = gjson.Get(r.Body.String(), "data.id").String()
+			token := gjson.Get(r.Body.String(), "data.attributes.update-token").String()
+			etag = `"` + token + `"`
+			assert.Equal(t, etag, r.Header().Get("ETag"))
+		})
+
+		// get selection
+		var digest string
+		tester.Request("GET", "selections/"+selection, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			digest = r.Header().Get("ETag")
+			assert.Regexp(t, `^W/"[0-9a-f]{40}"$`, digest)
+		})
+
+		// get unmodified selection
+		tester.Header["If-None-Match"] = digest
+		tester.Request("GET", "selections/"+selection, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotModified, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// get selection with different fields
+		tester.Request("GET", "selections/"+selection+"?fields[selections]=name", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.NotEqual(t, digest, r.Header().Get("ETag"))
+		})
+
+		// get selection with token
+		tester.Header["If-None-Match"] = etag
+		tester.Request("GET", "selections/"+selection, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		delete(tester.Header, "If-None-Match")
+
+		// update with stale precondition
+		tester.Header["If-Match"] = `"foo"`
+		tester.Request("PATCH", "selections/"+selection, `{
+			"data": {
+				"type": "selections",
+				"id": "`+selection+`",
+				"attributes": {
+					"name": "bar"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusPreconditionFailed, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "412",
+					"title": "precondition failed",
+					"detail": "precondition failed"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// update with weak precondition
+		tester.Header["If-Match"] = "W/" + etag
+		tester.Request("PATCH", "selections/"+selection, `{
+			"data": {
+				"type": "selections",
+				"id": "`+selection+`",
+				"attributes": {
+					"name": "bar"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusPreconditionFailed, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// update with precondition
+		tester.Header["If-Match"] = etag
+		tester.Request("PATCH", "selections/"+selection, `{
+			"data": {
+				"type": "selections",
+				"id": "`+selection+`",
+				"attributes": {
+					"name": "bar"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "bar", gjson.Get(r.Body.String(), "data.attributes.name").String())
+			assert.NotEqual(t, etag, r.Header().Get("ETag"))
+		})
+
+		// delete with stale precondition
+		tester.Request("DELETE", "selections/"+selection, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusPreconditionFailed, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// delete with precondition
+		tester.Header["If-Match"] = `"` + tester.Fetch(&selectionModel{}, coal.MustFromHex(selection)).(*selectionModel).UpdateToken + `"`
+		tester.Request("DELETE", "selections/"+selection, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		delete(tester.Header, "If-Match")
+	})
+}
+
+func TestConditionalRequestsUnreadableToken(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			ConsistentUpdate: true,
+			Authorizers: L{
+				C("TestConditionalRequestsUnreadableToken", Authorizer, All(), func(ctx *Context) error {
+					ctx.ReadableFields = stick.Subtract(ctx.ReadableFields, []string{"UpdateToken"})
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Request("POST", "selections", `{
+			"data": {
+				"type": "selections",
+				"attributes": {
+					"name": "foo"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "data.attributes.update-token").Exists())
+			assert.Empty(t, r.Header().Get("ETag"))
+		})
+	})
+}
+
 func TestTransactions(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		group := tester.Assign("", &Controller{
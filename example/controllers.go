@@ -1,7 +1,10 @@
 package main
 
 import (
+	"time"
+
 	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/ash"
 	"github.com/256dpi/fire/axe"
 	"github.com/256dpi/fire/blaze"
 	"github.com/256dpi/fire/coal"
@@ -9,12 +12,19 @@ import (
 	"github.com/256dpi/fire/glut"
 )
 
-func itemController(store *coal.Store, queue *axe.Queue, bucket *blaze.Bucket) *fire.Controller {
+func itemController(store, limiterStore *coal.Store, queue *axe.Queue, bucket *blaze.Bucket) *fire.Controller {
 	return &fire.Controller{
 		Model: &Item{},
 		Store: store,
 		Authorizers: fire.L{
 			flame.Callback(true),
+			(&ash.Limiter{
+				Store: limiterStore,
+				Write: &ash.Limit{
+					Burst:    20,
+					Interval: time.Second,
+				},
+			}).Callback(),
 		},
 		Modifiers: fire.L{
 			bucket.Modifier(),
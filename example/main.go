@@ -181,11 +181,18 @@ func createHandler(store *coal.Store) http.Handler {
 	// qun queue
 	queue.Run()
 
+	// create limiter store, lungo does not support writes outside open
+	// transactions
+	limiterStore := store
+	if store.Lungo() {
+		limiterStore = coal.MustOpen(nil, "example-limiter", xo.Capture)
+	}
+
 	// create group
 	g := fire.NewGroup(xo.Capture)
 
 	// add controllers
-	g.Add(itemController(store, queue, bucket))
+	g.Add(itemController(store, limiterStore, queue, bucket))
 	g.Add(userController(store))
 	g.Add(jobController(store))
 	g.Add(valueController(store))
@@ -0,0 +1,377 @@
+package fire
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// The available export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// ExportBatchSize defines the number of documents that are verified, decorated
+// and written together during an export.
+var ExportBatchSize = 100
+
+// ExportAction returns a collection action that exports all resources matching
+// the list request in one of the provided formats. The format is selected
+// using the "format" query parameter and defaults to the first provided format.
+// The action supports the same filters, sorters, sparse fieldsets and search
+// parameters as the list operation and runs the authorizers, verifiers and
+// decorators as for a list operation. The documents are streamed from the
+// database without loading them into memory and without applying any
+// pagination limits. Resources are transformed like list responses if an
+// older API version is requested.
+//
+// CSV exports include the ID, the readable attributes, to-one and to-many
+// relationships and properties as columns. Values that would be interpreted as
+// formulas by spreadsheet applications are prefixed with a single quote.
+// NDJSON exports write one JSON:API resource object per line.
+//
+// Note: Errors that occur after the response has been started cannot be
+// reported properly and will truncate the export.
+func ExportAction(timeout time.Duration, formats ...string) *Action {
+	// check formats
+	if len(formats) == 0 {
+		panic("fire: missing export formats")
+	}
+	for _, format := range formats {
+		if format != ExportCSV && format != ExportNDJSON {
+			panic(`fire: unknown export format "` + format + `"`)
+		}
+	}
+
+	return A("fire/ExportAction", []string{"GET"}, 0, timeout, func(ctx *Context) error {
+		// get controller
+		c := ctx.Controller
+
+		// get format
+		format := formats[0]
+		if value := ctx.HTTPRequest.URL.Query().Get("format"); value != "" {
+			if !stick.Contains(formats, value) {
+				return jsonapi.BadRequestParam("unsupported format", "format")
+			}
+			format = value
+		}
+
+		// prepare list request
+		listRequest := ctx.HTTPRequest.Clone(ctx)
+		listRequest.Method = "GET"
+		listRequest.URL.Path = "/" + strings.Trim(ctx.JSONAPIRequest.Prefix+"/"+c.meta.PluralName, "/")
+		listRequest.Header.Del("Accept")
+		listRequest.Header.Del("Content-Type")
+
+		// parse list request
+		req, err := jsonapi.ParseRequest(listRequest, ctx.JSONAPIRequest.Prefix)
+		if err != nil {
+			return err
+		}
+
+		// check pagination
+		if req.PageSize > 0 || req.PageLimit > 0 || req.PageBefore != "" || req.PageAfter != "" || req.Pagination != "" {
+			return jsonapi.BadRequest("pagination not supported")
+		}
+
+		// prepare context
+		listCtx := &Context{
+			Context:        ctx,
+			Data:           stick.Map{},
+			Operation:      List,
+			HTTPRequest:    ctx.HTTPRequest,
+			ResponseWriter: ctx.ResponseWriter,
+			Controller:     c,
+			Group:          ctx.Group,
+			APIVersion:     ctx.APIVersion,
+			Tracer:         ctx.Tracer,
+			JSONAPIRequest: req,
+			tenant:         ctx.tenant,
+		}
+		c.prepareContext(listCtx, nil)
+
+		// prepare query in transaction
+		var q listQuery
+		xo.AbortIf(c.Store.T(ctx.Context, true, func(tc context.Context) error {
+			return listCtx.With(tc, func() error {
+				q = c.prepareQuery(listCtx, false)
+				return nil
+			})
+		}))
+
+		// check related sorters
+		if len(q.relatedSorters) > 0 {
+			return jsonapi.BadRequest("related sorters not supported")
+		}
+
+		// find documents
+		iter, err := listCtx.Store.M(c.Model).FindEach(listCtx, q.filter, q.sorting, 0, 0, false, q.flags, coal.NoTransaction)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		// prepare writer
+		var writer exportWriter
+		switch format {
+		case ExportCSV:
+			writer = c.csvExportWriter(listCtx)
+		case ExportNDJSON:
+			writer = c.ndjsonExportWriter(listCtx)
+		}
+
+		// write header
+		ctx.ResponseWriter.Header().Set("Content-Type", writer.contentType)
+		ctx.ResponseWriter.Header().Set("Content-Disposition", `attachment; filename="`+c.meta.PluralName+"."+format+`"`)
+		ctx.ResponseWriter.WriteHeader(http.StatusOK)
+
+		// prepare batch
+		batch := make([]coal.Model, 0, ExportBatchSize)
+
+		// prepare flush
+		flush := func() {
+			// set models
+			listCtx.Models = batch
+
+			// run verifiers and decorators
+			c.runCallbacks(listCtx, Verifier, c.Verifiers, http.StatusUnauthorized)
+			c.runCallbacks(listCtx, Decorator, c.Decorators, http.StatusInternalServerError)
+
+			// prepare resources
+			resources := c.resourcesForModels(listCtx, batch, nil)
+
+			// transform resources if an older API version is requested
+			c.transformResponse(listCtx, &jsonapi.Document{
+				Data: &jsonapi.HybridResource{
+					Many: resources,
+				},
+			})
+
+			// write resources
+			for _, res := range resources {
+				xo.AbortIf(writer.write(res))
+			}
+			xo.AbortIf(writer.flush())
+
+			// flush response
+			if flusher, ok := ctx.ResponseWriter.(http.Flusher); ok {
+				flusher.Flush()
+			}
+
+			// reset batch
+			batch = make([]coal.Model, 0, ExportBatchSize)
+		}
+
+		// stream documents
+		for iter.Next() {
+			// decode model
+			model := c.meta.Make()
+			err = iter.Decode(model)
+			if err != nil {
+				return err
+			}
+
+			// add model
+			batch = append(batch, model)
+			if len(batch) >= ExportBatchSize {
+				flush()
+			}
+		}
+		if len(batch) > 0 {
+			flush()
+		}
+		xo.AbortIf(writer.flush())
+
+		// check error
+		err = iter.Error()
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+type exportWriter struct {
+	contentType string
+	write       func(*jsonapi.Resource) error
+	flush       func() error
+}
+
+func (c *Controller) csvExportWriter(ctx *Context) exportWriter {
+	// prepare columns
+	columns := []string{"id"}
+
+	// add readable attributes and relationships
+	readableFields := c.readableFields(ctx, nil)
+	for _, field := range c.meta.OrderedFields {
+		if !stick.Contains(readableFields, field.Name) {
+			continue
+		}
+		if field.JSONKey != "" {
+			columns = append(columns, field.JSONKey)
+		} else if field.ToOne || field.ToMany {
+			columns = append(columns, field.RelName)
+		}
+	}
+
+	// add readable properties
+	readableProperties := c.readableProperties(ctx, nil)
+	properties := make([]string, 0, len(c.Properties)+len(c.BatchProperties))
+	for name, key := range c.Properties {
+		if stick.Contains(readableProperties, name) {
+			properties = append(properties, key)
+		}
+	}
+	for key := range c.BatchProperties {
+		if stick.Contains(readableProperties, key) {
+			properties = append(properties, key)
+		}
+	}
+	sort.Strings(properties)
+	columns = append(columns, properties...)
+
+	// transform columns if an older API version is requested
+	if ctx.Group.legacyAPIVersion(ctx.APIVersion) {
+		// prepare template
+		template := &jsonapi.Resource{
+			Type:          c.meta.PluralName,
+			Attributes:    jsonapi.Map{},
+			Relationships: map[string]*jsonapi.Document{},
+		}
+		for _, column := range columns[1:] {
+			if field := c.meta.Relationships[column]; field != nil {
+				template.Relationships[column] = &jsonapi.Document{}
+			} else {
+				template.Attributes[column] = nil
+			}
+		}
+
+		// transform template
+		c.transformResponse(ctx, &jsonapi.Document{
+			Data: &jsonapi.HybridResource{
+				One: template,
+			},
+		})
+
+		// keep remaining columns
+		transformed := []string{"id"}
+		for _, column := range columns[1:] {
+			if _, ok := template.Attributes[column]; ok {
+				transformed = append(transformed, column)
+				delete(template.Attributes, column)
+			} else if template.Relationships[column] != nil {
+				transformed = append(transformed, column)
+			}
+		}
+
+		// add new columns
+		added := make([]string, 0, len(template.Attributes))
+		for key := range template.Attributes {
+			added = append(added, key)
+		}
+		sort.Strings(added)
+		columns = append(transformed, added...)
+	}
+
+	// prepare writer
+	writer := csv.NewWriter(ctx.ResponseWriter)
+
+	// write header
+	xo.AbortIf(writer.Write(columns))
+
+	return exportWriter{
+		contentType: "text/csv; charset=utf-8",
+		write: func(res *jsonapi.Resource) error {
+			// prepare record
+			record := make([]string, len(columns))
+			record[0] = res.ID
+			for i, column := range columns[1:] {
+				if value, ok := res.Attributes[column]; ok {
+					record[i+1] = exportValue(value)
+				} else if rel := res.Relationships[column]; rel != nil && rel.Data != nil {
+					if rel.Data.One != nil {
+						record[i+1] = rel.Data.One.ID
+					} else if rel.Data.Many != nil {
+						ids := make([]string, 0, len(rel.Data.Many))
+						for _, ref := range rel.Data.Many {
+							ids = append(ids, ref.ID)
+						}
+						record[i+1] = strings.Join(ids, ",")
+					}
+				}
+			}
+
+			return writer.Write(record)
+		},
+		flush: func() error {
+			writer.Flush()
+			return writer.Error()
+		},
+	}
+}
+
+func (c *Controller) ndjsonExportWriter(ctx *Context) exportWriter {
+	// prepare encoder
+	encoder := json.NewEncoder(ctx.ResponseWriter)
+
+	return exportWriter{
+		contentType: "application/x-ndjson",
+		write: func(res *jsonapi.Resource) error {
+			// remove unloaded relationships
+			for _, field := range c.meta.Relationships {
+				if field.HasOne || field.HasMany {
+					delete(res.Relationships, field.RelName)
+				}
+			}
+
+			return encoder.Encode(res)
+		},
+		flush: func() error {
+			return nil
+		},
+	}
+}
+
+func exportValue(value interface{}) string {
+	// handle basic values
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return exportString(value)
+	}
+
+	// otherwise, encode value
+	buf, err := json.Marshal(value)
+	xo.AbortIf(err)
+
+	// unquote strings
+	var str string
+	if json.Unmarshal(buf, &str) == nil {
+		return exportString(str)
+	}
+
+	return string(buf)
+}
+
+func exportString(str string) string {
+	// prefix strings that spreadsheet applications would interpret as a
+	// formula to prevent CSV injection
+	if str != "" && strings.ContainsRune("=+-@\t\r", rune(str[0])) {
+		return "'" + str
+	}
+
+	return str
+}
@@ -0,0 +1,166 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/256dpi/serve"
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestExportAction(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, "fire: missing export formats", func() {
+			ExportAction(0)
+		})
+
+		assert.PanicsWithValue(t, `fire: unknown export format "xml"`, func() {
+			ExportAction(0, "xml")
+		})
+
+		tester.Assign("", &Controller{
+			Model:     &postModel{},
+			Filters:   []string{"Published"},
+			Sorters:   []string{"Title"},
+			ListLimit: 1,
+			Properties: map[string]string{
+				"Virtual": "virtual",
+			},
+			Authorizers: L{
+				C("TestAuthorizer", Authorizer, Only(List), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Denied") != "" {
+						return ErrAccessDenied.Wrap()
+					}
+					return nil
+				}),
+			},
+			CollectionActions: M{
+				"export": ExportAction(0, ExportCSV, ExportNDJSON),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post1 := tester.Insert(&postModel{
+			Title:     "Post 1",
+			Published: true,
+			TextBody:  "Hello, World!",
+		}).ID().Hex()
+		post2 := tester.Insert(&postModel{
+			Title:    "Post 2",
+			TextBody: "=HYPERLINK(\"http://example.com\")",
+		}).ID().Hex()
+		post3 := tester.Insert(&postModel{
+			Title:     "Post 3",
+			Published: true,
+		}).ID().Hex()
+
+		// export csv
+		tester.Request("GET", "posts/export", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "text/csv; charset=utf-8", r.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename="posts.csv"`, r.Header().Get("Content-Disposition"))
+			assert.Equal(t, strings.Join([]string{
+				"id,title,published,text-body,virtual",
+				post1 + `,Post 1,true,"Hello, World!",42`,
+				post2 + `,Post 2,false,"'=HYPERLINK(""http://example.com"")",42`,
+				post3 + ",Post 3,true,,42",
+			}, "\n")+"\n", r.Body.String())
+		})
+
+		// export ndjson with filter, sorting and fields
+		tester.Request("GET", "posts/export?format=ndjson&filter[published]=true&sort=-title&fields[posts]=title,published", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "application/x-ndjson", r.Header().Get("Content-Type"))
+
+			lines := strings.Split(strings.TrimSpace(r.Body.String()), "\n")
+			assert.Len(t, lines, 2)
+			assert.Equal(t, post3, gjson.Get(lines[0], "id").String())
+			assert.JSONEq(t, `{"title": "Post 3", "published": true}`, gjson.Get(lines[0], "attributes").Raw)
+			assert.Equal(t, post1, gjson.Get(lines[1], "id").String())
+			assert.JSONEq(t, `{"title": "Post 1", "published": true}`, gjson.Get(lines[1], "attributes").Raw)
+			assert.False(t, gjson.Get(lines[1], "relationships.comments").Exists())
+		})
+
+		// invalid filter
+		tester.Request("GET", "posts/export?filter[title]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// unsupported format
+		tester.Request("GET", "posts/export?format=xml", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "unsupported format", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// pagination
+		tester.Request("GET", "posts/export?page[size]=1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "pagination not supported", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// export csv with filter, sorting and fields
+		tester.Request("GET", "posts/export?format=csv&filter[published]=true&sort=title&fields[posts]=title,published", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "id,title,published\n"+post1+",Post 1,true\n"+post3+",Post 3,true\n", r.Body.String())
+		})
+
+		// access denied
+		tester.Header["Denied"] = "true"
+		tester.Request("GET", "posts/export", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnauthorized, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestExportActionTransformers(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Model: &postModel{},
+			Store: tester.Store,
+			Transformers: []*Transformer{
+				RenameAttribute("v1", "name", "title"),
+				RemoveAttribute("v1", "summary", "none"),
+			},
+			CollectionActions: M{
+				"export": ExportAction(0, ExportCSV, ExportNDJSON),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &selectionModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &noteModel{},
+			Store: tester.Store,
+		})
+		group.APIVersions("v1", "v2")
+		tester.Handler = serve.Compose(xo.RootHandler(), group.Endpoint(""))
+
+		post := tester.Insert(&postModel{
+			Title: "Hello",
+		}).ID().Hex()
+
+		// export csv
+		tester.Request("GET", "v1/posts/export?fields[posts]=title,published", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "id,published,name,summary\n"+post+",false,Hello,none\n", r.Body.String())
+		})
+
+		// export ndjson
+		tester.Request("GET", "v1/posts/export?format=ndjson&fields[posts]=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{"name": "Hello", "summary": "none"}`, gjson.Get(r.Body.String(), "attributes").Raw)
+		})
+	})
+}
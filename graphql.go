@@ -0,0 +1,1355 @@
+package fire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/serve"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+var graphQLListArguments = []string{"filter", "sort", "page", "size"}
+
+type graphQLSchema struct {
+	types     map[string]*graphQLType
+	resources map[string]*graphQLType
+	query     map[string]*graphQLRoot
+	mutation  map[string]*graphQLRoot
+}
+
+type graphQLType struct {
+	name       string
+	controller *Controller
+	fields     map[string]*graphQLField
+	names      []string
+	input      []*graphQLField
+}
+
+type graphQLField struct {
+	name     string
+	key      string
+	typ      string
+	input    string
+	relation *coal.Field
+	related  string
+}
+
+type graphQLRoot struct {
+	typ       *graphQLType
+	intent    jsonapi.Intent
+	arguments []string
+	result    string
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data   *graphQLObject `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+type graphQLError struct {
+	Message    string        `json:"message"`
+	Path       []interface{} `json:"path,omitempty"`
+	Extensions stick.Map     `json:"extensions,omitempty"`
+}
+
+type graphQLObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *graphQLObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *graphQLObject) MarshalJSON() ([]byte, error) {
+	// write fields in selection order
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// GraphQL will return a handler that serves GraphQL queries and mutations for
+// the controllers of the group. Every model is exposed as an object type named
+// after the model struct with its attributes, properties and relationships as
+// fields. Find queries are named after the type e.g. "post" and list queries
+// after the camel-cased plural name e.g. "posts", while the create, update and
+// delete mutations are prefixed accordingly e.g. "createPost". List queries and
+// to-many relationships accept the "filter", "sort", "page" and "size"
+// arguments which are passed to the controllers as the JSON:API query
+// parameters. Every field is resolved by running a regular request through the
+// responsible controller. Therefore, all callbacks, field whitelists and
+// properties apply unchanged. Relationships without arguments are loaded with
+// a single request per field for all resources of a list if the related
+// controller has no list limit. Queries may be sent using GET and POST
+// requests while mutations require a POST request.
+//
+// The max depth limits the nesting of selections and the max complexity limits
+// the total number of selected fields. Queries exceeding the limits are
+// rejected. Zero values disable the limits.
+//
+// Note: The handler must be created after all controllers have been added.
+// Introspection is not supported, the schema can be obtained using
+// GraphQLSchema. Batched relationship requests do not set Context.Parent.
+// Queries always use the current API version of the group, requests selecting
+// another version using the "API-Version" header are rejected.
+func (g *Group) GraphQL(maxDepth, maxComplexity int) http.Handler {
+	// build schema
+	schema := g.graphQLSchema()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// create tracer
+		tracer, tc := xo.CreateTracer(r.Context(), "fire/Group.GraphQL")
+		defer tracer.End()
+		r = r.WithContext(tc)
+
+		// recover any panic
+		defer xo.Recover(func(err error) {
+			// record error
+			tracer.Record(err)
+
+			// report error if possible
+			if g.reporter != nil {
+				g.reporter(err)
+			}
+
+			// write internal server error
+			writeGraphQL(w, http.StatusInternalServerError, &graphQLResponse{
+				Errors: []graphQLError{{Message: "internal server error"}},
+			})
+		})
+
+		// check API version
+		var version string
+		if len(g.apiVersions) > 0 {
+			version = g.apiVersions[len(g.apiVersions)-1]
+			if header := r.Header.Get(APIVersionHeader); header != "" && header != version {
+				writeGraphQLError(w, http.StatusBadRequest, "unsupported API version")
+				return
+			}
+		}
+
+		// decode request
+		var req graphQLRequest
+		switch r.Method {
+		case "GET":
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if vars := r.URL.Query().Get("variables"); vars != "" {
+				dec := json.NewDecoder(strings.NewReader(vars))
+				dec.UseNumber()
+				if dec.Decode(&req.Variables) != nil {
+					writeGraphQLError(w, http.StatusBadRequest, "invalid variables")
+					return
+				}
+			}
+		case "POST":
+			serve.LimitBody(w, r, serve.MustByteSize("8M"))
+			dec := json.NewDecoder(r.Body)
+			dec.UseNumber()
+			if dec.Decode(&req) != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "invalid request")
+				return
+			}
+		default:
+			writeGraphQLError(w, http.StatusMethodNotAllowed, "unsupported method")
+			return
+		}
+
+		// parse query
+		doc, err := parseGraphQL(req.Query)
+		if err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// select operation
+		var op *graphQLOperation
+		for _, o := range doc.operations {
+			if req.OperationName == "" && len(doc.operations) == 1 || o.name == req.OperationName {
+				op = o
+			}
+		}
+		if op == nil && req.OperationName == "" {
+			writeGraphQLError(w, http.StatusBadRequest, "missing operation name")
+			return
+		} else if op == nil {
+			writeGraphQLError(w, http.StatusBadRequest, "unknown operation")
+			return
+		}
+
+		// check operation
+		if op.typ == "subscription" {
+			writeGraphQLError(w, http.StatusBadRequest, "subscriptions are not supported")
+			return
+		} else if op.typ == "mutation" && r.Method != "POST" {
+			writeGraphQLError(w, http.StatusMethodNotAllowed, "mutations require a POST request")
+			return
+		}
+
+		// prepare variables
+		variables := map[string]interface{}{}
+		for _, v := range op.variables {
+			if value, ok := req.Variables[v.name]; ok {
+				variables[v.name] = value
+			} else if v.hasDefault {
+				variables[v.name] = v.value
+			}
+		}
+
+		// prepare executor
+		exec := &graphQLExecutor{
+			ctx: &Context{
+				Context:        r.Context(),
+				Data:           stick.Map{},
+				HTTPRequest:    r,
+				ResponseWriter: w,
+				Group:          g,
+				APIVersion:     version,
+				Tracer:         tracer,
+			},
+			schema:    schema,
+			doc:       doc,
+			variables: variables,
+		}
+
+		// check limits
+		depth, complexity := exec.measure(exec.rootName(op), op.selection, 1)
+		if maxDepth > 0 && depth > maxDepth {
+			writeGraphQLError(w, http.StatusBadRequest, "query exceeds maximum depth")
+			return
+		} else if maxComplexity > 0 && complexity > maxComplexity {
+			writeGraphQLError(w, http.StatusBadRequest, "query exceeds maximum complexity")
+			return
+		}
+
+		// execute operation
+		data := exec.execute(op)
+
+		// write response
+		writeGraphQL(w, http.StatusOK, &graphQLResponse{
+			Data:   data,
+			Errors: exec.errors,
+		})
+	})
+}
+
+// GraphQLSchema will return the schema of the handler returned by GraphQL in
+// the GraphQL schema definition language.
+func (g *Group) GraphQLSchema() string {
+	// build schema
+	schema := g.graphQLSchema()
+
+	// prepare builder
+	var b strings.Builder
+	b.WriteString("scalar Time\n\nscalar JSON\n")
+
+	// write types
+	for _, name := range sortedKeys(schema.types) {
+		typ := schema.types[name]
+
+		// write object type
+		b.WriteString("\ntype " + typ.name + " {\n")
+		for _, fieldName := range typ.names {
+			field := typ.fields[fieldName]
+			b.WriteString("  " + field.name)
+			if field.relation != nil && (field.relation.ToMany || field.relation.HasMany) {
+				b.WriteString(graphQLArgumentsSchema(graphQLListArguments, nil))
+			}
+			b.WriteString(": " + field.typ + "\n")
+		}
+		b.WriteString("}\n")
+
+		// write input type
+		if len(typ.input) > 0 {
+			b.WriteString("\ninput " + typ.name + "Input {\n")
+			for _, field := range typ.input {
+				b.WriteString("  " + field.name + ": " + field.input + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	// write root types
+	for _, root := range []struct {
+		name   string
+		fields map[string]*graphQLRoot
+	}{
+		{name: "Query", fields: schema.query},
+		{name: "Mutation", fields: schema.mutation},
+	} {
+		if len(root.fields) == 0 {
+			continue
+		}
+		b.WriteString("\ntype " + root.name + " {\n")
+		for _, name := range sortedKeys(root.fields) {
+			field := root.fields[name]
+			b.WriteString("  " + name + graphQLArgumentsSchema(field.arguments, field.typ) + ": " + field.result + "\n")
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.String()
+}
+
+func (g *Group) graphQLSchema() *graphQLSchema {
+	// prepare schema
+	schema := &graphQLSchema{
+		types:     map[string]*graphQLType{},
+		resources: map[string]*graphQLType{},
+		query:     map[string]*graphQLRoot{},
+		mutation:  map[string]*graphQLRoot{},
+	}
+
+	// add types
+	for _, name := range sortedKeys(g.controllers) {
+		controller := g.controllers[name]
+
+		// get type name
+		typeName := graphQLTypeName(controller.meta.Type.Name())
+		if schema.types[typeName] != nil {
+			panic(fmt.Sprintf(`fire: graphql type "%s" already exists`, typeName))
+		}
+
+		// add type
+		typ := &graphQLType{
+			name:       typeName,
+			controller: controller,
+			fields:     map[string]*graphQLField{},
+		}
+		schema.types[typeName] = typ
+		schema.resources[name] = typ
+	}
+
+	// add fields and root fields
+	for _, name := range sortedKeys(schema.resources) {
+		typ := schema.resources[name]
+		controller := typ.controller
+
+		// prepare adder
+		add := func(field *graphQLField) {
+			if field.name == "id" || typ.fields[field.name] != nil {
+				panic(fmt.Sprintf(`fire: graphql field "%s" on type "%s" already exists`, field.name, typ.name))
+			}
+			typ.fields[field.name] = field
+			typ.names = append(typ.names, field.name)
+		}
+
+		// add id
+		typ.fields["id"] = &graphQLField{name: "id", typ: "ID!"}
+		typ.names = append(typ.names, "id")
+
+		// add attributes
+		for _, field := range controller.meta.OrderedFields {
+			if field.JSONKey != "" {
+				scalar := graphQLScalar(field.Type)
+				add(&graphQLField{
+					name:  graphQLName(field.JSONKey),
+					key:   field.JSONKey,
+					typ:   scalar,
+					input: scalar,
+				})
+			}
+		}
+
+		// add properties
+		ptrType := reflect.PtrTo(controller.meta.Type)
+		for _, property := range sortedKeys(controller.Properties) {
+			key := controller.Properties[property]
+			method, _ := ptrType.MethodByName(property)
+			add(&graphQLField{
+				name: graphQLName(key),
+				key:  key,
+				typ:  graphQLScalar(method.Type.Out(0)),
+			})
+		}
+
+		// add batch properties
+		for _, key := range sortedKeys(controller.BatchProperties) {
+			add(&graphQLField{
+				name: graphQLName(key),
+				key:  key,
+				typ:  "JSON",
+			})
+		}
+
+		// add relationships
+		for _, field := range controller.meta.OrderedFields {
+			// check relationship
+			if field.RelName == "" {
+				continue
+			}
+
+			// get related type
+			related := schema.resources[field.RelType]
+			if related == nil {
+				continue
+			}
+
+			// prepare field
+			f := &graphQLField{
+				name:     graphQLName(field.RelName),
+				key:      field.RelName,
+				relation: field,
+				related:  related.name,
+			}
+
+			// set types
+			switch {
+			case field.ToOne:
+				f.typ = related.name
+				f.input = "ID"
+			case field.ToMany:
+				f.typ = "[" + related.name + "!]"
+				f.input = "[ID!]"
+			case field.HasOne:
+				f.typ = related.name
+			case field.HasMany:
+				f.typ = "[" + related.name + "!]"
+			}
+
+			add(f)
+		}
+
+		// collect input fields
+		for _, fieldName := range typ.names {
+			if field := typ.fields[fieldName]; field.input != "" {
+				typ.input = append(typ.input, field)
+			}
+		}
+
+		// check supported operations
+		supported := map[Operation]bool{}
+		for _, op := range []Operation{List, Find, Create, Update, Delete} {
+			supported[op] = controller.Supported(&Context{Operation: op})
+		}
+
+		// prepare root fields
+		single := strings.ToLower(typ.name[:1]) + typ.name[1:]
+		roots := []struct {
+			op     Operation
+			target map[string]*graphQLRoot
+			name   string
+			root   *graphQLRoot
+		}{
+			{List, schema.query, graphQLName(name), &graphQLRoot{intent: jsonapi.ListResources, arguments: graphQLListArguments, result: "[" + typ.name + "!]"}},
+			{Find, schema.query, single, &graphQLRoot{intent: jsonapi.FindResource, arguments: []string{"id"}, result: typ.name}},
+			{Create, schema.mutation, "create" + typ.name, &graphQLRoot{intent: jsonapi.CreateResource, arguments: []string{"input"}, result: typ.name}},
+			{Update, schema.mutation, "update" + typ.name, &graphQLRoot{intent: jsonapi.UpdateResource, arguments: []string{"id", "input"}, result: typ.name}},
+			{Delete, schema.mutation, "delete" + typ.name, &graphQLRoot{intent: jsonapi.DeleteResource, arguments: []string{"id"}, result: "ID"}},
+		}
+
+		// add root fields
+		for _, item := range roots {
+			if !supported[item.op] {
+				continue
+			}
+			if item.target[item.name] != nil {
+				panic(fmt.Sprintf(`fire: graphql root field "%s" already exists`, item.name))
+			}
+			item.root.typ = typ
+			item.target[item.name] = item.root
+		}
+	}
+
+	return schema
+}
+
+type graphQLExecutor struct {
+	ctx       *Context
+	schema    *graphQLSchema
+	doc       *graphQLDocument
+	variables map[string]interface{}
+	errors    []graphQLError
+}
+
+type graphQLItem struct {
+	res  *jsonapi.Resource
+	path []interface{}
+}
+
+func (e *graphQLExecutor) rootName(op *graphQLOperation) string {
+	// get root type name
+	if op.typ == "mutation" {
+		return "Mutation"
+	}
+
+	return "Query"
+}
+
+func (e *graphQLExecutor) measure(typeName string, selection []*graphQLSelection, level int) (int, int) {
+	// get fields
+	var fields map[string]string
+	switch typeName {
+	case "Query", "Mutation":
+		roots := e.schema.query
+		if typeName == "Mutation" {
+			roots = e.schema.mutation
+		}
+		fields = map[string]string{}
+		for name, root := range roots {
+			fields[name] = root.typ.name
+		}
+	default:
+		if typ := e.schema.types[typeName]; typ != nil {
+			fields = map[string]string{}
+			for name, field := range typ.fields {
+				fields[name] = field.related
+			}
+		}
+	}
+
+	// measure fields
+	var depth, complexity int
+	for _, sel := range e.collect(typeName, selection, nil) {
+		// count field
+		depth = max(depth, level)
+		complexity++
+
+		// measure selection
+		if len(sel.selection) > 0 {
+			d, c := e.measure(fields[sel.name], sel.selection, level+1)
+			depth = max(depth, d)
+			complexity += c
+		}
+	}
+
+	return depth, complexity
+}
+
+func (e *graphQLExecutor) execute(op *graphQLOperation) *graphQLObject {
+	// get root type
+	rootName := e.rootName(op)
+	roots := e.schema.query
+	if op.typ == "mutation" {
+		roots = e.schema.mutation
+	}
+
+	// resolve root fields in order
+	data := &graphQLObject{values: map[string]interface{}{}}
+	for _, sel := range e.collect(rootName, op.selection, nil) {
+		// handle type name
+		if sel.name == "__typename" {
+			data.set(sel.alias, rootName)
+			continue
+		}
+
+		// get root field
+		root := roots[sel.name]
+		if root == nil {
+			e.fail(xo.SF(`unknown field "%s" on type "%s"`, sel.name, rootName), []interface{}{sel.alias})
+			data.set(sel.alias, nil)
+			continue
+		}
+
+		// resolve field
+		data.set(sel.alias, e.resolveRoot(root, sel, []interface{}{sel.alias}))
+	}
+
+	return data
+}
+
+func (e *graphQLExecutor) resolveRoot(root *graphQLRoot, sel *graphQLSelection, path []interface{}) (value interface{}) {
+	// trace
+	e.ctx.Tracer.Push("fire/graphQLExecutor.resolveRoot")
+	defer e.ctx.Tracer.Pop()
+
+	// handle errors
+	defer xo.Resume(func(err error) {
+		e.fail(err, path)
+		value = nil
+	})
+
+	// get arguments
+	args := e.arguments(sel, root.arguments)
+
+	// prepare request
+	req := &jsonapi.Request{
+		Intent:       root.intent,
+		ResourceType: root.typ.controller.meta.PluralName,
+	}
+
+	// get id
+	if stick.Contains(root.arguments, "id") {
+		req.ResourceID, _ = args["id"].(string)
+		if req.ResourceID == "" {
+			xo.Abort(jsonapi.BadRequest(`missing argument "id"`))
+		}
+	}
+
+	// prepare document
+	var doc *jsonapi.Document
+	if root.intent == jsonapi.CreateResource || root.intent == jsonapi.UpdateResource {
+		doc = root.typ.document(req.ResourceID, args["input"])
+	}
+
+	// apply list arguments
+	if root.intent == jsonapi.ListResources {
+		root.typ.applyArguments(req, args)
+	}
+
+	// check selection
+	if root.intent != jsonapi.DeleteResource && len(sel.selection) == 0 {
+		xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`missing selection for field "%s"`, sel.name)))
+	} else if root.intent == jsonapi.DeleteResource && len(sel.selection) > 0 {
+		xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`unexpected selection for field "%s"`, sel.name)))
+	}
+
+	// handle request
+	ctx := e.handle(root.typ.controller, req, doc, nil)
+
+	// handle deletion
+	if root.intent == jsonapi.DeleteResource {
+		return req.ResourceID
+	}
+
+	// handle list
+	if root.intent == jsonapi.ListResources {
+		items := make([]graphQLItem, 0, len(ctx.Response.Data.Many))
+		for i, res := range ctx.Response.Data.Many {
+			items = append(items, graphQLItem{res: res, path: graphQLPath(path, i)})
+		}
+		return e.resolveResources(root.typ, items, sel.selection)
+	}
+
+	return e.resolveResources(root.typ, []graphQLItem{{res: ctx.Response.Data.One, path: path}}, sel.selection)[0]
+}
+
+func (e *graphQLExecutor) resolveResources(typ *graphQLType, items []graphQLItem, selection []*graphQLSelection) []interface{} {
+	// prepare objects for available resources
+	values := make([]interface{}, len(items))
+	available := make([]graphQLItem, 0, len(items))
+	objects := make([]*graphQLObject, 0, len(items))
+	for i, item := range items {
+		if item.res != nil {
+			obj := &graphQLObject{values: map[string]interface{}{}}
+			values[i] = obj
+			available = append(available, item)
+			objects = append(objects, obj)
+		}
+	}
+
+	// check resources
+	if len(available) == 0 {
+		return values
+	}
+
+	// resolve fields in order
+	for _, sel := range e.collect(typ.name, selection, nil) {
+		// handle type name
+		if sel.name == "__typename" {
+			for _, obj := range objects {
+				obj.set(sel.alias, typ.name)
+			}
+			continue
+		}
+
+		// get field
+		field := typ.fields[sel.name]
+		if field == nil {
+			for i, obj := range objects {
+				e.fail(xo.SF(`unknown field "%s" on type "%s"`, sel.name, typ.name), graphQLPath(available[i].path, sel.alias))
+				obj.set(sel.alias, nil)
+			}
+			continue
+		}
+
+		// handle relationships
+		if field.relation != nil {
+			for i, value := range e.resolveRelationships(typ, available, field, sel) {
+				objects[i].set(sel.alias, value)
+			}
+			continue
+		}
+
+		// check selection and arguments
+		if len(sel.selection) > 0 || len(sel.arguments) > 0 {
+			for i, obj := range objects {
+				e.fail(xo.SF(`unexpected selection or arguments for field "%s"`, sel.name), graphQLPath(available[i].path, sel.alias))
+				obj.set(sel.alias, nil)
+			}
+			continue
+		}
+
+		// set id, attribute or property
+		for i, obj := range objects {
+			if field.name == "id" {
+				obj.set(sel.alias, available[i].res.ID)
+			} else {
+				obj.set(sel.alias, available[i].res.Attributes[field.key])
+			}
+		}
+	}
+
+	return values
+}
+
+func (e *graphQLExecutor) resolveRelationships(typ *graphQLType, items []graphQLItem, field *graphQLField, sel *graphQLSelection) (values []interface{}) {
+	// trace
+	e.ctx.Tracer.Push("fire/graphQLExecutor.resolveRelationships")
+	defer e.ctx.Tracer.Pop()
+
+	// prepare values
+	values = make([]interface{}, len(items))
+
+	// handle errors
+	defer xo.Resume(func(err error) {
+		for _, item := range items {
+			e.fail(err, graphQLPath(item.path, sel.alias))
+		}
+		values = make([]interface{}, len(items))
+	})
+
+	// determine cardinality
+	many := field.relation.ToMany || field.relation.HasMany
+
+	// get arguments
+	var args map[string]interface{}
+	if many {
+		args = e.arguments(sel, graphQLListArguments)
+	} else {
+		args = e.arguments(sel, nil)
+	}
+
+	// check selection
+	if len(sel.selection) == 0 {
+		xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`missing selection for field "%s"`, sel.name)))
+	}
+
+	// get related type
+	related := e.schema.types[field.related]
+
+	// load related resources in batch if possible or individually
+	var results [][]*jsonapi.Resource
+	var failed []bool
+	if len(args) == 0 && related.controller.ListLimit == 0 {
+		results, failed = e.loadRelated(typ, items, field, sel)
+	} else {
+		results = make([][]*jsonapi.Resource, len(items))
+		failed = make([]bool, len(items))
+		for i, item := range items {
+			results[i], failed[i] = e.loadRelationship(typ, item, field, sel, args)
+		}
+	}
+
+	// collect related resources
+	var children []graphQLItem
+	for i, item := range items {
+		// skip failed resources
+		if failed[i] {
+			continue
+		}
+
+		// add resources
+		path := graphQLPath(item.path, sel.alias)
+		if many {
+			for j, res := range results[i] {
+				children = append(children, graphQLItem{res: res, path: graphQLPath(path, j)})
+			}
+		} else {
+			var res *jsonapi.Resource
+			if len(results[i]) > 0 {
+				res = results[i][0]
+			}
+			children = append(children, graphQLItem{res: res, path: path})
+		}
+	}
+
+	// resolve related resources together
+	objects := e.resolveResources(related, children, sel.selection)
+
+	// distribute objects
+	var k int
+	for i := range items {
+		// skip failed resources
+		if failed[i] {
+			continue
+		}
+
+		// set list or object
+		if many {
+			n := len(results[i])
+			values[i] = append(make([]interface{}, 0, n), objects[k:k+n]...)
+			k += n
+		} else {
+			values[i] = objects[k]
+			k++
+		}
+	}
+
+	return values
+}
+
+func (e *graphQLExecutor) loadRelationship(typ *graphQLType, item graphQLItem, field *graphQLField, sel *graphQLSelection, args map[string]interface{}) (list []*jsonapi.Resource, failed bool) {
+	// handle errors
+	defer xo.Resume(func(err error) {
+		e.fail(err, graphQLPath(item.path, sel.alias))
+		list = nil
+		failed = true
+	})
+
+	// prepare request
+	req := &jsonapi.Request{
+		Intent:          jsonapi.GetRelatedResources,
+		ResourceType:    typ.controller.meta.PluralName,
+		ResourceID:      item.res.ID,
+		RelatedResource: field.key,
+	}
+
+	// apply list arguments
+	if field.relation.ToMany || field.relation.HasMany {
+		e.schema.types[field.related].applyArguments(req, args)
+	}
+
+	// handle request
+	ctx := e.handle(typ.controller, req, nil, nil)
+
+	// get resources
+	if ctx.Response.Data.One != nil {
+		return []*jsonapi.Resource{ctx.Response.Data.One}, false
+	}
+
+	return ctx.Response.Data.Many, false
+}
+
+func (e *graphQLExecutor) loadRelated(typ *graphQLType, items []graphQLItem, field *graphQLField, sel *graphQLSelection) ([][]*jsonapi.Resource, []bool) {
+	// get relationship and related controller
+	rel := field.relation
+	rc := e.schema.types[field.related].controller
+
+	// prepare results
+	results := make([][]*jsonapi.Resource, len(items))
+	failed := make([]bool, len(items))
+
+	// collect references of readable relationships
+	var ids []coal.ID
+	refs := make([][]coal.ID, len(items))
+	for i, item := range items {
+		// check relationship
+		doc := item.res.Relationships[field.key]
+		if doc == nil {
+			e.fail(jsonapi.BadRequest("relationship is not readable"), graphQLPath(item.path, sel.alias))
+			failed[i] = true
+			continue
+		}
+
+		// collect parent IDs for has-one and has-many relationships
+		if rel.HasOne || rel.HasMany {
+			refs[i] = []coal.ID{coal.MustFromHex(item.res.ID)}
+		} else if doc.Data != nil && doc.Data.One != nil {
+			refs[i] = []coal.ID{coal.MustFromHex(doc.Data.One.ID)}
+		} else if doc.Data != nil {
+			for _, ref := range doc.Data.Many {
+				refs[i] = append(refs[i], coal.MustFromHex(ref.ID))
+			}
+		}
+		ids = append(ids, refs[i]...)
+	}
+
+	// check IDs
+	ids = stick.Unique(ids)
+	if len(ids) == 0 {
+		return results, failed
+	}
+
+	// prepare selector
+	var inverse *coal.Field
+	selector := bson.M{
+		"_id": bson.M{"$in": ids},
+	}
+	if rel.HasOne || rel.HasMany {
+		inverse = rc.meta.Relationships[rel.RelInverse]
+		if inverse == nil {
+			xo.Abort(xo.F("no relationship matching the inverse name %s", rel.RelInverse))
+		}
+		selector = bson.M{
+			inverse.Name: bson.M{"$in": ids},
+		}
+	}
+
+	// load related resources
+	ctx := e.handle(rc, &jsonapi.Request{
+		Intent:       jsonapi.ListResources,
+		ResourceType: rel.RelType,
+	}, nil, selector)
+
+	// group related resources by their references
+	groups := map[coal.ID][]*jsonapi.Resource{}
+	for i, res := range ctx.Response.Data.Many {
+		// get references
+		var keys []coal.ID
+		if inverse == nil {
+			keys = []coal.ID{coal.MustFromHex(res.ID)}
+		} else {
+			switch value := stick.MustGet(ctx.Models[i], inverse.Name).(type) {
+			case coal.ID:
+				keys = []coal.ID{value}
+			case *coal.ID:
+				if value != nil {
+					keys = []coal.ID{*value}
+				}
+			case []coal.ID:
+				keys = value
+			}
+		}
+
+		// add resource
+		for _, key := range keys {
+			groups[key] = append(groups[key], res)
+		}
+	}
+
+	// assign related resources in response order
+	for i, item := range items {
+		// skip failed resources
+		if failed[i] {
+			continue
+		}
+
+		// collect resources
+		for _, id := range refs[i] {
+			results[i] = append(results[i], groups[id]...)
+		}
+
+		// check has-one relationships
+		if rel.HasOne && len(results[i]) > 1 {
+			e.fail(xo.F("has one relationship returned more than one result"), graphQLPath(item.path, sel.alias))
+			failed[i] = true
+		}
+	}
+
+	return results, failed
+}
+
+func (e *graphQLExecutor) handle(controller *Controller, req *jsonapi.Request, doc *jsonapi.Document, selector bson.M) *Context {
+	// prepare context
+	ctx := &Context{
+		Context:        e.ctx,
+		Data:           stick.Map{},
+		HTTPRequest:    e.ctx.HTTPRequest,
+		Controller:     controller,
+		Group:          e.ctx.Group,
+		APIVersion:     e.ctx.APIVersion,
+		Tracer:         e.ctx.Tracer,
+		JSONAPIRequest: req,
+		Request:        doc,
+	}
+
+	// handle request
+	controller.handle("", ctx, selector, false)
+
+	return ctx
+}
+
+func (e *graphQLExecutor) collect(typeName string, selection []*graphQLSelection, visited map[string]bool) []*graphQLSelection {
+	// ensure visited fragments
+	if visited == nil {
+		visited = map[string]bool{}
+	}
+
+	// collect fields
+	var list []*graphQLSelection
+	index := map[string]int{}
+	for _, sel := range selection {
+		// check directives
+		if !e.included(sel) {
+			continue
+		}
+
+		// prepare fields
+		var fields []*graphQLSelection
+
+		// handle fields, fragment spreads and inline fragments
+		switch {
+		case sel.spread != "":
+			fragment := e.doc.fragments[sel.spread]
+			if visited[sel.spread] || fragment.on != typeName {
+				continue
+			}
+			visited[sel.spread] = true
+			fields = e.collect(typeName, fragment.selection, visited)
+		case sel.inline:
+			if sel.on != "" && sel.on != typeName {
+				continue
+			}
+			fields = e.collect(typeName, sel.selection, visited)
+		default:
+			fields = []*graphQLSelection{sel}
+		}
+
+		// merge fields with the same response key
+		for _, field := range fields {
+			i, ok := index[field.alias]
+			if !ok {
+				index[field.alias] = len(list)
+				list = append(list, field)
+				continue
+			}
+			merged := *list[i]
+			merged.selection = append(append([]*graphQLSelection{}, merged.selection...), field.selection...)
+			list[i] = &merged
+		}
+	}
+
+	return list
+}
+
+func (e *graphQLExecutor) included(sel *graphQLSelection) bool {
+	// check skip and include directives
+	for _, directive := range sel.directives {
+		value, _ := e.value(directive.arguments["if"]).(bool)
+		if directive.name == "skip" && value || directive.name == "include" && !value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *graphQLExecutor) arguments(sel *graphQLSelection, allowed []string) map[string]interface{} {
+	// resolve arguments
+	args := map[string]interface{}{}
+	for name, value := range sel.arguments {
+		if !stick.Contains(allowed, name) {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`unknown argument "%s" on field "%s"`, name, sel.name)))
+		}
+		args[name] = e.value(value)
+	}
+
+	return args
+}
+
+func (e *graphQLExecutor) value(value interface{}) interface{} {
+	// resolve variables
+	switch value := value.(type) {
+	case graphQLVariableRef:
+		return e.variables[string(value)]
+	case []interface{}:
+		list := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			list = append(list, e.value(item))
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			obj[key] = e.value(item)
+		}
+		return obj
+	default:
+		return value
+	}
+}
+
+func (e *graphQLExecutor) fail(err error, path []interface{}) {
+	// convert jsonapi and safe errors
+	var jsonapiError *jsonapi.Error
+	if errors.As(err, &jsonapiError) {
+		message := jsonapiError.Detail
+		if message == "" {
+			message = jsonapiError.Title
+		}
+		e.errors = append(e.errors, graphQLError{
+			Message: message,
+			Path:    path,
+			Extensions: stick.Map{
+				"status": jsonapiError.Status,
+			},
+		})
+		return
+	} else if xo.IsSafe(err) {
+		e.errors = append(e.errors, graphQLError{
+			Message: err.Error(),
+			Path:    path,
+		})
+		return
+	}
+
+	// record error
+	e.ctx.Tracer.Record(err)
+
+	// report error if possible
+	if e.ctx.Group.reporter != nil {
+		e.ctx.Group.reporter(err)
+	}
+
+	// add internal server error
+	e.errors = append(e.errors, graphQLError{
+		Message: "internal server error",
+		Path:    path,
+	})
+}
+
+func (t *graphQLType) document(id string, input interface{}) *jsonapi.Document {
+	// check input
+	values, ok := input.(map[string]interface{})
+	if !ok {
+		xo.Abort(jsonapi.BadRequest(`missing or invalid argument "input"`))
+	}
+
+	// prepare resource
+	attributes := map[string]interface{}{}
+	relationships := map[string]interface{}{}
+	for name, value := range values {
+		// get field
+		field := t.fields[name]
+		if field == nil || field.input == "" {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid input field "%s"`, name)))
+		}
+
+		// add attribute
+		if field.relation == nil {
+			attributes[field.key] = value
+			continue
+		}
+
+		// add to-one relationship
+		if field.relation.ToOne {
+			var data interface{}
+			if value != nil {
+				data = map[string]interface{}{"type": field.relation.RelType, "id": value}
+			}
+			relationships[field.key] = map[string]interface{}{"data": data}
+			continue
+		}
+
+		// add to-many relationship
+		ids, _ := value.([]interface{})
+		data := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			data = append(data, map[string]interface{}{"type": field.relation.RelType, "id": id})
+		}
+		relationships[field.key] = map[string]interface{}{"data": data}
+	}
+
+	// encode document
+	buf, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":          t.controller.meta.PluralName,
+			"id":            id,
+			"attributes":    attributes,
+			"relationships": relationships,
+		},
+	})
+	xo.AbortIf(err)
+
+	// parse document
+	doc, err := jsonapi.ParseDocument(bytes.NewReader(buf))
+	if err != nil {
+		xo.Abort(jsonapi.BadRequest(`invalid argument "input"`))
+	}
+
+	return doc
+}
+
+func (t *graphQLType) applyArguments(req *jsonapi.Request, args map[string]interface{}) {
+	// apply filters
+	if filter, ok := args["filter"]; ok {
+		values, ok := filter.(map[string]interface{})
+		if !ok {
+			xo.Abort(jsonapi.BadRequest(`invalid argument "filter"`))
+		}
+		req.Filters = map[string][]string{}
+		for name, value := range values {
+			key := t.key(name)
+			if operators, ok := value.(map[string]interface{}); ok {
+				for operator, value := range operators {
+					req.Filters[key+"]["+operator] = graphQLStrings(value)
+				}
+			} else {
+				req.Filters[key] = graphQLStrings(value)
+			}
+		}
+	}
+
+	// apply sorting
+	if sorting, ok := args["sort"]; ok {
+		for _, name := range graphQLStrings(sorting) {
+			if strings.HasPrefix(name, "-") {
+				req.Sorting = append(req.Sorting, "-"+t.key(name[1:]))
+			} else {
+				req.Sorting = append(req.Sorting, t.key(name))
+			}
+		}
+	}
+
+	// apply pagination
+	for name, target := range map[string]*int64{"page": &req.PageNumber, "size": &req.PageSize} {
+		if value, ok := args[name]; ok {
+			num, ok := value.(json.Number)
+			n, err := num.Int64()
+			if !ok || err != nil || n <= 0 {
+				xo.Abort(jsonapi.BadRequest(fmt.Sprintf(`invalid argument "%s"`, name)))
+			}
+			*target = n
+		}
+	}
+}
+
+func (t *graphQLType) key(name string) string {
+	// get attribute or relationship key
+	if field := t.fields[name]; field != nil && field.key != "" {
+		return field.key
+	}
+
+	return name
+}
+
+func graphQLStrings(value interface{}) []string {
+	// handle lists
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			values = append(values, graphQLStrings(item)...)
+		}
+		return values
+	}
+
+	// handle scalars
+	switch value := value.(type) {
+	case nil:
+		return []string{""}
+	case string:
+		return []string{value}
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+func graphQLScalar(typ reflect.Type) string {
+	// handle pointers
+	if typ.Kind() == reflect.Ptr {
+		return graphQLScalar(typ.Elem())
+	}
+
+	// handle special types
+	switch {
+	case typ == timeType:
+		return "Time"
+	case typ == idType:
+		return "ID"
+	case typ.Implements(openAPIMarshalerType) || reflect.PtrTo(typ).Implements(openAPIMarshalerType):
+		return "JSON"
+	}
+
+	// handle kinds
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "Boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Int"
+	case reflect.Float32, reflect.Float64:
+		return "Float"
+	case reflect.String:
+		return "String"
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "String"
+		}
+		elem := graphQLScalar(typ.Elem())
+		if typ.Elem().Kind() != reflect.Ptr {
+			elem += "!"
+		}
+		return "[" + elem + "]"
+	default:
+		return "JSON"
+	}
+}
+
+func graphQLArgumentsSchema(arguments []string, typ *graphQLType) string {
+	// check arguments
+	if len(arguments) == 0 {
+		return ""
+	}
+
+	// prepare types
+	types := map[string]string{
+		"filter": "JSON",
+		"sort":   "[String!]",
+		"page":   "Int",
+		"size":   "Int",
+		"id":     "ID!",
+	}
+	if typ != nil {
+		types["input"] = typ.name + "Input!"
+	}
+
+	// write arguments
+	list := make([]string, 0, len(arguments))
+	for _, name := range arguments {
+		list = append(list, name+": "+types[name])
+	}
+
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+func graphQLTypeName(name string) string {
+	// capitalize name
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func graphQLName(key string) string {
+	// convert dashed keys to camel case
+	parts := strings.Split(key, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+func graphQLPath(path []interface{}, elem interface{}) []interface{} {
+	// copy path and append element
+	return append(append(make([]interface{}, 0, len(path)+1), path...), elem)
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, message string) {
+	writeGraphQL(w, status, &graphQLResponse{
+		Errors: []graphQLError{{Message: message}},
+	})
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, res *graphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
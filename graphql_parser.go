@@ -0,0 +1,554 @@
+package fire
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/256dpi/xo"
+)
+
+type graphQLDocument struct {
+	operations []*graphQLOperation
+	fragments  map[string]*graphQLFragment
+}
+
+type graphQLOperation struct {
+	typ       string
+	name      string
+	variables []*graphQLVariable
+	selection []*graphQLSelection
+}
+
+type graphQLVariable struct {
+	name       string
+	value      interface{}
+	hasDefault bool
+}
+
+type graphQLFragment struct {
+	name      string
+	on        string
+	selection []*graphQLSelection
+}
+
+type graphQLSelection struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	directives []*graphQLDirective
+	selection  []*graphQLSelection
+	spread     string
+	inline     bool
+	on         string
+}
+
+type graphQLDirective struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+type graphQLVariableRef string
+
+type graphQLParser struct {
+	src     string
+	pos     int
+	spreads []string
+}
+
+func parseGraphQL(src string) (doc *graphQLDocument, err error) {
+	// handle aborts
+	defer xo.Resume(func(e error) {
+		err = e
+	})
+
+	// prepare parser
+	p := &graphQLParser{src: src}
+
+	// prepare document
+	doc = &graphQLDocument{
+		fragments: map[string]*graphQLFragment{},
+	}
+
+	// parse definitions
+	for p.skip(); p.pos < len(p.src); p.skip() {
+		// handle shorthand query
+		if p.peek('{') {
+			doc.operations = append(doc.operations, &graphQLOperation{
+				typ:       "query",
+				selection: p.selectionSet(),
+			})
+			continue
+		}
+
+		// get keyword
+		keyword := p.name()
+
+		// handle fragment
+		if keyword == "fragment" {
+			fragment := &graphQLFragment{
+				name: p.name(),
+			}
+			if p.name() != "on" {
+				p.fail("expected type condition")
+			}
+			fragment.on = p.name()
+			p.directives()
+			fragment.selection = p.selectionSet()
+			if doc.fragments[fragment.name] != nil {
+				p.fail("duplicate fragment %q", fragment.name)
+			}
+			doc.fragments[fragment.name] = fragment
+			continue
+		}
+
+		// check operation type
+		if keyword != "query" && keyword != "mutation" && keyword != "subscription" {
+			p.fail("unexpected %q", keyword)
+		}
+
+		// prepare operation
+		op := &graphQLOperation{
+			typ: keyword,
+		}
+
+		// parse name
+		if !p.peek('(') && !p.peek('@') && !p.peek('{') {
+			op.name = p.name()
+		}
+
+		// parse variables
+		if p.consume('(') {
+			for !p.consume(')') {
+				p.expect('$')
+				variable := &graphQLVariable{
+					name: p.name(),
+				}
+				p.expect(':')
+				p.typeRef()
+				if p.consume('=') {
+					variable.value = p.value(true)
+					variable.hasDefault = true
+				}
+				p.directives()
+				op.variables = append(op.variables, variable)
+			}
+		}
+
+		// parse directives and selection
+		p.directives()
+		op.selection = p.selectionSet()
+
+		// add operation
+		doc.operations = append(doc.operations, op)
+	}
+
+	// check operations
+	if len(doc.operations) == 0 {
+		p.fail("missing operation")
+	}
+
+	// check fragment spreads
+	for _, name := range p.spreads {
+		if doc.fragments[name] == nil {
+			xo.Abort(xo.SF("unknown fragment %q", name))
+		}
+	}
+
+	// check fragment cycles
+	for name := range doc.fragments {
+		doc.checkCycles(name, map[string]bool{})
+	}
+
+	return doc, nil
+}
+
+func (d *graphQLDocument) checkCycles(name string, stack map[string]bool) {
+	// check stack
+	if stack[name] {
+		xo.Abort(xo.SF("fragment cycle %q", name))
+	}
+
+	// check spreads
+	stack[name] = true
+	for _, spread := range graphQLSpreads(d.fragments[name].selection) {
+		d.checkCycles(spread, stack)
+	}
+	delete(stack, name)
+}
+
+func graphQLSpreads(selection []*graphQLSelection) []string {
+	// collect spreads of nested selections
+	var list []string
+	for _, sel := range selection {
+		if sel.spread != "" {
+			list = append(list, sel.spread)
+		}
+		list = append(list, graphQLSpreads(sel.selection)...)
+	}
+
+	return list
+}
+
+func (p *graphQLParser) selectionSet() []*graphQLSelection {
+	// parse selections
+	var list []*graphQLSelection
+	p.expect('{')
+	for !p.consume('}') {
+		list = append(list, p.selection())
+	}
+
+	// check selections
+	if len(list) == 0 {
+		p.fail("empty selection set")
+	}
+
+	return list
+}
+
+func (p *graphQLParser) selection() *graphQLSelection {
+	// handle fragments
+	p.skip()
+	if strings.HasPrefix(p.src[p.pos:], "...") {
+		p.pos += 3
+		p.skip()
+
+		// handle inline fragments without type condition
+		if p.peek('@') || p.peek('{') {
+			return &graphQLSelection{
+				inline:     true,
+				directives: p.directives(),
+				selection:  p.selectionSet(),
+			}
+		}
+
+		// handle inline fragments with type condition
+		name := p.name()
+		if name == "on" {
+			sel := &graphQLSelection{
+				inline: true,
+				on:     p.name(),
+			}
+			sel.directives = p.directives()
+			sel.selection = p.selectionSet()
+			return sel
+		}
+
+		p.spreads = append(p.spreads, name)
+
+		return &graphQLSelection{
+			spread:     name,
+			directives: p.directives(),
+		}
+	}
+
+	// parse name and alias
+	sel := &graphQLSelection{
+		name: p.name(),
+	}
+	if p.consume(':') {
+		sel.alias = sel.name
+		sel.name = p.name()
+	} else {
+		sel.alias = sel.name
+	}
+
+	// parse arguments and directives
+	sel.arguments = p.arguments(false)
+	sel.directives = p.directives()
+
+	// parse selection set
+	if p.peek('{') {
+		sel.selection = p.selectionSet()
+	}
+
+	return sel
+}
+
+func (p *graphQLParser) arguments(constant bool) map[string]interface{} {
+	// check arguments
+	args := map[string]interface{}{}
+	if !p.consume('(') {
+		return args
+	}
+
+	// parse arguments
+	for !p.consume(')') {
+		name := p.name()
+		p.expect(':')
+		if _, ok := args[name]; ok {
+			p.fail("duplicate argument %q", name)
+		}
+		args[name] = p.value(constant)
+	}
+
+	return args
+}
+
+func (p *graphQLParser) directives() []*graphQLDirective {
+	// parse directives
+	var list []*graphQLDirective
+	for p.consume('@') {
+		list = append(list, &graphQLDirective{
+			name:      p.name(),
+			arguments: p.arguments(false),
+		})
+	}
+
+	return list
+}
+
+func (p *graphQLParser) typeRef() {
+	// parse list or named type
+	if p.consume('[') {
+		p.typeRef()
+		p.expect(']')
+	} else {
+		p.name()
+	}
+
+	// parse non-null marker
+	p.consume('!')
+}
+
+func (p *graphQLParser) value(constant bool) interface{} {
+	// skip ignored
+	p.skip()
+	if p.pos >= len(p.src) {
+		p.fail("unexpected end of document")
+	}
+
+	// handle punctuation and literals
+	switch ch := p.src[p.pos]; {
+	case ch == '$':
+		if constant {
+			p.fail("unexpected variable")
+		}
+		p.pos++
+		return graphQLVariableRef(p.name())
+	case ch == '[':
+		p.pos++
+		list := []interface{}{}
+		for !p.consume(']') {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case ch == '{':
+		p.pos++
+		obj := map[string]interface{}{}
+		for !p.consume('}') {
+			name := p.name()
+			p.expect(':')
+			obj[name] = p.value(constant)
+		}
+		return obj
+	case ch == '"':
+		return p.string()
+	case ch == '-' || ch >= '0' && ch <= '9':
+		return p.number()
+	}
+
+	// handle names
+	switch name := p.name(); name {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	default:
+		return name
+	}
+}
+
+func (p *graphQLParser) number() json.Number {
+	// scan number
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("+-.0123456789eE", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+
+	// check number
+	num := p.src[start:p.pos]
+	if _, err := strconv.ParseFloat(num, 64); err != nil {
+		p.fail("invalid number %q", num)
+	}
+
+	return json.Number(num)
+}
+
+func (p *graphQLParser) string() string {
+	// handle block strings
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		p.pos += 3
+		end := strings.Index(strings.ReplaceAll(p.src[p.pos:], `\"""`, "\x00\x00\x00\x00"), `"""`)
+		if end < 0 {
+			p.fail("unterminated string")
+		}
+		raw := strings.ReplaceAll(p.src[p.pos:p.pos+end], `\"""`, `"""`)
+		p.pos += end + 3
+		return graphQLBlockString(raw)
+	}
+
+	// parse string
+	p.pos++
+	var b strings.Builder
+	for {
+		// check end
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+
+		// handle end and plain characters
+		ch := p.src[p.pos]
+		if ch == '"' {
+			p.pos++
+			return b.String()
+		} else if ch != '\\' {
+			b.WriteByte(ch)
+			p.pos++
+			continue
+		}
+
+		// handle escape sequences
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid escape sequence")
+			}
+			code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid escape sequence")
+			}
+			b.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			p.fail("invalid escape sequence")
+		}
+	}
+}
+
+func (p *graphQLParser) name() string {
+	// scan name
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || p.pos > start && ch >= '0' && ch <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	// check name
+	if p.pos == start {
+		p.fail("expected name")
+	}
+
+	return p.src[start:p.pos]
+}
+
+func (p *graphQLParser) peek(ch byte) bool {
+	p.skip()
+	return p.pos < len(p.src) && p.src[p.pos] == ch
+}
+
+func (p *graphQLParser) consume(ch byte) bool {
+	if p.peek(ch) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *graphQLParser) expect(ch byte) {
+	if !p.consume(ch) {
+		p.fail("expected %q", string(ch))
+	}
+}
+
+func (p *graphQLParser) skip() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r', ',':
+			p.pos++
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+				p.pos += len("\ufeff")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (p *graphQLParser) fail(format string, args ...interface{}) {
+	// determine location
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	column := 1 + utf8.RuneCountInString(p.src[strings.LastIndex(p.src[:p.pos], "\n")+1:p.pos])
+
+	// abort with safe error
+	xo.Abort(xo.SF("syntax error at %d:%d: %s", line, column, fmt.Sprintf(format, args...)))
+}
+
+func graphQLBlockString(raw string) string {
+	// split lines
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+
+	// determine common indentation
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	// remove common indentation
+	if indent > 0 {
+		for i, line := range lines[1:] {
+			if len(line) >= indent {
+				lines[i+1] = line[indent:]
+			} else {
+				lines[i+1] = ""
+			}
+		}
+	}
+
+	// remove leading and trailing blank lines
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,530 @@
+package fire
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/256dpi/serve"
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire/coal"
+)
+
+func TestGroupGraphQLSchema(t *testing.T) {
+	group := NewGroup(nil)
+
+	group.Add(&Controller{
+		Model: &postModel{},
+		Store: lungoStore,
+		Properties: map[string]string{
+			"Virtual": "virtual",
+		},
+	}, &Controller{
+		Model:     &commentModel{},
+		Store:     lungoStore,
+		Supported: Only(List | Find),
+	})
+
+	assert.Equal(t, `scalar Time
+
+scalar JSON
+
+type CommentModel {
+  id: ID!
+  message: String
+  parent: CommentModel
+  post: PostModel
+}
+
+input CommentModelInput {
+  message: String
+  parent: ID
+  post: ID
+}
+
+type PostModel {
+  id: ID!
+  title: String
+  published: Boolean
+  textBody: String
+  virtual: Int
+  comments(filter: JSON, sort: [String!], page: Int, size: Int): [CommentModel!]
+}
+
+input PostModelInput {
+  title: String
+  published: Boolean
+  textBody: String
+}
+
+type Query {
+  commentModel(id: ID!): CommentModel
+  comments(filter: JSON, sort: [String!], page: Int, size: Int): [CommentModel!]
+  postModel(id: ID!): PostModel
+  posts(filter: JSON, sort: [String!], page: Int, size: Int): [PostModel!]
+}
+
+type Mutation {
+  createPostModel(input: PostModelInput!): PostModel
+  deletePostModel(id: ID!): ID
+  updatePostModel(id: ID!, input: PostModelInput!): PostModel
+}
+`, group.GraphQLSchema())
+}
+
+func TestGroupGraphQL(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := tester.Assign("", &Controller{
+			Model:   &postModel{},
+			Filters: []string{"Published"},
+			Sorters: []string{"Title"},
+			Properties: map[string]string{
+				"Virtual": "virtual",
+			},
+		}, &Controller{
+			Model: &commentModel{},
+			Authorizers: L{
+				C("TestGraphQL", Authorizer, All(), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Deny") != "" {
+						return xo.SF("not authorized")
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Handler = serve.Compose(xo.RootHandler(), group.GraphQL(0, 0))
+
+		// create post
+		var post coal.ID
+		tester.Request("POST", "graphql", `{
+			"query": "mutation Create($title: String) { post: createPostModel(input: {title: $title, textBody: \"Hello\"}) { id title textBody virtual } }",
+			"variables": {
+				"title": "Post 1"
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "errors").Exists(), tester.DebugRequest(rq, r))
+			post = coal.MustFromHex(gjson.Get(r.Body.String(), "data.post.id").String())
+			assert.JSONEq(t, `{
+				"data": {
+					"post": {
+						"id": "`+post.Hex()+`",
+						"title": "Post 1",
+						"textBody": "Hello",
+						"virtual": 42
+					}
+				}
+			}`, r.Body.String())
+		})
+
+		// run validators
+		tester.Request("POST", "graphql", `{
+			"query": "mutation { createPostModel(input: {title: \"error\"}) { id } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"createPostModel": null
+				},
+				"errors": [
+					{
+						"message": "validation error",
+						"path": ["createPostModel"],
+						"extensions": {
+							"status": 400
+						}
+					}
+				]
+			}`, r.Body.String())
+		})
+
+		tester.Insert(&postModel{
+			Title:     "Post 2",
+			Published: true,
+		})
+		comment := tester.Insert(&commentModel{
+			Message: "Comment 1",
+			Post:    post,
+		}).ID()
+
+		// list posts
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts(sort: [\"-title\"]) { __typename title ...Comments } } fragment Comments on PostModel { comments { id post { title } } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"posts": [
+						{
+							"__typename": "PostModel",
+							"title": "Post 2",
+							"comments": []
+						},
+						{
+							"__typename": "PostModel",
+							"title": "Post 1",
+							"comments": [
+								{
+									"id": "`+comment.Hex()+`",
+									"post": {
+										"title": "Post 1"
+									}
+								}
+							]
+						}
+					]
+				}
+			}`, r.Body.String())
+		})
+
+		// filter posts
+		tester.Request("GET", "graphql?query="+url.QueryEscape(`{ posts(filter: {published: true}) { title } }`), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"posts": [
+						{
+							"title": "Post 2"
+						}
+					]
+				}
+			}`, r.Body.String())
+		})
+
+		// invalid filter
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts(filter: {title: \"Post 1\"}) { title } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `invalid filter "title"`, gjson.Get(r.Body.String(), "errors.0.message").String())
+		})
+
+		// run authorizers
+		tester.Header["Deny"] = "1"
+		tester.Request("POST", "graphql", `{
+			"query": "query Find($id: ID!) { postModel(id: $id) { title comments { id } } }",
+			"variables": {
+				"id": "`+post.Hex()+`"
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"postModel": {
+						"title": "Post 1",
+						"comments": null
+					}
+				},
+				"errors": [
+					{
+						"message": "not authorized",
+						"path": ["postModel", "comments"],
+						"extensions": {
+							"status": 401
+						}
+					}
+				]
+			}`, r.Body.String())
+		})
+		delete(tester.Header, "Deny")
+
+		// update post
+		tester.Request("POST", "graphql", `{
+			"query": "mutation { updatePostModel(id: \"`+post.Hex()+`\", input: {published: true}) { title published } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"updatePostModel": {
+						"title": "Post 1",
+						"published": true
+					}
+				}
+			}`, r.Body.String())
+		})
+
+		// delete post
+		tester.Request("POST", "graphql", `{
+			"query": "mutation { deletePostModel(id: \"`+post.Hex()+`\") }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"deletePostModel": "`+post.Hex()+`"
+				}
+			}`, r.Body.String())
+		})
+
+		// find missing post
+		tester.Request("POST", "graphql", `{
+			"query": "{ postModel(id: \"`+post.Hex()+`\") { title } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"postModel": null
+				},
+				"errors": [
+					{
+						"message": "resource not found",
+						"path": ["postModel"],
+						"extensions": {
+							"status": 404
+						}
+					}
+				]
+			}`, r.Body.String())
+		})
+
+		// unknown fields
+		tester.Request("POST", "graphql", `{
+			"query": "{ foo posts { bar } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"foo": null,
+					"posts": [
+						{
+							"bar": null
+						}
+					]
+				},
+				"errors": [
+					{
+						"message": "unknown field \"foo\" on type \"Query\"",
+						"path": ["foo"]
+					},
+					{
+						"message": "unknown field \"bar\" on type \"PostModel\"",
+						"path": ["posts", 0, "bar"]
+					}
+				]
+			}`, r.Body.String())
+		})
+
+		// mutation via get
+		tester.Request("GET", "graphql?query="+url.QueryEscape(`mutation { deletePostModel(id: "x") }`), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusMethodNotAllowed, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// syntax error
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { title }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [
+					{
+						"message": "syntax error at 1:18: expected name"
+					}
+				]
+			}`, r.Body.String())
+		})
+	})
+}
+
+func TestGroupGraphQLBatching(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		counts := map[string]int{}
+		counter := C("TestGraphQLBatching", Authorizer, All(), func(ctx *Context) error {
+			counts[ctx.Controller.meta.PluralName]++
+			return nil
+		})
+
+		group := tester.Assign("", &Controller{
+			Model:       &postModel{},
+			Sorters:     []string{"Title"},
+			Authorizers: L{counter},
+		}, &Controller{
+			Model:       &commentModel{},
+			Authorizers: L{counter},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Handler = serve.Compose(xo.RootHandler(), group.GraphQL(0, 0))
+
+		var posts []coal.ID
+		for i := 1; i <= 3; i++ {
+			post := tester.Insert(&postModel{
+				Title: fmt.Sprintf("Post %d", i),
+			}).ID()
+			posts = append(posts, post)
+			for j := 0; j < i-1; j++ {
+				tester.Insert(&commentModel{
+					Message: fmt.Sprintf("Comment %d-%d", i, j+1),
+					Post:    post,
+				})
+			}
+		}
+
+		// batched relationships
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts(sort: [\"title\"]) { title comments { message post { title } } } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"data": {
+					"posts": [
+						{
+							"title": "Post 1",
+							"comments": []
+						},
+						{
+							"title": "Post 2",
+							"comments": [
+								{"message": "Comment 2-1", "post": {"title": "Post 2"}}
+							]
+						},
+						{
+							"title": "Post 3",
+							"comments": [
+								{"message": "Comment 3-1", "post": {"title": "Post 3"}},
+								{"message": "Comment 3-2", "post": {"title": "Post 3"}}
+							]
+						}
+					]
+				}
+			}`, r.Body.String())
+		})
+
+		assert.Equal(t, map[string]int{
+			"posts":    2,
+			"comments": 1,
+		}, counts)
+
+		// individual relationships
+		counts = map[string]int{}
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts(sort: [\"title\"]) { title comments(size: 1) { message } } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `[[],["Comment 2-1"],["Comment 3-1"]]`, gjson.Get(r.Body.String(), "data.posts.#.comments.#.message").Raw)
+		})
+
+		assert.Equal(t, map[string]int{
+			"posts":    4,
+			"comments": 3,
+		}, counts)
+	})
+}
+
+func TestGroupGraphQLLimits(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Handler = serve.Compose(xo.RootHandler(), group.GraphQL(2, 4))
+
+		tester.Insert(&postModel{
+			Title: "Post",
+		})
+
+		// within limits
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { id title ...Extra } } fragment Extra on PostModel { published }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post", gjson.Get(r.Body.String(), "data.posts.0.title").String())
+		})
+
+		// exceeded depth
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { comments { id } } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "query exceeds maximum depth", gjson.Get(r.Body.String(), "errors.0.message").String())
+		})
+
+		// exceeded complexity
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { id title ...Extra } } fragment Extra on PostModel { published textBody }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "query exceeds maximum complexity", gjson.Get(r.Body.String(), "errors.0.message").String())
+		})
+	})
+}
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# comment
+		query Foo($a: [ID!]! = ["x"], $b: Int) @dir {
+			alias: field(a: $a, b: -1.5e3, c: "A\n", d: """
+				block
+				  string
+			""", e: {f: [true, null, ENUM]}) @skip(if: $b) {
+				...Frag
+				... on Bar { baz }
+				... @include(if: true) { qux }
+			}
+		}
+		fragment Frag on Bar { quux }
+	`)
+	assert.NoError(t, err)
+	assert.Len(t, doc.operations, 1)
+	assert.Len(t, doc.fragments, 1)
+
+	op := doc.operations[0]
+	assert.Equal(t, "query", op.typ)
+	assert.Equal(t, "Foo", op.name)
+	assert.Len(t, op.variables, 2)
+	assert.Equal(t, []interface{}{"x"}, op.variables[0].value)
+
+	sel := op.selection[0]
+	assert.Equal(t, "alias", sel.alias)
+	assert.Equal(t, "field", sel.name)
+	assert.Equal(t, map[string]interface{}{
+		"a": graphQLVariableRef("a"),
+		"b": json.Number("-1.5e3"),
+		"c": "A\n",
+		"d": "block\n  string",
+		"e": map[string]interface{}{
+			"f": []interface{}{true, nil, "ENUM"},
+		},
+	}, sel.arguments)
+	assert.Len(t, sel.directives, 1)
+	assert.Len(t, sel.selection, 3)
+	assert.Equal(t, "Frag", sel.selection[0].spread)
+	assert.Equal(t, "Bar", sel.selection[1].on)
+	assert.True(t, sel.selection[2].inline)
+
+	for src, msg := range map[string]string{
+		``:                           "syntax error at 1:1: missing operation",
+		`{}`:                         "syntax error at 1:3: empty selection set",
+		`{ a(b: $c) }`:               "",
+		`query ($a: Int = $b) { a }`: "syntax error at 1:18: unexpected variable",
+		`{ ...Foo }`:                 "unknown fragment \"Foo\"",
+		`foo { a }`:                  "syntax error at 1:4: unexpected \"foo\"",
+		`{ a(b: "c) }`:               "syntax error at 1:13: unterminated string",
+		`{ ...A } fragment A on B { c { ...A } }`: "fragment cycle \"A\"",
+	} {
+		_, err := parseGraphQL(src)
+		if msg == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, msg, src)
+		}
+	}
+}
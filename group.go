@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -34,6 +35,7 @@ type Group struct {
 	reporter    func(error)
 	controllers map[string]*Controller
 	actions     map[string]*GroupAction
+	apiVersions []string
 }
 
 // NewGroup creates and returns a new group.
@@ -90,6 +92,37 @@ func (g *Group) Handle(name string, a *GroupAction) {
 	g.actions[name] = a
 }
 
+// APIVersions will configure the API versions supported by the group. The
+// versions must be ordered from the oldest to the current version. A version
+// is selected by prefixing the path with it (e.g. "/api/v1/posts") or by
+// setting the "API-Version" header. Requests that do not select a version use
+// the current version.
+//
+// Controller transformers are used to convert incoming and outgoing resources
+// between the requested and the current version. Query parameters like
+// filters, sorters and sparse fieldsets are not transformed and must use the
+// current names. Atomic operations are transformed like regular requests,
+// while the GraphQL handler only supports the current version.
+func (g *Group) APIVersions(versions ...string) {
+	// check versions
+	for i, version := range versions {
+		if version == "" || strings.Contains(version, "/") || stick.Contains(versions[:i], version) {
+			panic(fmt.Sprintf(`fire: invalid API version "%s"`, version))
+		}
+	}
+
+	// set versions
+	g.apiVersions = versions
+}
+
+func (g *Group) legacyAPIVersion(version string) bool {
+	if g == nil || version == "" {
+		return false
+	}
+	index := slices.Index(g.apiVersions, version)
+	return index >= 0 && index < len(g.apiVersions)-1
+}
+
 // Endpoint will return a handler that serves requests for this group. The
 // specified prefix is used to parse the requests and generate URLs for the
 // resources.
@@ -97,6 +130,16 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 	// trim prefix
 	prefix = strings.Trim(prefix, "/")
 
+	// check API versions
+	for _, version := range g.apiVersions {
+		if g.controllers[version] != nil || g.actions[version] != nil {
+			panic(fmt.Sprintf(`fire: API version "%s" conflicts with controller or action`, version))
+		}
+	}
+	for _, controller := range g.controllers {
+		controller.checkTransformers(g.apiVersions)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// create tracer
 		tracer, tc := xo.CreateTracer(r.Context(), "fire/Group.Endpoint")
@@ -161,6 +204,33 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 		// split path
 		s := strings.Split(path, "/")
 
+		// select API version
+		var version string
+		prefix := prefix
+		if len(g.apiVersions) > 0 {
+			if stick.Contains(g.apiVersions, s[0]) {
+				// use path version and extend prefix
+				version = s[0]
+				s = s[1:]
+				prefix = strings.Trim(prefix+"/"+version, "/")
+				if len(s) == 0 || s[0] == "" {
+					xo.Abort(jsonapi.NotFound("resource not found"))
+				}
+			} else if header := r.Header.Get(APIVersionHeader); header != "" {
+				// use header version
+				if !stick.Contains(g.apiVersions, header) {
+					xo.Abort(jsonapi.BadRequest("unknown API version"))
+				}
+				version = header
+			} else {
+				// use current version
+				version = g.apiVersions[len(g.apiVersions)-1]
+			}
+
+			// set header
+			w.Header().Set(APIVersionHeader, version)
+		}
+
 		// prepare context
 		ctx := &Context{
 			Context:        r.Context(),
@@ -168,6 +238,7 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 			HTTPRequest:    r,
 			ResponseWriter: w,
 			Group:          g,
+			APIVersion:     version,
 			Tracer:         tracer,
 		}
 
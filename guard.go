@@ -0,0 +1,195 @@
+package fire
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// GuardMode defines how the index guard handles unindexed queries.
+type GuardMode int
+
+// The available guard modes.
+const (
+	// GuardReport will report unindexed queries using the group reporter and
+	// continue processing the request.
+	GuardReport GuardMode = iota + 1
+
+	// GuardReject will reject unindexed queries with a bad request error.
+	GuardReject
+)
+
+func (c *Controller) guardQuery(ctx *Context, query bson.M, sorting []string) {
+	// skip search queries as they use the text index
+	if ctx.JSONAPIRequest.Search != "" {
+		return
+	}
+
+	// collect query fields
+	fields := queryFields(query)
+
+	// collect fields added by the soft delete and tenancy mechanisms
+	var mechanismFields []string
+	if c.SoftDelete {
+		mechanismFields = append(mechanismFields, coal.L(c.Model, "fire-soft-delete", true))
+	}
+	if c.Tenancy != nil {
+		mechanismFields = append(mechanismFields, coal.L(c.Model, "fire-tenant", true))
+	}
+
+	// collect filter fields
+	filterFields := stick.Subtract(fields, mechanismFields)
+
+	// skip if no fields are used
+	if len(filterFields) == 0 && len(sorting) == 0 {
+		return
+	}
+
+	// collect sort fields
+	sortFields := make([]string, 0, len(sorting))
+	for _, field := range sorting {
+		sortFields = append(sortFields, strings.TrimPrefix(field, "-"))
+	}
+
+	// check id index
+	if stick.Contains(fields, "_id") || (len(sortFields) > 0 && sortFields[0] == "_id") {
+		return
+	}
+
+	// ignore id tie-breaker added by cursor pagination
+	if len(sorting) > 1 && sortFields[len(sortFields)-1] == "_id" {
+		sorting = sorting[:len(sorting)-1]
+	}
+
+	// collect fields filtered by the client
+	var clientFields []string
+	for key := range ctx.JSONAPIRequest.Filters {
+		// strip operator and path
+		name, _, _ := strings.Cut(key, "][")
+		name, _, _ = strings.Cut(name, ".")
+
+		// add field if used by the query
+		if field := c.meta.Attributes[name]; field != nil && stick.Contains(filterFields, field.Name) {
+			clientFields = append(clientFields, field.Name)
+		} else if field := c.meta.Relationships[name]; field != nil && stick.Contains(filterFields, field.Name) {
+			clientFields = append(clientFields, field.Name)
+		}
+	}
+
+	// check registered indexes
+	for _, index := range c.meta.Indexes {
+		// skip wildcard and partial indexes
+		if len(index.Fields) == 0 || index.Filter != nil {
+			continue
+		}
+
+		// check if the index supports filtering and sorting
+		if indexSupports(index, fields, filterFields, clientFields, sorting) {
+			return
+		}
+	}
+
+	// prepare error
+	err := xo.F("unindexed query on %s (filter: %s, sort: %s)", c.meta.PluralName, strings.Join(filterFields, ","), strings.Join(sortFields, ","))
+
+	// handle error
+	switch c.IndexGuard {
+	case GuardReport:
+		if ctx.Group != nil && ctx.Group.reporter != nil {
+			ctx.Group.reporter(err)
+		}
+	case GuardReject:
+		xo.Abort(jsonapi.BadRequest("unindexed query"))
+	}
+}
+
+func indexSupports(index coal.Index, fields, filterFields, clientFields, sorting []string) bool {
+	// consume query fields from the index prefix
+	pos := 0
+	for pos < len(index.Fields) && stick.Contains(fields, index.Fields[pos]) {
+		pos++
+	}
+
+	// require a filter field in the index prefix for filtered queries
+	if len(filterFields) > 0 && len(stick.Intersect(index.Fields[:pos], filterFields)) == 0 {
+		return false
+	}
+
+	// require all client filter fields in the index prefix
+	if !stick.Includes(index.Fields[:pos], clientFields) {
+		return false
+	}
+
+	// check that the sort fields follow the filter prefix
+	var direction int32
+	for _, field := range sorting {
+		// skip fields that are already covered by the filter prefix
+		name := strings.TrimPrefix(field, "-")
+		if stick.Contains(index.Fields[:pos], name) {
+			continue
+		}
+
+		// check field
+		if pos >= len(index.Fields) || index.Fields[pos] != name {
+			return false
+		}
+
+		// get relative direction
+		dir, _ := index.Keys[pos].Value.(int32)
+		if strings.HasPrefix(field, "-") {
+			dir = -dir
+		}
+
+		// check that the index is traversed in one direction
+		if direction != 0 && dir != direction {
+			return false
+		}
+		direction = dir
+		pos++
+	}
+
+	return true
+}
+
+func queryFields(query bson.M) []string {
+	// collect fields
+	var fields []string
+	for key, value := range query {
+		// handle conjunctions
+		if key == "$and" {
+			switch value := value.(type) {
+			case []bson.M:
+				for _, sub := range value {
+					fields = append(fields, queryFields(sub)...)
+				}
+			case bson.A:
+				for _, sub := range value {
+					if sub, ok := sub.(bson.M); ok {
+						fields = append(fields, queryFields(sub)...)
+					}
+				}
+			}
+			continue
+		}
+
+		// skip other operators
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		// add field
+		fields = append(fields, key)
+	}
+
+	// sort fields
+	fields = stick.Unique(fields)
+	sort.Strings(fields)
+
+	return fields
+}
@@ -0,0 +1,120 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+)
+
+func init() {
+	coal.AddIndex(&productModel{}, false, 0, "Name")
+	coal.AddIndex(&productModel{}, false, 0, "Stock", "-ReleaseDate")
+	coal.AddPartialIndex(&productModel{}, false, 0, []string{"Price"}, bson.M{
+		"Stock": bson.M{"$gt": 0},
+	})
+	coal.AddIndex(&projectModel{}, false, 0, "Tenant")
+}
+
+func TestIndexGuard(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:      &productModel{},
+			Filters:    []string{"Name", "Price", "Stock"},
+			Sorters:    []string{"Name", "Price", "Stock", "ReleaseDate"},
+			IndexGuard: GuardReject,
+		})
+
+		tester.Insert(&productModel{
+			Name:  "Foo",
+			Stock: 1,
+		})
+
+		for _, query := range []string{
+			"",
+			"?filter[name]=Foo",
+			"?filter[stock][gt]=0",
+			"?sort=name",
+			"?sort=-name",
+			"?sort=stock",
+			"?sort=stock,-release-date",
+			"?sort=-stock,release-date",
+			"?filter[name]=Foo&sort=name",
+			"?filter[stock]=1&sort=-release-date",
+		} {
+			tester.Request("GET", "products"+query, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			})
+		}
+
+		for _, query := range []string{
+			"?sort=price",
+			"?filter[price][gt]=1",
+			"?filter[price][gt]=1&sort=-price",
+			"?filter[price][gt]=1&sort=name",
+			"?filter[name]=Foo&sort=price",
+			"?filter[name]=Foo&sort=stock",
+			"?sort=release-date",
+			"?sort=stock,release-date",
+			"?filter[name]=Foo&filter[stock]=1",
+		} {
+			tester.Request("GET", "products"+query, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, "unindexed query", gjson.Get(r.Body.String(), "errors.0.detail").String())
+			})
+		}
+
+		group := tester.Assign("", &Controller{
+			Model:      &productModel{},
+			Filters:    []string{"Name", "Price", "Stock"},
+			Sorters:    []string{"Name", "Price", "Stock"},
+			IndexGuard: GuardReport,
+		})
+
+		var errs []string
+		group.reporter = func(err error) {
+			errs = append(errs, err.Error())
+		}
+
+		tester.Request("GET", "products?filter[name]=Foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "products?filter[price][gt]=1&sort=-price", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, []string{
+			"unindexed query on products (filter: Price, sort: Price)",
+		}, errs)
+	})
+}
+
+func TestIndexGuardTenancy(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:   &projectModel{},
+			Filters: []string{"Name"},
+			Tenancy: func(ctx *Context) (coal.ID, error) {
+				return coal.MustFromHex("6ad24f2a710aae66897e9b70"), nil
+			},
+			IndexGuard: GuardReject,
+		}, &Controller{
+			Model: &taskModel{},
+		})
+
+		tester.Request("GET", "projects", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "projects?filter[name]=Foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "unindexed query", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+	})
+}
@@ -0,0 +1,209 @@
+package fire
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/glut"
+	"github.com/256dpi/fire/stick"
+)
+
+// IdempotencyKeyHeader is the header used to submit idempotency keys.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyReplayedHeader is the header set on replayed responses.
+const IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+type idempotencyValue struct {
+	glut.Base          `json:"-" glut:"fire/idempotency/,0"`
+	Key                string      `json:"key"`
+	Fingerprint        string      `json:"fingerprint"`
+	Completed          bool        `json:"completed"`
+	Status             int         `json:"status"`
+	Header             http.Header `json:"header"`
+	Body               []byte      `json:"body"`
+	Deadline           *time.Time  `json:"-"`
+	stick.NoValidation `json:"-"`
+}
+
+func (v *idempotencyValue) GetExtension() string {
+	return v.Key
+}
+
+func (v *idempotencyValue) GetDeadline() *time.Time {
+	return v.Deadline
+}
+
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *idempotencyRecorder) WriteHeader(status int) {
+	// record status
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *idempotencyRecorder) Write(data []byte) (int, error) {
+	// record implicit status
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	// record body
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
+
+// Idempotent wraps the provided action to support idempotency keys. If a
+// request provides an "Idempotency-Key" header, the status, headers and body
+// of the first successful response are stored in the provided store using
+// glut and replayed for subsequent requests with the same key for the
+// specified retention. Concurrent requests with the same key are rejected
+// with a conflict error while the first request is still in flight. Keys are
+// scoped to the identity returned by the scope function, the request method
+// and path. Reusing a key with a different request body is rejected. Requests
+// without the header are not affected and failed requests may be retried with
+// the same key.
+//
+// The scope function should identify the requester, e.g. using ash.RequestKey
+// to derive the key from the ash identity or flame client.
+//
+// Note: The retention must be longer than the action timeout.
+func Idempotent(store *coal.Store, retention time.Duration, scope func(ctx *Context) (string, error), action *Action) *Action {
+	// check scope
+	if scope == nil {
+		panic("fire: missing idempotency scope")
+	}
+
+	// prepare action
+	wrapped := &Action{
+		Methods:   action.Methods,
+		BodyLimit: action.BodyLimit,
+		Timeout:   action.Timeout,
+	}
+
+	// set handler
+	wrapped.Handler = func(ctx *Context) error {
+		// trace
+		ctx.Tracer.Push("fire/Idempotent")
+		defer ctx.Tracer.Pop()
+
+		// get key
+		key := ctx.HTTPRequest.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return action.Handler(ctx)
+		}
+
+		// get identity
+		identity, err := scope(ctx)
+		if err != nil {
+			return err
+		}
+
+		// read body
+		body, err := io.ReadAll(ctx.HTTPRequest.Body)
+		if err != nil {
+			return err
+		}
+
+		// restore body
+		ctx.HTTPRequest.Body = io.NopCloser(bytes.NewReader(body))
+
+		// compute fingerprint
+		hash := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(hash[:])
+
+		// prepare value
+		value := &idempotencyValue{
+			Key:      identity + " " + ctx.HTTPRequest.Method + " " + ctx.HTTPRequest.URL.Path + " " + key,
+			Deadline: stick.P(time.Now().Add(retention)),
+		}
+
+		// get lock timeout
+		timeout := wrapped.Timeout
+		if timeout == 0 {
+			timeout = 30 * time.Second
+		}
+
+		// lock value
+		locked, err := glut.Lock(ctx, store, value, timeout)
+		if err != nil {
+			return err
+		} else if !locked {
+			return jsonapi.ErrorFromStatus(http.StatusConflict, "request with same idempotency key in progress")
+		}
+
+		// ensure unlock, also if the request has been cancelled
+		defer func() {
+			uc, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+			defer cancel()
+			_, _ = glut.Unlock(uc, store, value)
+		}()
+
+		// check fingerprint
+		if value.Fingerprint != "" && value.Fingerprint != fingerprint {
+			return jsonapi.ErrorFromStatus(http.StatusUnprocessableEntity, "idempotency key reused with different request")
+		}
+
+		// replay completed response
+		if value.Completed {
+			for name, values := range value.Header {
+				ctx.ResponseWriter.Header()[name] = values
+			}
+			ctx.ResponseWriter.Header().Set(IdempotencyReplayedHeader, "true")
+			ctx.ResponseWriter.WriteHeader(value.Status)
+			if len(value.Body) > 0 {
+				_, err = ctx.ResponseWriter.Write(value.Body)
+			}
+			return err
+		}
+
+		// wrap response writer
+		writer := ctx.ResponseWriter
+		recorder := &idempotencyRecorder{ResponseWriter: writer}
+		ctx.ResponseWriter = recorder
+		defer func() {
+			ctx.ResponseWriter = writer
+		}()
+
+		// call handler
+		err = action.Handler(ctx)
+		if err != nil {
+			return err
+		}
+
+		// store response
+		value.Fingerprint = fingerprint
+		value.Completed = true
+		value.Status = recorder.status
+		value.Header = recorder.Header().Clone()
+		value.Body = recorder.body.Bytes()
+		if value.Status == 0 {
+			value.Status = http.StatusOK
+		}
+		_, err = glut.SetLocked(ctx, store, value)
+		if err != nil {
+			return xo.W(err)
+		}
+
+		return nil
+	}
+
+	return wrapped
+}
@@ -0,0 +1,176 @@
+package fire
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire/glut"
+)
+
+func idempotencyScope(ctx *Context) (string, error) {
+	return ctx.HTTPRequest.Header.Get("User"), nil
+}
+
+func TestIdempotent(t *testing.T) {
+	assert.PanicsWithValue(t, "fire: missing idempotency scope", func() {
+		Idempotent(lungoStore, time.Hour, nil, A("pay", []string{"POST"}, 0, 0, func(ctx *Context) error {
+			return nil
+		}))
+	})
+
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var mutex sync.Mutex
+		var calls int
+		var wait chan struct{}
+		group := tester.Assign("", &Controller{
+			Model: &postModel{},
+			CollectionActions: M{
+				"pay": Idempotent(tester.Store, time.Hour, idempotencyScope, A("pay", []string{"POST"}, 0, 0, func(ctx *Context) error {
+					mutex.Lock()
+					calls++
+					n := calls
+					ch := wait
+					mutex.Unlock()
+
+					if ch != nil {
+						<-ch
+					}
+
+					body, err := io.ReadAll(ctx.HTTPRequest.Body)
+					if err != nil {
+						return err
+					}
+
+					if string(body) == "fail" {
+						return jsonapi.BadRequest("failed")
+					}
+
+					ctx.ResponseWriter.Header().Set("Payment", "paid")
+					ctx.ResponseWriter.WriteHeader(http.StatusCreated)
+					_, err = ctx.ResponseWriter.Write([]byte(fmt.Sprintf(`{"call":%d}`, n)))
+					return err
+				})),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		group.Handle("pay", &GroupAction{
+			Action: Idempotent(tester.Store, time.Hour, idempotencyScope, A("pay", []string{"POST"}, 0, 0, func(ctx *Context) error {
+				mutex.Lock()
+				calls++
+				mutex.Unlock()
+				ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+				return nil
+			})),
+		})
+
+		// without key
+		for i := 1; i <= 2; i++ {
+			tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, int64(i), gjson.Get(r.Body.String(), "call").Int())
+				assert.Empty(t, r.Header().Get(IdempotencyReplayedHeader))
+			})
+		}
+
+		tester.Header[IdempotencyKeyHeader] = "key1"
+
+		// first request
+		tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(3), gjson.Get(r.Body.String(), "call").Int())
+			assert.Equal(t, "paid", r.Header().Get("Payment"))
+			assert.Empty(t, r.Header().Get(IdempotencyReplayedHeader))
+		})
+
+		// replayed request
+		tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(3), gjson.Get(r.Body.String(), "call").Int())
+			assert.Equal(t, "paid", r.Header().Get("Payment"))
+			assert.Equal(t, "true", r.Header().Get(IdempotencyReplayedHeader))
+		})
+
+		// different request
+		tester.Request("POST", "posts/pay", "bar", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnprocessableEntity, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// different user
+		tester.Header["User"] = "other"
+		tester.Request("POST", "posts/pay", "bar", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(4), gjson.Get(r.Body.String(), "call").Int())
+			assert.Empty(t, r.Header().Get(IdempotencyReplayedHeader))
+		})
+		delete(tester.Header, "User")
+
+		assert.Equal(t, 4, calls)
+
+		tester.Header[IdempotencyKeyHeader] = "key2"
+
+		// failed request
+		tester.Request("POST", "posts/pay", "fail", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// retried request
+		tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(6), gjson.Get(r.Body.String(), "call").Int())
+		})
+
+		tester.Header[IdempotencyKeyHeader] = "key3"
+
+		// concurrent requests
+		mutex.Lock()
+		wait = make(chan struct{})
+		mutex.Unlock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, int64(7), gjson.Get(r.Body.String(), "call").Int())
+			})
+		}()
+		assert.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return calls == 7
+		}, time.Second, time.Millisecond)
+		tester.Request("POST", "posts/pay", "foo", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusConflict, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		close(wait)
+		<-done
+
+		mutex.Lock()
+		wait = nil
+		mutex.Unlock()
+
+		// group action
+		for i := 0; i < 2; i++ {
+			tester.Request("POST", "pay", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			})
+		}
+
+		assert.Equal(t, 8, calls)
+		assert.Equal(t, 5, tester.Count(&glut.Model{}))
+	})
+}
@@ -0,0 +1,527 @@
+package fire
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+
+	"github.com/256dpi/fire/stick"
+)
+
+var openAPIMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// OpenAPI will generate an OpenAPI 3.1 document that describes the controllers
+// and actions of the group. The specified prefix is used to generate the paths
+// and should match the prefix used with Endpoint. The document may be encoded
+// using the standard JSON encoder and served by a group action.
+//
+// Note: The supported operations are determined by calling the Supported
+// matcher of each controller with a context that only has the operation set.
+// Operations are assumed to be supported if the matcher panics.
+func (g *Group) OpenAPI(prefix, title, version string) stick.Map {
+	// trim prefix
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix = "/" + prefix
+	}
+
+	// prepare paths and schemas
+	paths := stick.Map{}
+	schemas := stick.Map{
+		"error": stick.Map{
+			"type": "object",
+			"properties": stick.Map{
+				"status": stick.Map{"type": "string"},
+				"title":  stick.Map{"type": "string"},
+				"detail": stick.Map{"type": "string"},
+				"source": stick.Map{
+					"type": "object",
+					"properties": stick.Map{
+						"pointer":   stick.Map{"type": "string"},
+						"parameter": stick.Map{"type": "string"},
+					},
+				},
+			},
+		},
+		"errors": stick.Map{
+			"type": "object",
+			"properties": stick.Map{
+				"errors": stick.Map{
+					"type":  "array",
+					"items": openAPIRef("error"),
+				},
+			},
+		},
+	}
+
+	// add controllers
+	for _, name := range sortedKeys(g.controllers) {
+		g.controllers[name].openAPI(prefix, paths, schemas)
+	}
+
+	// add group actions
+	for _, name := range sortedKeys(g.actions) {
+		paths[prefix+"/"+name] = openAPIAction(name, "", g.actions[name].Action, nil)
+	}
+
+	return stick.Map{
+		"openapi": "3.1.0",
+		"info": stick.Map{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": stick.Map{
+			"schemas": schemas,
+			"responses": stick.Map{
+				"error": stick.Map{
+					"description": "error",
+					"content":     openAPIContent(openAPIRef("errors")),
+				},
+			},
+		},
+	}
+}
+
+func (c *Controller) openAPI(prefix string, paths, schemas stick.Map) {
+	// get name
+	name := c.meta.PluralName
+
+	// check supported operations
+	supported := map[Operation]bool{}
+	for _, op := range []Operation{List, Find, Create, Update, Delete} {
+		supported[op] = c.openAPISupported(op)
+	}
+
+	// prepare attributes
+	attributes := stick.Map{}
+	for _, field := range c.meta.Attributes {
+		attributes[field.JSONKey] = openAPISchema(field.Type, schemas)
+	}
+
+	// add properties
+	ptrType := reflect.PtrTo(c.meta.Type)
+	for property, key := range c.Properties {
+		method, _ := ptrType.MethodByName(property)
+		schema := openAPISchema(method.Type.Out(0), schemas)
+		schema["readOnly"] = true
+		attributes[key] = schema
+	}
+
+	// add batch properties
+	for key := range c.BatchProperties {
+		attributes[key] = stick.Map{"readOnly": true}
+	}
+
+	// prepare relationships
+	relationships := stick.Map{}
+	for _, field := range c.meta.Relationships {
+		// prepare identifier
+		identifier := stick.Map{
+			"type": "object",
+			"properties": stick.Map{
+				"type": stick.Map{"const": field.RelType},
+				"id":   stick.Map{"type": "string"},
+			},
+			"required": []string{"type", "id"},
+		}
+
+		// prepare linkage
+		var linkage stick.Map
+		switch {
+		case field.ToOne && field.Optional:
+			linkage = stick.Map{"oneOf": []stick.Map{identifier, {"type": "null"}}}
+		case field.ToOne:
+			linkage = identifier
+		case field.ToMany:
+			linkage = stick.Map{"type": "array", "items": identifier}
+		}
+
+		// prepare relationship
+		relationship := stick.Map{
+			"type": "object",
+			"properties": stick.Map{
+				"links": stick.Map{"type": "object", "readOnly": true},
+			},
+		}
+		if linkage != nil {
+			relationship["properties"].(stick.Map)["data"] = linkage
+		}
+
+		relationships[field.RelName] = relationship
+	}
+
+	// add resource schema
+	schemas[name] = stick.Map{
+		"type": "object",
+		"properties": stick.Map{
+			"type":          stick.Map{"const": name},
+			"id":            stick.Map{"type": "string"},
+			"attributes":    stick.Map{"type": "object", "properties": attributes},
+			"relationships": stick.Map{"type": "object", "properties": relationships},
+			"links":         stick.Map{"type": "object", "readOnly": true},
+		},
+		"required": []string{"type"},
+	}
+
+	// prepare documents
+	one := openAPIContent(stick.Map{
+		"type": "object",
+		"properties": stick.Map{
+			"data": openAPIRef(name),
+		},
+	})
+	many := openAPIContent(stick.Map{
+		"type": "object",
+		"properties": stick.Map{
+			"data":  stick.Map{"type": "array", "items": openAPIRef(name)},
+			"links": stick.Map{"type": "object"},
+		},
+	})
+
+	// prepare parameters
+	id := stick.Map{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   stick.Map{"type": "string"},
+	}
+	query := []stick.Map{
+		openAPIQuery("include", "A comma separated list of relationship paths to include."),
+		openAPIQuery("fields["+name+"]", "A comma separated list of fields to return."),
+	}
+
+	// prepare collection and resource paths
+	collection := stick.Map{}
+	resource := stick.Map{}
+	paths[prefix+"/"+name] = collection
+	paths[prefix+"/"+name+"/{id}"] = resource
+
+	// add list operation
+	if supported[List] {
+		params := append([]stick.Map{}, query...)
+		for _, filter := range c.Filters {
+			field := c.meta.Fields[filter]
+			key := field.JSONKey
+			if field.RelName != "" {
+				key = field.RelName
+			}
+			params = append(params, openAPIQuery("filter["+key+"]", "Filter by "+key+"."))
+		}
+		if len(c.Sorters) > 0 {
+			var keys []string
+			for _, sorter := range c.Sorters {
+				keys = append(keys, c.meta.Fields[sorter].JSONKey)
+			}
+			params = append(params, openAPIQuery("sort", "A comma separated list of sort keys (prefix with - for descending order): "+strings.Join(keys, ", ")+"."))
+		}
+		if c.Search {
+			params = append(params, openAPIQuery("search", "A full text search query."))
+		}
+		if c.CursorPagination {
+			params = append(params, openAPIQuery("page[after]", "The cursor after which to return resources."))
+			params = append(params, openAPIQuery("page[before]", "The cursor before which to return resources."))
+		} else {
+			params = append(params, openAPIQuery("page[number]", "The page number."))
+		}
+		params = append(params, openAPIQuery("page[size]", "The page size."))
+		collection["get"] = openAPIOperation("list-"+name, name, params, nil, http.StatusOK, many)
+	}
+
+	// add create operation
+	if supported[Create] {
+		collection["post"] = openAPIOperation("create-"+name, name, query, one, http.StatusCreated, one)
+	}
+
+	// add find, update and delete operations
+	params := append([]stick.Map{id}, query...)
+	if supported[Find] {
+		resource["get"] = openAPIOperation("find-"+name, name, params, nil, http.StatusOK, one)
+	}
+	if supported[Update] {
+		resource["patch"] = openAPIOperation("update-"+name, name, params, one, http.StatusOK, one)
+	}
+	if supported[Delete] {
+		resource["delete"] = openAPIOperation("delete-"+name, name, []stick.Map{id}, nil, http.StatusNoContent, nil)
+	}
+
+	// add relationship operations
+	for _, rel := range sortedKeys(c.meta.Relationships) {
+		// get field
+		field := c.meta.Relationships[rel]
+
+		// prepare documents
+		var related, linkage stick.Map
+		if field.ToOne || field.HasOne {
+			related = openAPIContent(stick.Map{
+				"type": "object",
+				"properties": stick.Map{
+					"data": stick.Map{"oneOf": []stick.Map{openAPIRef(field.RelType), {"type": "null"}}},
+				},
+			})
+		} else {
+			related = openAPIContent(stick.Map{
+				"type": "object",
+				"properties": stick.Map{
+					"data": stick.Map{"type": "array", "items": openAPIRef(field.RelType)},
+				},
+			})
+		}
+		if field.ToOne || field.ToMany {
+			linkage = openAPIContent(relationships[rel].(stick.Map))
+		}
+
+		// prepare paths
+		relatedPath := stick.Map{}
+		relationshipPath := stick.Map{}
+		paths[prefix+"/"+name+"/{id}/"+rel] = relatedPath
+		paths[prefix+"/"+name+"/{id}/relationships/"+rel] = relationshipPath
+
+		// add read operations
+		if supported[Find] {
+			relatedPath["get"] = openAPIOperation("get-"+name+"-"+rel, name, []stick.Map{id}, nil, http.StatusOK, related)
+			relationshipPath["get"] = openAPIOperation("get-"+name+"-relationship-"+rel, name, []stick.Map{id}, nil, http.StatusOK, linkage)
+		}
+
+		// add write operations
+		if supported[Update] && linkage != nil {
+			relationshipPath["patch"] = openAPIOperation("set-"+name+"-relationship-"+rel, name, []stick.Map{id}, linkage, http.StatusOK, linkage)
+			if field.ToMany {
+				relationshipPath["post"] = openAPIOperation("append-to-"+name+"-relationship-"+rel, name, []stick.Map{id}, linkage, http.StatusOK, linkage)
+				relationshipPath["delete"] = openAPIOperation("remove-from-"+name+"-relationship-"+rel, name, []stick.Map{id}, linkage, http.StatusOK, linkage)
+			}
+		}
+	}
+
+	// add collection actions
+	for _, action := range sortedKeys(c.CollectionActions) {
+		paths[prefix+"/"+name+"/"+action] = openAPIAction(action, name, c.CollectionActions[action], nil)
+	}
+
+	// add resource actions
+	for _, action := range sortedKeys(c.ResourceActions) {
+		paths[prefix+"/"+name+"/{id}/"+action] = openAPIAction(action, name, c.ResourceActions[action], id)
+	}
+}
+
+func (c *Controller) openAPISupported(op Operation) (ok bool) {
+	// assume support if the matcher requires a request
+	defer func() {
+		if recover() != nil {
+			ok = true
+		}
+	}()
+
+	return c.Supported(&Context{
+		Operation:      op,
+		Data:           stick.Map{},
+		JSONAPIRequest: &jsonapi.Request{},
+	})
+}
+
+func openAPIAction(name, tag string, action *Action, id stick.Map) stick.Map {
+	// prepare parameters
+	var params []stick.Map
+	if id != nil {
+		params = append(params, id)
+	}
+
+	// prepare operation id
+	operationID := name
+	if tag != "" {
+		operationID = tag + "-" + name
+	}
+
+	// add methods
+	path := stick.Map{}
+	for _, method := range action.Methods {
+		operation := openAPIOperation(strings.ToLower(method)+"-"+operationID, tag, params, nil, http.StatusOK, nil)
+		operation["responses"].(stick.Map)["200"] = stick.Map{
+			"description": "action response",
+		}
+		path[strings.ToLower(method)] = operation
+	}
+
+	return path
+}
+
+func openAPIOperation(id, tag string, params []stick.Map, request stick.Map, status int, response stick.Map) stick.Map {
+	// prepare response
+	res := stick.Map{
+		"description": strings.ToLower(http.StatusText(status)),
+	}
+	if response != nil {
+		res["content"] = response
+	}
+
+	// prepare operation
+	operation := stick.Map{
+		"operationId": id,
+		"responses": stick.Map{
+			fmt.Sprintf("%d", status): res,
+			"default":                 openAPIRef("error", "responses"),
+		},
+	}
+
+	// set tag
+	if tag != "" {
+		operation["tags"] = []string{tag}
+	}
+
+	// set parameters
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+
+	// set request body
+	if request != nil {
+		operation["requestBody"] = stick.Map{
+			"required": true,
+			"content":  request,
+		}
+	}
+
+	return operation
+}
+
+func openAPIQuery(name, description string) stick.Map {
+	return stick.Map{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      stick.Map{"type": "string"},
+	}
+}
+
+func openAPIContent(schema stick.Map) stick.Map {
+	return stick.Map{
+		jsonapi.MediaType: stick.Map{
+			"schema": schema,
+		},
+	}
+}
+
+func openAPIRef(name string, kind ...string) stick.Map {
+	// get kind
+	k := "schemas"
+	if len(kind) > 0 {
+		k = kind[0]
+	}
+
+	return stick.Map{
+		"$ref": "#/components/" + k + "/" + name,
+	}
+}
+
+func openAPISchema(typ reflect.Type, schemas stick.Map) stick.Map {
+	return openAPITypeSchema(typ, schemas, map[reflect.Type]bool{})
+}
+
+func openAPITypeSchema(typ reflect.Type, schemas stick.Map, stack map[reflect.Type]bool) stick.Map {
+	// handle pointers
+	if typ.Kind() == reflect.Ptr {
+		schema := openAPITypeSchema(typ.Elem(), schemas, stack)
+		if kind, ok := schema["type"]; ok {
+			schema["type"] = []interface{}{kind, "null"}
+		}
+		return schema
+	}
+
+	// handle special types
+	switch {
+	case typ == timeType:
+		return stick.Map{"type": "string", "format": "date-time"}
+	case typ == idType:
+		return stick.Map{"type": "string"}
+	case typ.Implements(openAPIMarshalerType) || reflect.PtrTo(typ).Implements(openAPIMarshalerType):
+		return stick.Map{}
+	}
+
+	// handle kinds
+	switch typ.Kind() {
+	case reflect.Bool:
+		return stick.Map{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return stick.Map{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return stick.Map{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return stick.Map{"type": "number"}
+	case reflect.String:
+		return stick.Map{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return stick.Map{"type": "string", "contentEncoding": "base64"}
+		}
+		return stick.Map{"type": "array", "items": openAPITypeSchema(typ.Elem(), schemas, stack)}
+	case reflect.Map:
+		return stick.Map{"type": "object", "additionalProperties": openAPITypeSchema(typ.Elem(), schemas, stack)}
+	case reflect.Struct:
+		// reference self-referential types
+		name := openAPITypeName(typ)
+		if _, ok := stack[typ]; ok {
+			stack[typ] = true
+			return openAPIRef(name)
+		}
+		stack[typ] = false
+
+		properties := stick.Map{}
+		for i := 0; i < typ.NumField(); i++ {
+			// get field
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			// get key
+			key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if key == "-" {
+				continue
+			} else if key == "" {
+				key = field.Name
+			}
+
+			properties[key] = openAPITypeSchema(field.Type, schemas, stack)
+		}
+		schema := stick.Map{"type": "object", "properties": properties}
+
+		// store referenced types as components
+		referenced := stack[typ]
+		delete(stack, typ)
+		if referenced {
+			schemas[name] = schema
+			return openAPIRef(name)
+		}
+
+		return schema
+	}
+
+	return stick.Map{}
+}
+
+func openAPITypeName(typ reflect.Type) string {
+	return "type-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, typ.String())
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	// collect keys
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	// sort keys
+	sort.Strings(keys)
+
+	return keys
+}
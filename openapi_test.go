@@ -0,0 +1,353 @@
+package fire
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func TestGroupOpenAPI(t *testing.T) {
+	group := NewGroup(nil)
+
+	group.Add(&Controller{
+		Model:   &postModel{},
+		Store:   lungoStore,
+		Filters: []string{"Published"},
+		Sorters: []string{"Title"},
+		Properties: map[string]string{
+			"Virtual": "virtual",
+		},
+		BatchProperties: map[string]BatchProperty{
+			"batch": func(*Context, []coal.Model) (map[coal.ID]interface{}, error) {
+				return nil, nil
+			},
+		},
+		CollectionActions: M{
+			"stats": A("stats", []string{"GET"}, 0, 0, func(*Context) error {
+				return nil
+			}),
+		},
+		ResourceActions: M{
+			"publish": A("publish", []string{"POST"}, 0, 0, func(*Context) error {
+				return nil
+			}),
+		},
+	}, &Controller{
+		Model:     &commentModel{},
+		Store:     lungoStore,
+		Filters:   []string{"Post"},
+		Supported: Only(List | Find),
+	})
+
+	group.Handle("operations", &GroupAction{
+		Action: AtomicOperations(lungoStore),
+	})
+
+	doc := group.OpenAPI("/api/", "Test", "1.0")
+
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	assert.Equal(t, stick.Map{
+		"title":   "Test",
+		"version": "1.0",
+	}, doc["info"])
+
+	paths := doc["paths"].(stick.Map)
+
+	var keys []string
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{
+		"/api/comments",
+		"/api/comments/{id}",
+		"/api/comments/{id}/parent",
+		"/api/comments/{id}/post",
+		"/api/comments/{id}/relationships/parent",
+		"/api/comments/{id}/relationships/post",
+		"/api/operations",
+		"/api/posts",
+		"/api/posts/stats",
+		"/api/posts/{id}",
+		"/api/posts/{id}/comments",
+		"/api/posts/{id}/note",
+		"/api/posts/{id}/publish",
+		"/api/posts/{id}/relationships/comments",
+		"/api/posts/{id}/relationships/note",
+		"/api/posts/{id}/relationships/selections",
+		"/api/posts/{id}/selections",
+	}, keys)
+
+	assert.Len(t, paths["/api/posts"], 2)
+	assert.Len(t, paths["/api/posts/{id}"], 3)
+	assert.Len(t, paths["/api/comments"], 1)
+	assert.Len(t, paths["/api/comments/{id}"], 1)
+	assert.Len(t, paths["/api/comments/{id}/relationships/post"], 1)
+	assert.Len(t, paths["/api/posts/stats"], 1)
+	assert.Len(t, paths["/api/posts/{id}/publish"], 1)
+	assert.Len(t, paths["/api/operations"], 1)
+
+	var params []string
+	for _, param := range paths["/api/posts"].(stick.Map)["get"].(stick.Map)["parameters"].([]stick.Map) {
+		params = append(params, param["name"].(string))
+	}
+	assert.Equal(t, []string{
+		"include",
+		"fields[posts]",
+		"filter[published]",
+		"sort",
+		"page[number]",
+		"page[size]",
+	}, params)
+
+	buf, err := json.Marshal(doc["components"].(stick.Map)["schemas"].(stick.Map)["posts"])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"type": {
+				"const": "posts"
+			},
+			"id": {
+				"type": "string"
+			},
+			"attributes": {
+				"type": "object",
+				"properties": {
+					"title": {
+						"type": "string"
+					},
+					"published": {
+						"type": "boolean"
+					},
+					"text-body": {
+						"type": "string"
+					},
+					"virtual": {
+						"type": "integer",
+						"readOnly": true
+					},
+					"batch": {
+						"readOnly": true
+					}
+				}
+			},
+			"relationships": {
+				"type": "object",
+				"properties": {
+					"comments": {
+						"type": "object",
+						"properties": {
+							"links": {
+								"type": "object",
+								"readOnly": true
+							}
+						}
+					},
+					"note": {
+						"type": "object",
+						"properties": {
+							"links": {
+								"type": "object",
+								"readOnly": true
+							}
+						}
+					},
+					"selections": {
+						"type": "object",
+						"properties": {
+							"links": {
+								"type": "object",
+								"readOnly": true
+							}
+						}
+					}
+				}
+			},
+			"links": {
+				"type": "object",
+				"readOnly": true
+			}
+		},
+		"required": ["type"]
+	}`, string(buf))
+
+	buf, err = json.Marshal(doc["components"].(stick.Map)["schemas"].(stick.Map)["comments"].(stick.Map)["properties"].(stick.Map)["relationships"])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"parent": {
+				"type": "object",
+				"properties": {
+					"data": {
+						"oneOf": [
+							{
+								"type": "object",
+								"properties": {
+									"type": {
+										"const": "comments"
+									},
+									"id": {
+										"type": "string"
+									}
+								},
+								"required": ["type", "id"]
+							},
+							{
+								"type": "null"
+							}
+						]
+					},
+					"links": {
+						"type": "object",
+						"readOnly": true
+					}
+				}
+			},
+			"post": {
+				"type": "object",
+				"properties": {
+					"data": {
+						"type": "object",
+						"properties": {
+							"type": {
+								"const": "posts"
+							},
+							"id": {
+								"type": "string"
+							}
+						},
+						"required": ["type", "id"]
+					},
+					"links": {
+						"type": "object",
+						"readOnly": true
+					}
+				}
+			}
+		}
+	}`, string(buf))
+
+	_, err = json.Marshal(doc)
+	assert.NoError(t, err)
+}
+
+func TestOpenAPISchema(t *testing.T) {
+	type item struct {
+		Name   string             `json:"name"`
+		Skip   string             `json:"-"`
+		Count  *int               `json:"count,omitempty"`
+		Tags   []string           `json:"tags"`
+		Data   []byte             `json:"data"`
+		Extra  map[string]uint    `json:"extra"`
+		Value  stick.Map          `json:"value"`
+		Nested []struct{ A bool } `json:"nested"`
+	}
+
+	buf, err := json.Marshal(openAPISchema(reflect.TypeOf(item{}), stick.Map{}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"name": {
+				"type": "string"
+			},
+			"count": {
+				"type": ["integer", "null"]
+			},
+			"tags": {
+				"type": "array",
+				"items": {
+					"type": "string"
+				}
+			},
+			"data": {
+				"type": "string",
+				"contentEncoding": "base64"
+			},
+			"extra": {
+				"type": "object",
+				"additionalProperties": {
+					"type": "integer",
+					"minimum": 0
+				}
+			},
+			"value": {
+				"type": "object",
+				"additionalProperties": {}
+			},
+			"nested": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"A": {
+							"type": "boolean"
+						}
+					}
+				}
+			}
+		}
+	}`, string(buf))
+}
+
+func TestOpenAPISchemaRecursive(t *testing.T) {
+	type node struct {
+		Name     string `json:"name"`
+		Parent   *node  `json:"parent"`
+		Children []node `json:"children"`
+	}
+
+	schemas := stick.Map{}
+	buf, err := json.Marshal(openAPISchema(reflect.TypeOf(node{}), schemas))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$ref": "#/components/schemas/type-fire.node"
+	}`, string(buf))
+
+	buf, err = json.Marshal(schemas)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type-fire.node": {
+			"type": "object",
+			"properties": {
+				"name": {
+					"type": "string"
+				},
+				"parent": {
+					"$ref": "#/components/schemas/type-fire.node"
+				},
+				"children": {
+					"type": "array",
+					"items": {
+						"$ref": "#/components/schemas/type-fire.node"
+					}
+				}
+			}
+		}
+	}`, string(buf))
+}
+
+func TestOpenAPISupportedPanic(t *testing.T) {
+	controller := &Controller{
+		Model: &postModel{},
+		Store: lungoStore,
+		Supported: func(ctx *Context) bool {
+			return ctx.HTTPRequest.Method == "GET"
+		},
+	}
+
+	group := NewGroup(xo.Crash)
+	group.Add(controller)
+
+	doc := group.OpenAPI("", "Test", "1.0")
+	paths := doc["paths"].(stick.Map)
+	assert.NotNil(t, paths["/posts"])
+	assert.NotNil(t, paths["/posts/{id}"])
+}
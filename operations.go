@@ -0,0 +1,299 @@
+package fire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// AtomicMediaType is the media type used by the JSON:API atomic operations
+// extension.
+const AtomicMediaType = jsonapi.MediaType + `; ext="https://jsonapi.org/ext/atomic"`
+
+type atomicRequest struct {
+	Operations []atomicOperation `json:"atomic:operations"`
+}
+
+type atomicOperation struct {
+	Op   string          `json:"op"`
+	Ref  *atomicRef      `json:"ref"`
+	Data json.RawMessage `json:"data"`
+}
+
+type atomicRef struct {
+	Type         string `json:"type"`
+	ID           string `json:"id"`
+	LID          string `json:"lid"`
+	Relationship string `json:"relationship"`
+}
+
+type atomicResponse struct {
+	Results []atomicResult `json:"atomic:results"`
+}
+
+type atomicResult struct {
+	Data *jsonapi.Resource `json:"data,omitempty"`
+}
+
+// AtomicOperations returns an action that implements the JSON:API atomic
+// operations extension. The action should be added as a group action and will
+// run all operations in the order received using the group controllers within
+// a single transaction of the provided store. Every operation is processed by
+// the controllers as a regular request and therefore runs all configured
+// callbacks. Newly created resources may be referenced in later operations
+// using local identifiers ("lid"). If an operation fails, the transaction is
+// aborted and the error is returned with a pointer to the failed operation.
+// Resources are transformed like regular requests if an older API version of
+// the group is requested.
+//
+// Note: All controllers of the group must use the provided store.
+func AtomicOperations(store *coal.Store) *Action {
+	return A("fire/AtomicOperations", []string{"POST"}, 0, 0, func(ctx *Context) error {
+		// check content type
+		if !strings.HasPrefix(ctx.HTTPRequest.Header.Get("Content-Type"), jsonapi.MediaType) {
+			xo.Abort(jsonapi.BadRequest("invalid content type header"))
+		}
+
+		// decode request
+		var req atomicRequest
+		dec := json.NewDecoder(ctx.HTTPRequest.Body)
+		dec.UseNumber()
+		err := dec.Decode(&req)
+		if err != nil {
+			xo.Abort(jsonapi.BadRequest("invalid operations document"))
+		}
+
+		// check operations
+		if len(req.Operations) == 0 {
+			xo.Abort(jsonapi.BadRequestPointer("missing operations", "/atomic:operations"))
+		}
+
+		// determine prefix from action path
+		prefix := strings.Trim(ctx.HTTPRequest.URL.Path, "/")
+		prefix = prefix[:max(0, strings.LastIndex(prefix, "/"))]
+
+		// prepare results and local identifiers
+		results := make([]atomicResult, len(req.Operations))
+		lids := map[string]string{}
+
+		// run operations in transaction
+		err = store.T(ctx.Context, false, func(tc context.Context) error {
+			return ctx.With(tc, func() error {
+				for i, op := range req.Operations {
+					results[i] = runAtomicOperation(ctx, prefix, i, op, lids)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		// write results
+		ctx.ResponseWriter.Header().Set("Content-Type", AtomicMediaType)
+		ctx.ResponseWriter.WriteHeader(http.StatusOK)
+
+		return json.NewEncoder(ctx.ResponseWriter).Encode(atomicResponse{
+			Results: results,
+		})
+	})
+}
+
+func runAtomicOperation(ctx *Context, prefix string, index int, op atomicOperation, lids map[string]string) atomicResult {
+	// trace
+	ctx.Tracer.Push("fire/runAtomicOperation")
+	defer ctx.Tracer.Pop()
+
+	// point errors to operation
+	defer xo.Resume(func(err error) {
+		// re-abort other errors
+		var jsonapiError *jsonapi.Error
+		if !errors.As(err, &jsonapiError) {
+			xo.Abort(err)
+		}
+
+		// copy error and prefix pointer
+		opError := *jsonapiError
+		pointer := fmt.Sprintf("/atomic:operations/%d", index)
+		if jsonapiError.Source != nil && strings.HasPrefix(jsonapiError.Source.Pointer, "/") {
+			pointer += jsonapiError.Source.Pointer
+		}
+		opError.Source = &jsonapi.ErrorSource{
+			Pointer: pointer,
+		}
+
+		xo.Abort(&opError)
+	})
+
+	// get reference
+	var ref atomicRef
+	if op.Ref != nil {
+		ref = *op.Ref
+	}
+
+	// resolve reference local identifier
+	if ref.LID != "" {
+		id, ok := lids[ref.Type+"/"+ref.LID]
+		if !ok {
+			xo.Abort(jsonapi.BadRequestPointer("unknown local identifier", "/ref/lid"))
+		}
+		ref.ID = id
+	}
+
+	// check operation code
+	if op.Op != "add" && op.Op != "update" && op.Op != "remove" {
+		xo.Abort(jsonapi.BadRequestPointer("invalid operation code", "/op"))
+	}
+
+	// determine if a resource is created
+	create := op.Op == "add" && ref.Relationship == ""
+
+	// prepare document
+	doc := &jsonapi.Document{}
+
+	// parse data if available
+	var newLID string
+	if len(op.Data) > 0 {
+		// decode data
+		var data interface{}
+		dec := json.NewDecoder(bytes.NewReader(op.Data))
+		dec.UseNumber()
+		err := dec.Decode(&data)
+		if err != nil {
+			xo.Abort(jsonapi.BadRequestPointer("invalid data", "/data"))
+		}
+
+		// get local identifier of created resource
+		if obj, ok := data.(map[string]interface{}); ok && create {
+			newLID, _ = obj["lid"].(string)
+			delete(obj, "lid")
+		}
+
+		// resolve local identifiers
+		resolveLocalIdentifiers(data, lids)
+
+		// encode document
+		buf, err := json.Marshal(map[string]interface{}{
+			"data": data,
+		})
+		xo.AbortIf(err)
+
+		// parse document
+		doc, err = jsonapi.ParseDocument(bytes.NewReader(buf))
+		if err != nil {
+			xo.Abort(jsonapi.BadRequestPointer("invalid data", "/data"))
+		}
+	}
+
+	// get resource type and id from data if missing
+	if ref.Type == "" && doc.Data != nil && doc.Data.One != nil {
+		ref.Type = doc.Data.One.Type
+		if !create && ref.ID == "" {
+			ref.ID = doc.Data.One.ID
+		}
+	}
+
+	// get controller
+	controller := ctx.Group.controllers[ref.Type]
+	if controller == nil {
+		xo.Abort(jsonapi.BadRequestPointer("invalid resource type", "/ref/type"))
+	}
+
+	// check resource id
+	if !create && ref.ID == "" {
+		xo.Abort(jsonapi.BadRequestPointer("missing resource id", "/ref/id"))
+	}
+
+	// prepare request
+	req := &jsonapi.Request{
+		Prefix:       prefix,
+		ResourceType: ref.Type,
+		ResourceID:   ref.ID,
+		Relationship: ref.Relationship,
+	}
+
+	// determine intent
+	switch {
+	case create:
+		req.Intent = jsonapi.CreateResource
+	case op.Op == "add":
+		req.Intent = jsonapi.AppendToRelationship
+	case op.Op == "update" && ref.Relationship != "":
+		req.Intent = jsonapi.SetRelationship
+	case op.Op == "update":
+		req.Intent = jsonapi.UpdateResource
+	case ref.Relationship != "":
+		req.Intent = jsonapi.RemoveFromRelationship
+	default:
+		req.Intent = jsonapi.DeleteResource
+	}
+
+	// prepare context
+	subCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		HTTPRequest:    ctx.HTTPRequest,
+		Controller:     controller,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: req,
+		Request:        doc,
+		tenant:         ctx.tenant,
+	}
+
+	// handle request
+	controller.handle(prefix, subCtx, nil, false)
+
+	// store local identifier of created resource
+	if newLID != "" {
+		lids[ref.Type+"/"+newLID] = subCtx.Model.ID().Hex()
+	}
+
+	// prepare result
+	var result atomicResult
+	if create || req.Intent == jsonapi.UpdateResource {
+		controller.transformResponse(subCtx, subCtx.Response)
+		result.Data = subCtx.Response.Data.One
+	}
+
+	return result
+}
+
+func resolveLocalIdentifiers(data interface{}, lids map[string]string) {
+	switch data := data.(type) {
+	case []interface{}:
+		for _, item := range data {
+			resolveLocalIdentifiers(item, lids)
+		}
+	case map[string]interface{}:
+		// resolve local identifier
+		if lid, ok := data["lid"].(string); ok {
+			typ, _ := data["type"].(string)
+			id, ok := lids[typ+"/"+lid]
+			if !ok {
+				xo.Abort(jsonapi.BadRequestPointer("unknown local identifier", "/data"))
+			}
+			data["id"] = id
+			delete(data, "lid")
+		}
+
+		// resolve relationships
+		relationships, _ := data["relationships"].(map[string]interface{})
+		for _, relationship := range relationships {
+			if relationship, ok := relationship.(map[string]interface{}); ok {
+				resolveLocalIdentifiers(relationship["data"], lids)
+			}
+		}
+	}
+}
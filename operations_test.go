@@ -0,0 +1,251 @@
+package fire
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicOperations(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		group.Handle("operations", &GroupAction{
+			Action: AtomicOperations(tester.Store),
+		})
+
+		// add resources
+		tester.Request("POST", "operations", `{
+			"atomic:operations": [
+				{
+					"op": "add",
+					"data": {
+						"type": "posts",
+						"lid": "p1",
+						"attributes": {
+							"title": "Post 1"
+						}
+					}
+				},
+				{
+					"op": "add",
+					"data": {
+						"type": "comments",
+						"lid": "c1",
+						"attributes": {
+							"message": "Comment 1"
+						},
+						"relationships": {
+							"post": {
+								"data": {
+									"type": "posts",
+									"lid": "p1"
+								}
+							}
+						}
+					}
+				},
+				{
+					"op": "update",
+					"ref": {
+						"type": "posts",
+						"lid": "p1"
+					},
+					"data": {
+						"type": "posts",
+						"lid": "p1",
+						"attributes": {
+							"text-body": "Hello"
+						}
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, AtomicMediaType, r.Header().Get("Content-Type"))
+
+			var res atomicResponse
+			err := json.Unmarshal(r.Body.Bytes(), &res)
+			assert.NoError(t, err)
+			assert.Len(t, res.Results, 3)
+			assert.Equal(t, "posts", res.Results[0].Data.Type)
+			assert.Equal(t, "comments", res.Results[1].Data.Type)
+			assert.Equal(t, res.Results[0].Data.ID, res.Results[1].Data.Relationships["post"].Data.One.ID)
+			assert.Equal(t, res.Results[0].Data.ID, res.Results[2].Data.ID)
+			assert.Equal(t, "Hello", res.Results[2].Data.Attributes["text-body"])
+		})
+
+		post := tester.FindLast(&postModel{}).(*postModel)
+		assert.Equal(t, "Post 1", post.Title)
+		assert.Equal(t, "Hello", post.TextBody)
+
+		comment := tester.FindLast(&commentModel{}).(*commentModel)
+		assert.Equal(t, "Comment 1", comment.Message)
+		assert.Equal(t, post.ID(), comment.Post)
+
+		// relationships and removal
+		tester.Request("POST", "operations", `{
+			"atomic:operations": [
+				{
+					"op": "add",
+					"data": {
+						"type": "comments",
+						"lid": "c2",
+						"attributes": {
+							"message": "Comment 2"
+						},
+						"relationships": {
+							"post": {
+								"data": {
+									"type": "posts",
+									"id": "`+post.ID().Hex()+`"
+								}
+							}
+						}
+					}
+				},
+				{
+					"op": "update",
+					"ref": {
+						"type": "comments",
+						"lid": "c2",
+						"relationship": "parent"
+					},
+					"data": {
+						"type": "comments",
+						"id": "`+comment.ID().Hex()+`"
+					}
+				},
+				{
+					"op": "remove",
+					"ref": {
+						"type": "posts",
+						"id": "`+post.ID().Hex()+`"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+
+			var res atomicResponse
+			err := json.Unmarshal(r.Body.Bytes(), &res)
+			assert.NoError(t, err)
+			assert.Len(t, res.Results, 3)
+			assert.NotNil(t, res.Results[0].Data)
+			assert.Nil(t, res.Results[1].Data)
+			assert.Nil(t, res.Results[2].Data)
+		})
+
+		comment2 := tester.FindLast(&commentModel{}).(*commentModel)
+		assert.Equal(t, "Comment 2", comment2.Message)
+		assert.Equal(t, &comment.Base.DocID, comment2.Parent)
+
+		assert.Equal(t, 0, tester.Count(&postModel{}))
+
+		// rollback on failure
+		tester.Request("POST", "operations", `{
+			"atomic:operations": [
+				{
+					"op": "add",
+					"data": {
+						"type": "posts",
+						"attributes": {
+							"title": "Post 2"
+						}
+					}
+				},
+				{
+					"op": "add",
+					"data": {
+						"type": "posts",
+						"attributes": {
+							"title": "error"
+						}
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "validation error",
+					"source": {
+						"pointer": "/atomic:operations/1"
+					}
+				}]
+			}`, r.Body.String())
+		})
+
+		assert.Equal(t, 0, tester.Count(&postModel{}))
+
+		// invalid attribute
+		tester.Request("POST", "operations", `{
+			"atomic:operations": [
+				{
+					"op": "add",
+					"data": {
+						"type": "posts",
+						"attributes": {
+							"foo": "bar"
+						}
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "invalid attribute",
+					"source": {
+						"pointer": "/atomic:operations/0/data/attributes/foo"
+					}
+				}]
+			}`, r.Body.String())
+		})
+
+		// unknown local identifier
+		tester.Request("POST", "operations", `{
+			"atomic:operations": [
+				{
+					"op": "remove",
+					"ref": {
+						"type": "posts",
+						"lid": "foo"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "unknown local identifier",
+					"source": {
+						"pointer": "/atomic:operations/0/ref/lid"
+					}
+				}]
+			}`, r.Body.String())
+		})
+
+		// missing operations
+		tester.Request("POST", "operations", `{}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+	})
+}
@@ -0,0 +1,231 @@
+package fire
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+var statsOperators = []string{"sum", "avg", "min", "max"}
+
+type statsMetric struct {
+	name     string
+	operator string
+	field    *coal.Field
+}
+
+func (c *Controller) computeStats(ctx *Context) error {
+	// get query
+	query := ctx.HTTPRequest.URL.Query()
+
+	// parse group fields
+	var groups []*coal.Field
+	if value := query.Get("group-by"); value != "" {
+		for _, key := range strings.Split(value, ",") {
+			field := c.meta.Attributes[key]
+			if field == nil {
+				field = c.meta.Relationships[key]
+			}
+			if field == nil || (field.RelName != "" && !field.ToOne) || !stick.Contains(c.Aggregations, field.Name) {
+				return jsonapi.BadRequestParam(fmt.Sprintf(`invalid group field "%s"`, key), "group-by")
+			}
+			groups = append(groups, field)
+		}
+	}
+
+	// parse metrics
+	metrics := []statsMetric{{name: "count"}}
+	if value := query.Get("metrics"); value != "" {
+		metrics = nil
+		for _, name := range strings.Split(value, ",") {
+			// handle count
+			if name == "count" {
+				metrics = append(metrics, statsMetric{name: name})
+				continue
+			}
+
+			// split operator and field
+			operator, key, ok := strings.Cut(strings.TrimSuffix(name, ")"), "(")
+			field := c.meta.Attributes[key]
+			if !ok || !strings.HasSuffix(name, ")") || !stick.Contains(statsOperators, operator) || field == nil || !stick.Contains(c.Aggregations, field.Name) {
+				return jsonapi.BadRequestParam(fmt.Sprintf(`invalid metric "%s"`, name), "metrics")
+			}
+
+			// add metric
+			metrics = append(metrics, statsMetric{
+				name:     name,
+				operator: operator,
+				field:    field,
+			})
+		}
+	}
+
+	// prepare list request
+	listRequest := ctx.HTTPRequest.Clone(ctx)
+	listRequest.Method = "GET"
+	listRequest.URL.Path = "/" + strings.Trim(ctx.JSONAPIRequest.Prefix+"/"+c.meta.PluralName, "/")
+	listRequest.Header.Del("Accept")
+	listRequest.Header.Del("Content-Type")
+
+	// remove stats parameters
+	listQuery := listRequest.URL.Query()
+	listQuery.Del("group-by")
+	listQuery.Del("metrics")
+	listRequest.URL.RawQuery = listQuery.Encode()
+
+	// parse list request
+	req, err := jsonapi.ParseRequest(listRequest, ctx.JSONAPIRequest.Prefix)
+	if err != nil {
+		return err
+	}
+
+	// check pagination and sorting
+	if req.PageNumber > 0 || req.PageSize > 0 || req.PageOffset > 0 || req.PageLimit > 0 || req.PageBefore != "" || req.PageAfter != "" || req.Pagination != "" {
+		return jsonapi.BadRequest("pagination not supported")
+	} else if len(req.Sorting) > 0 {
+		return jsonapi.BadRequestParam("sorting not supported", "sort")
+	}
+
+	// prepare context
+	listCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		Operation:      List,
+		HTTPRequest:    ctx.HTTPRequest,
+		ResponseWriter: ctx.ResponseWriter,
+		Controller:     c,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: req,
+		tenant:         ctx.tenant,
+	}
+	c.prepareContext(listCtx, nil)
+
+	// prepare result
+	result := make([]stick.Map, 0)
+
+	// compute stats in transaction
+	xo.AbortIf(c.Store.T(ctx.Context, true, func(tc context.Context) error {
+		return listCtx.With(tc, func() error {
+			// prepare query
+			q := c.prepareQuery(listCtx, false)
+
+			// check readability
+			readableFields := c.readableFields(listCtx, nil)
+			for _, field := range groups {
+				if !stick.Contains(readableFields, field.Name) {
+					xo.Abort(jsonapi.BadRequestParam("group field is not readable", "group-by"))
+				}
+			}
+			for _, metric := range metrics {
+				if metric.field != nil && !stick.Contains(readableFields, metric.field.Name) {
+					xo.Abort(jsonapi.BadRequestParam("metric field is not readable", "metrics"))
+				}
+			}
+
+			// translate filter
+			filter, err := coal.NewTranslator(c.Model).Document(q.filter)
+			if err != nil {
+				return err
+			}
+
+			// prepare group
+			var id interface{}
+			if len(groups) > 0 {
+				keys := bson.D{}
+				for i, field := range groups {
+					keys = append(keys, bson.E{Key: fmt.Sprintf("g%d", i), Value: "$" + field.BSONKey})
+				}
+				id = keys
+			}
+			group := bson.D{{Key: "_id", Value: id}}
+			for i, metric := range metrics {
+				if metric.field == nil {
+					group = append(group, bson.E{Key: fmt.Sprintf("m%d", i), Value: bson.M{"$sum": 1}})
+				} else {
+					group = append(group, bson.E{Key: fmt.Sprintf("m%d", i), Value: bson.M{"$" + metric.operator: "$" + metric.field.BSONKey}})
+				}
+			}
+
+			// run aggregation
+			iter, err := listCtx.Store.C(c.Model).Aggregate(listCtx, bson.A{
+				bson.M{"$match": filter},
+				bson.M{"$group": group},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			})
+			if err != nil {
+				return err
+			}
+			defer iter.Close()
+
+			// collect groups
+			for iter.Next() {
+				// decode document
+				var doc struct {
+					Group   bson.M `bson:"_id"`
+					Metrics bson.M `bson:",inline"`
+				}
+				err = iter.Decode(&doc)
+				if err != nil {
+					return err
+				}
+
+				// get group values
+				values := stick.Map{}
+				for i, field := range groups {
+					key := field.JSONKey
+					if key == "" {
+						key = field.RelName
+					}
+					values[key] = doc.Group[fmt.Sprintf("g%d", i)]
+				}
+
+				// get metric values
+				metricValues := stick.Map{}
+				for i, metric := range metrics {
+					metricValues[metric.name] = doc.Metrics[fmt.Sprintf("m%d", i)]
+				}
+
+				// add group
+				result = append(result, stick.Map{
+					"group":   values,
+					"metrics": metricValues,
+				})
+			}
+
+			return iter.Error()
+		})
+	}))
+
+	// add empty group if ungrouped
+	if len(groups) == 0 && len(result) == 0 {
+		metricValues := stick.Map{}
+		for _, metric := range metrics {
+			if metric.field == nil || metric.operator == "sum" {
+				metricValues[metric.name] = 0
+			} else {
+				metricValues[metric.name] = nil
+			}
+		}
+		result = append(result, stick.Map{
+			"group":   stick.Map{},
+			"metrics": metricValues,
+		})
+	}
+
+	// write response
+	return jsonapi.WriteResponse(ctx.ResponseWriter, http.StatusOK, &jsonapi.Document{
+		Meta: jsonapi.Map{
+			"groups": result,
+		},
+	})
+}
@@ -0,0 +1,197 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func TestAggregations(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, `fire: invalid aggregation field "Comments"`, func() {
+			tester.Assign("", &Controller{
+				Model:        &postModel{},
+				Aggregations: []string{"Comments"},
+			})
+		})
+
+		actions := M{}
+		tester.Assign("", &Controller{
+			Model:             &postModel{},
+			CollectionActions: actions,
+			Filters:           []string{"Title"},
+			Sorters:           []string{"Title"},
+			SoftDelete:        true,
+			Aggregations:      []string{"Published"},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		}, &Controller{
+			Model:             &productModel{},
+			CollectionActions: actions,
+			Filters:           []string{"Stock"},
+			Aggregations:      []string{"Name", "Stock"},
+			Authorizers: L{
+				C("TestAggregations", Authorizer, All(), func(ctx *Context) error {
+					if ctx.HTTPRequest.Header.Get("Restricted") != "" {
+						ctx.ReadableFields = []string{"Name"}
+					}
+					if ctx.HTTPRequest.Header.Get("Getter") != "" {
+						ctx.GetReadableFields = func(model coal.Model) []string {
+							return []string{"Stock"}
+						}
+					}
+					return nil
+				}),
+			},
+		})
+
+		for query, message := range map[string]string{
+			"?group-by=price":               `invalid group field "price"`,
+			"?metrics=count,median(stock)":  `invalid metric "median(stock)"`,
+			"?metrics=sum(price)":           `invalid metric "sum(price)"`,
+			"?metrics=sum(stock":            `invalid metric "sum(stock"`,
+			"?page[number]=1&page[size]=10": "pagination not supported",
+			"?sort=name":                    "sorting not supported",
+			"?filter[price]=1":              `invalid filter "price"`,
+		} {
+			tester.Request("GET", "products/stats"+query, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+				assert.Equal(t, message, gjson.Get(r.Body.String(), "errors.0.detail").String(), query)
+			})
+		}
+
+		tester.Header["Restricted"] = "1"
+		tester.Request("GET", "products/stats?group-by=name&metrics=max(stock)", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "metric field is not readable", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+		delete(tester.Header, "Restricted")
+
+		tester.Header["Getter"] = "1"
+		tester.Request("GET", "products/stats?group-by=name&metrics=max(stock)", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "group field is not readable", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+		delete(tester.Header, "Getter")
+
+		assert.Empty(t, actions)
+
+		// lungo does not support aggregations
+		if tester.Store.Lungo() {
+			return
+		}
+
+		tester.Insert(&productModel{Name: "A", Stock: 1})
+		tester.Insert(&productModel{Name: "A", Stock: 3})
+		tester.Insert(&productModel{Name: "B", Stock: 5})
+		tester.Insert(&productModel{Name: "C", Stock: 0})
+
+		tester.Request("GET", "products/stats?group-by=name&metrics=count,sum(stock),avg(stock),min(stock),max(stock)&filter[stock][gt]=0", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"meta": {
+					"groups": [
+						{
+							"group": {
+								"name": "A"
+							},
+							"metrics": {
+								"count": 2,
+								"sum(stock)": 4,
+								"avg(stock)": 2,
+								"min(stock)": 1,
+								"max(stock)": 3
+							}
+						},
+						{
+							"group": {
+								"name": "B"
+							},
+							"metrics": {
+								"count": 1,
+								"sum(stock)": 5,
+								"avg(stock)": 5,
+								"min(stock)": 5,
+								"max(stock)": 5
+							}
+						}
+					]
+				}
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		tester.Insert(&postModel{Title: "A", Published: true})
+		tester.Insert(&postModel{Title: "B", Published: true})
+		tester.Insert(&postModel{Title: "C"})
+		tester.Insert(&postModel{Title: "D", Published: true, Deleted: stick.P(time.Now())})
+
+		tester.Request("GET", "posts/stats?group-by=published", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"meta": {
+					"groups": [
+						{
+							"group": {
+								"published": false
+							},
+							"metrics": {
+								"count": 1
+							}
+						},
+						{
+							"group": {
+								"published": true
+							},
+							"metrics": {
+								"count": 2
+							}
+						}
+					]
+				}
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "posts/stats?filter[title]=A", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"meta": {
+					"groups": [
+						{
+							"group": {},
+							"metrics": {
+								"count": 1
+							}
+						}
+					]
+				}
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "posts/stats?filter[title]=X", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"meta": {
+					"groups": [
+						{
+							"group": {},
+							"metrics": {
+								"count": 0
+							}
+						}
+					]
+				}
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
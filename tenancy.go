@@ -0,0 +1,100 @@
+package fire
+
+import (
+	"net/http"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func (c *Controller) resolveTenant(ctx *Context) coal.ID {
+	// use already resolved tenant
+	if !ctx.tenant.IsZero() {
+		return ctx.tenant
+	}
+
+	// resolve tenant
+	tenant, err := c.Tenancy(ctx)
+	if xo.IsSafe(err) {
+		xo.Abort(jsonapi.ErrorFromStatus(http.StatusUnauthorized, err.Error()))
+	} else if err != nil {
+		xo.Abort(err)
+	}
+
+	// check tenant
+	if tenant.IsZero() {
+		xo.Abort(ErrAccessDenied.Wrap())
+	}
+
+	// store tenant
+	ctx.tenant = tenant
+
+	return tenant
+}
+
+func (c *Controller) applyTenancy(ctx *Context) {
+	// get tenant field
+	tenantField := coal.L(c.Model, "fire-tenant", true)
+
+	// set selector
+	ctx.Selector[tenantField] = c.resolveTenant(ctx)
+}
+
+func (c *Controller) verifyTenancy(ctx *Context) {
+	// resolve tenant
+	tenant := c.resolveTenant(ctx)
+
+	// check tenant field
+	tenantField := coal.L(c.Model, "fire-tenant", true)
+	if stick.MustGet(ctx.Model, tenantField).(coal.ID) != tenant {
+		xo.Abort(jsonapi.BadRequest("tenant cannot be changed"))
+	}
+
+	// check modified relationships
+	for _, field := range c.meta.Relationships {
+		// skip virtual and unmodified relationships
+		if !field.ToOne && !field.ToMany || !ctx.Modified(field.Name) {
+			continue
+		}
+
+		// get related controller
+		rc := ctx.Group.controllers[field.RelType]
+		if rc == nil || rc.Tenancy == nil {
+			continue
+		}
+
+		// collect IDs
+		var ids []coal.ID
+		switch value := stick.MustGet(ctx.Model, field.Name).(type) {
+		case coal.ID:
+			ids = []coal.ID{value}
+		case *coal.ID:
+			if value != nil {
+				ids = []coal.ID{*value}
+			}
+		case []coal.ID:
+			ids = stick.Unique(value)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		// count related documents of tenant
+		count, err := ctx.Store.M(rc.Model).Count(ctx, bson.M{
+			"_id": bson.M{
+				"$in": ids,
+			},
+			coal.L(rc.Model, "fire-tenant", true): tenant,
+		}, 0, 0, false)
+		xo.AbortIf(err)
+
+		// check count
+		if int(count) != len(ids) {
+			xo.Abort(jsonapi.BadRequestPointer("relationship references resources of another tenant", "/data/relationships/"+field.RelName))
+		}
+	}
+}
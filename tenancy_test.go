@@ -0,0 +1,188 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func TestTenancy(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var resolved int
+		tenancy := func(ctx *Context) (coal.ID, error) {
+			resolved++
+			id, _ := coal.FromHex(ctx.HTTPRequest.Header.Get("Tenant"))
+			return id, nil
+		}
+
+		assert.PanicsWithValue(t, `coal: no or multiple fields flagged as "fire-tenant" on "fire.postModel"`, func() {
+			tester.Assign("", &Controller{
+				Model:   &postModel{},
+				Tenancy: tenancy,
+			})
+		})
+
+		tester.Assign("", &Controller{
+			Model:   &projectModel{},
+			Tenancy: tenancy,
+		}, &Controller{
+			Model:   &taskModel{},
+			Tenancy: tenancy,
+		})
+
+		tenantA := coal.New()
+		tenantB := coal.New()
+
+		projectA := tester.Insert(&projectModel{
+			Name:   "A",
+			Tenant: tenantA,
+		}).ID()
+		projectB := tester.Insert(&projectModel{
+			Name:   "B",
+			Tenant: tenantB,
+		}).ID()
+		taskA := tester.Insert(&taskModel{
+			Title:   "A",
+			Tenant:  tenantA,
+			Project: &projectA,
+		}).ID()
+		taskB := tester.Insert(&taskModel{
+			Title:   "B",
+			Tenant:  tenantB,
+			Project: &projectB,
+		}).ID()
+		tester.Insert(&taskModel{
+			Title:   "Foreign",
+			Tenant:  tenantB,
+			Project: &projectA,
+		})
+
+		// missing tenant
+		tester.Request("GET", "projects", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnauthorized, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Header["Tenant"] = tenantA.Hex()
+
+		// list projects
+		tester.Request("GET", "projects", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+projectA.Hex()+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// find foreign project
+		tester.Request("GET", "projects/"+projectB.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// find project with preloaded tasks
+		resolved = 0
+		tester.Request("GET", "projects/"+projectA.Hex(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+taskA.Hex()+`"]`, gjson.Get(r.Body.String(), "data.relationships.tasks.data.#.id").Raw)
+		})
+		assert.Equal(t, 1, resolved)
+
+		// get related tasks
+		tester.Request("GET", "projects/"+projectA.Hex()+"/tasks", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+taskA.Hex()+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// create project with foreign tenant
+		resolved = 0
+		tester.Request("POST", "projects", `{
+			"data": {
+				"type": "projects",
+				"attributes": {
+					"name": "C",
+					"tenant": "`+tenantB.Hex()+`"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, tenantA.Hex(), gjson.Get(r.Body.String(), "data.attributes.tenant").String())
+		})
+		assert.Equal(t, 1, resolved)
+
+		// change tenant
+		tester.Request("PATCH", "projects/"+projectA.Hex(), `{
+			"data": {
+				"type": "projects",
+				"id": "`+projectA.Hex()+`",
+				"attributes": {
+					"tenant": "`+tenantB.Hex()+`"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "tenant cannot be changed", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// create task with foreign project
+		tester.Request("POST", "tasks", `{
+			"data": {
+				"type": "tasks",
+				"attributes": {
+					"title": "C"
+				},
+				"relationships": {
+					"project": {
+						"data": {
+							"type": "projects",
+							"id": "`+projectB.Hex()+`"
+						}
+					}
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "/data/relationships/project", gjson.Get(r.Body.String(), "errors.0.source.pointer").String())
+		})
+
+		// create task
+		tester.Request("POST", "tasks", `{
+			"data": {
+				"type": "tasks",
+				"attributes": {
+					"title": "C"
+				},
+				"relationships": {
+					"project": {
+						"data": {
+							"type": "projects",
+							"id": "`+projectA.Hex()+`"
+						}
+					}
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			id := coal.MustFromHex(gjson.Get(r.Body.String(), "data.id").String())
+			task := tester.Fetch(&taskModel{}, id).(*taskModel)
+			assert.Equal(t, tenantA, task.Tenant)
+			assert.Equal(t, stick.P(projectA), task.Project)
+		})
+
+		// set foreign related tasks
+		tester.Request("PATCH", "tasks/"+taskA.Hex()+"/relationships/related", `{
+			"data": [
+				{
+					"type": "tasks",
+					"id": "`+taskB.Hex()+`"
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "/data/relationships/related", gjson.Get(r.Body.String(), "errors.0.source.pointer").String())
+		})
+
+		assert.Equal(t, 4, tester.Count(&taskModel{}))
+	})
+}
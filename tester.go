@@ -1,11 +1,20 @@
 package fire
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"testing"
+	"time"
 
 	"github.com/256dpi/jsonapi/v2"
 	"github.com/256dpi/serve"
@@ -16,6 +25,9 @@ import (
 	"github.com/256dpi/fire/stick"
 )
 
+var snapshotIDPattern = regexp.MustCompile(`[0-9a-f]{24}`)
+var snapshotCursorPattern = regexp.MustCompile(`(page(?:\[|%5B)(?:after|before)(?:\]|%5D)=)[^&]+`)
+
 // A Tester provides facilities to the test a fire API.
 type Tester struct {
 	*coal.Tester
@@ -31,6 +43,9 @@ type Tester struct {
 
 	// Context to be set on fake requests.
 	Context context.Context
+
+	// Whether snapshot files should be written instead of compared.
+	UpdateSnapshots bool
 }
 
 // NewTester returns a new tester.
@@ -287,3 +302,142 @@ func (t *Tester) DebugRequest(r *http.Request, rr *httptest.ResponseRecorder) st
 	Header: %v
 	Body:   %v`, r.URL.String(), r.Header, rr.Code, rr.Result().Header, rr.Body.String())
 }
+
+// Snapshot will run the specified request against the registered handler and
+// compare the normalized response with the "testdata/<name>.json" snapshot
+// file. Object IDs, timestamps and pagination cursors are replaced with stable
+// placeholders to make the snapshot independent of the inserted data. The
+// snapshot files are written instead if UpdateSnapshots is set.
+func (t *Tester) Snapshot(tt *testing.T, name, method, path, payload string) {
+	tt.Helper()
+
+	// run request
+	var actual []byte
+	t.Request(method, path, payload, func(r *httptest.ResponseRecorder, rq *http.Request) {
+		actual = normalizeSnapshot(r)
+	})
+
+	// get file
+	file := filepath.Join("testdata", name+".json")
+
+	// write snapshot if requested
+	if t.UpdateSnapshots {
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		err = os.WriteFile(file, actual, 0644)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		return
+	}
+
+	// read snapshot
+	expected, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		tt.Fatalf("missing snapshot %q, set UpdateSnapshots to create it", file)
+	} else if err != nil {
+		tt.Fatal(err)
+	}
+
+	// decode snapshots
+	var expectedValue, actualValue interface{}
+	err = json.Unmarshal(expected, &expectedValue)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	err = json.Unmarshal(actual, &actualValue)
+	if err != nil {
+		tt.Fatal(err)
+	}
+
+	// compare snapshots
+	if !reflect.DeepEqual(expectedValue, actualValue) {
+		tt.Errorf("snapshot %q does not match:\nexpected: %s\nactual:   %s", file, expected, actual)
+	}
+}
+
+func normalizeSnapshot(r *httptest.ResponseRecorder) []byte {
+	// decode body
+	var body interface{}
+	if r.Body.Len() > 0 {
+		err := json.Unmarshal(r.Body.Bytes(), &body)
+		if err != nil {
+			body = r.Body.String()
+		}
+	}
+
+	// normalize body
+	ids := map[string]string{}
+	body = normalizeSnapshotValue(body, ids)
+
+	// encode snapshot
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(map[string]interface{}{
+		"status": r.Code,
+		"body":   body,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+func normalizeSnapshotValue(value interface{}, ids map[string]string) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		// normalize values in key order to number IDs stably
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value[key] = normalizeSnapshotValue(value[key], ids)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeSnapshotValue(item, ids)
+		}
+		return value
+	case string:
+		// replace timestamps
+		if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return "<time>"
+		}
+
+		// replace cursors
+		value = snapshotCursorPattern.ReplaceAllString(value, "${1}<cursor>")
+
+		// replace standalone object IDs
+		var b strings.Builder
+		var last int
+		for _, loc := range snapshotIDPattern.FindAllStringIndex(value, -1) {
+			if (loc[0] > 0 && isLowerHex(value[loc[0]-1])) || (loc[1] < len(value) && isLowerHex(value[loc[1]])) {
+				continue
+			}
+			id := value[loc[0]:loc[1]]
+			if ids[id] == "" {
+				ids[id] = fmt.Sprintf("<id-%d>", len(ids)+1)
+			}
+			b.WriteString(value[last:loc[0]])
+			b.WriteString(ids[id])
+			last = loc[1]
+		}
+		b.WriteString(value[last:])
+
+		return b.String()
+	default:
+		return value
+	}
+}
+
+func isLowerHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')
+}
@@ -0,0 +1,96 @@
+package fire
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/fire/stick"
+)
+
+func TestTesterSnapshot(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:            &postModel{},
+			CursorPagination: true,
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		}, &Controller{
+			Model: &productModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "Post 1",
+		}).ID()
+
+		tester.Insert(&postModel{
+			Title: "Post 2",
+		})
+
+		product := tester.Insert(&productModel{
+			Name:        "Product",
+			PublishedAt: stick.P(time.Now()),
+		}).ID()
+
+		tester.Snapshot(t, "snapshot-posts", "GET", "posts?page[size]=1", "")
+		tester.Snapshot(t, "snapshot-post", "GET", "posts/"+post.Hex(), "")
+		tester.Snapshot(t, "snapshot-product", "GET", "products/"+product.Hex(), "")
+		tester.Snapshot(t, "snapshot-missing", "GET", "posts/"+product.Hex(), "")
+
+		// update snapshot
+		tester.UpdateSnapshots = true
+		tester.Snapshot(t, "snapshot-update", "GET", "posts/"+post.Hex(), "")
+		tester.UpdateSnapshots = false
+		defer os.Remove(filepath.Join("testdata", "snapshot-update.json"))
+
+		expected, err := os.ReadFile(filepath.Join("testdata", "snapshot-post.json"))
+		assert.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join("testdata", "snapshot-update.json"))
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(actual))
+		tester.Snapshot(t, "snapshot-update", "GET", "posts/"+post.Hex(), "")
+	})
+}
+
+func TestNormalizeSnapshot(t *testing.T) {
+	r := httptest.NewRecorder()
+	r.WriteHeader(200)
+	_, _ = r.WriteString(`{
+		"a": "6ad23e7b69e366f6785fc3fd",
+		"b": ["6ad23e7b69e366f6785fc3fd", "6ad23e7b69e366f6785fc3fe"],
+		"c": "/posts/6ad23e7b69e366f6785fc3fe/relationships/comments",
+		"d": "/posts?page%5Bafter%5D=WyI2YWQyM2U3YjY5ZTM2NmY2Nzg1ZmMzZmQiXQ&page%5Bsize%5D=1",
+		"e": "2026-10-16T12:00:00.123Z",
+		"f": "6ad23e7b69e366f6785fc3fd6ad23e7b69e366f6785fc3fd",
+		"g": 42
+	}`)
+
+	assert.JSONEq(t, `{
+		"status": 200,
+		"body": {
+			"a": "<id-1>",
+			"b": ["<id-1>", "<id-2>"],
+			"c": "/posts/<id-2>/relationships/comments",
+			"d": "/posts?page%5Bafter%5D=<cursor>&page%5Bsize%5D=1",
+			"e": "<time>",
+			"f": "6ad23e7b69e366f6785fc3fd6ad23e7b69e366f6785fc3fd",
+			"g": 42
+		}
+	}`, string(normalizeSnapshot(r)))
+
+	r = httptest.NewRecorder()
+	r.WriteHeader(204)
+
+	assert.JSONEq(t, `{
+		"status": 204,
+		"body": null
+	}`, string(normalizeSnapshot(r)))
+}
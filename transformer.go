@@ -0,0 +1,202 @@
+package fire
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+)
+
+// APIVersionHeader is the header used to select and report the API version.
+const APIVersionHeader = "API-Version"
+
+// A Transformer transforms resources between an API version and the next API
+// version of a group. Transformers of subsequent versions are chained to
+// transform resources between older versions and the current version.
+type Transformer struct {
+	// The API version that is transformed from and to.
+	Version string
+
+	// Up transforms an incoming resource from the version to the next version.
+	// Returned "safe" errors will cause the abortion of the request with a bad
+	// request status.
+	Up func(ctx *Context, res *jsonapi.Resource) error
+
+	// Down transforms an outgoing resource from the next version to the
+	// version.
+	Down func(ctx *Context, res *jsonapi.Resource) error
+}
+
+// RenameAttribute returns a transformer for an attribute that is called "from"
+// in the specified version and has been renamed to "to" in the next version.
+func RenameAttribute(version, from, to string) *Transformer {
+	return &Transformer{
+		Version: version,
+		Up: func(_ *Context, res *jsonapi.Resource) error {
+			renameAttribute(res, from, to)
+			return nil
+		},
+		Down: func(_ *Context, res *jsonapi.Resource) error {
+			renameAttribute(res, to, from)
+			return nil
+		},
+	}
+}
+
+// AddAttribute returns a transformer for an attribute that has been added in
+// the next version. The attribute is removed from outgoing resources and set
+// to the provided default value when resources are created.
+func AddAttribute(version, key string, value interface{}) *Transformer {
+	return &Transformer{
+		Version: version,
+		Up: func(ctx *Context, res *jsonapi.Resource) error {
+			if ctx.Operation == Create && res.Attributes[key] == nil {
+				if res.Attributes == nil {
+					res.Attributes = jsonapi.Map{}
+				}
+				res.Attributes[key] = value
+			}
+			return nil
+		},
+		Down: func(_ *Context, res *jsonapi.Resource) error {
+			delete(res.Attributes, key)
+			return nil
+		},
+	}
+}
+
+// RemoveAttribute returns a transformer for an attribute that has been removed
+// in the next version. The attribute is set to the provided value on outgoing
+// resources and ignored on incoming resources.
+func RemoveAttribute(version, key string, value interface{}) *Transformer {
+	return &Transformer{
+		Version: version,
+		Up: func(_ *Context, res *jsonapi.Resource) error {
+			delete(res.Attributes, key)
+			return nil
+		},
+		Down: func(_ *Context, res *jsonapi.Resource) error {
+			if res.Attributes == nil {
+				res.Attributes = jsonapi.Map{}
+			}
+			res.Attributes[key] = value
+			return nil
+		},
+	}
+}
+
+func renameAttribute(res *jsonapi.Resource, from, to string) {
+	if value, ok := res.Attributes[from]; ok {
+		res.Attributes[to] = value
+		delete(res.Attributes, from)
+	}
+}
+
+func (c *Controller) checkTransformers(versions []string) {
+	for _, transformer := range c.Transformers {
+		// check version
+		index := slices.Index(versions, transformer.Version)
+		if index < 0 || index == len(versions)-1 {
+			panic(fmt.Sprintf(`fire: invalid transformer version "%s" for model "%s"`, transformer.Version, c.meta.Name))
+		}
+
+		// check functions
+		if transformer.Up == nil || transformer.Down == nil {
+			panic(fmt.Sprintf(`fire: incomplete transformer for version "%s" and model "%s"`, transformer.Version, c.meta.Name))
+		}
+	}
+}
+
+func (c *Controller) transformers(ctx *Context) []*Transformer {
+	// check transformers
+	if len(c.Transformers) == 0 {
+		return nil
+	}
+
+	// get version index
+	versions := ctx.Group.apiVersions
+	index := slices.Index(versions, ctx.APIVersion)
+	if index < 0 {
+		return nil
+	}
+
+	// collect transformers of the requested and later versions
+	var list []*Transformer
+	for _, transformer := range c.Transformers {
+		if slices.Index(versions, transformer.Version) >= index {
+			list = append(list, transformer)
+		}
+	}
+
+	// sort transformers by version
+	sort.SliceStable(list, func(i, j int) bool {
+		return slices.Index(versions, list[i].Version) < slices.Index(versions, list[j].Version)
+	})
+
+	return list
+}
+
+func (c *Controller) transformRequest(ctx *Context) {
+	// check version
+	if ctx.currentRequest || !ctx.Group.legacyAPIVersion(ctx.APIVersion) {
+		return
+	}
+
+	// trace
+	ctx.Tracer.Push("fire/Controller.transformRequest")
+	defer ctx.Tracer.Pop()
+
+	// get resource
+	if ctx.Request == nil || ctx.Request.Data == nil || ctx.Request.Data.One == nil {
+		return
+	}
+	res := ctx.Request.Data.One
+
+	// transform resource up from the requested version
+	for _, transformer := range c.transformers(ctx) {
+		err := xo.W(transformer.Up(ctx, res))
+		if xo.IsSafe(err) {
+			xo.Abort(jsonapi.BadRequest(err.Error()))
+		} else if err != nil {
+			xo.Abort(err)
+		}
+	}
+}
+
+func (c *Controller) transformResponse(ctx *Context, doc *jsonapi.Document) {
+	// check version
+	if !ctx.Group.legacyAPIVersion(ctx.APIVersion) {
+		return
+	}
+
+	// trace
+	ctx.Tracer.Push("fire/Controller.transformResponse")
+	defer ctx.Tracer.Pop()
+
+	// collect resources
+	var resources []*jsonapi.Resource
+	if doc.Data != nil && doc.Data.One != nil {
+		resources = append(resources, doc.Data.One)
+	}
+	if doc.Data != nil {
+		resources = append(resources, doc.Data.Many...)
+	}
+	resources = append(resources, doc.Included...)
+
+	// transform resources down to the requested version
+	for _, res := range resources {
+		// get controller
+		rc := ctx.Group.controllers[res.Type]
+		if rc == nil {
+			continue
+		}
+
+		// run transformers in reverse order
+		list := rc.transformers(ctx)
+		for i := len(list) - 1; i >= 0; i-- {
+			xo.AbortIf(list[i].Down(ctx, res))
+		}
+	}
+}
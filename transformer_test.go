@@ -0,0 +1,400 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/serve"
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+)
+
+func TestTransformers(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Model: &postModel{},
+			Store: tester.Store,
+			Bulk:  true,
+			Transformers: []*Transformer{
+				RemoveAttribute("v2", "summary", "none"),
+				RenameAttribute("v1", "name", "title"),
+				AddAttribute("v2", "published", true),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &selectionModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &noteModel{},
+			Store: tester.Store,
+		})
+		group.APIVersions("v1", "v2", "v3")
+		tester.Handler = serve.Compose(xo.RootHandler(), group.Endpoint(""))
+
+		// create with path version
+		var id string
+		tester.Request("POST", "v1/posts", `{
+			"data": {
+				"type": "posts",
+				"attributes": {
+					"name": "Hello",
+					"summary": "ignored"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "v1", r.Header().Get(APIVersionHeader))
+
+			id = gjson.Get(r.Body.String(), "data.id").String()
+			attrs := gjson.Get(r.Body.String(), "data.attributes")
+			assert.Equal(t, "Hello", attrs.Get("name").String())
+			assert.False(t, attrs.Get("title").Exists())
+			assert.False(t, attrs.Get("published").Exists())
+			assert.Equal(t, "none", attrs.Get("summary").String())
+			assert.Equal(t, "/v1/posts/"+id, gjson.Get(r.Body.String(), "links.self").String())
+		})
+
+		post := tester.Fetch(&postModel{}, coal.MustFromHex(id)).(*postModel)
+		assert.Equal(t, "Hello", post.Title)
+		assert.True(t, post.Published)
+
+		// list with header version
+		tester.Header[APIVersionHeader] = "v2"
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "v2", r.Header().Get(APIVersionHeader))
+
+			attrs := gjson.Get(r.Body.String(), "data.0.attributes")
+			assert.Equal(t, "Hello", attrs.Get("title").String())
+			assert.False(t, attrs.Get("name").Exists())
+			assert.False(t, attrs.Get("published").Exists())
+			assert.Equal(t, "none", attrs.Get("summary").String())
+			assert.Equal(t, "/posts", gjson.Get(r.Body.String(), "links.self").String())
+		})
+
+		// prefer path version
+		tester.Request("GET", "v1/posts/"+id, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "v1", r.Header().Get(APIVersionHeader))
+			assert.Equal(t, "Hello", gjson.Get(r.Body.String(), "data.attributes.name").String())
+		})
+
+		// unknown header version
+		tester.Header[APIVersionHeader] = "v0"
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "unknown API version", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+		delete(tester.Header, APIVersionHeader)
+
+		// missing resource
+		tester.Request("GET", "v1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// update without defaults
+		tester.Update(post, bson.M{
+			"$set": bson.M{"Published": false},
+		})
+		tester.Request("PATCH", "v1/posts/"+id, `{
+			"data": {
+				"type": "posts",
+				"id": "`+id+`",
+				"attributes": {
+					"name": "World"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "World", gjson.Get(r.Body.String(), "data.attributes.name").String())
+		})
+
+		post = tester.Fetch(&postModel{}, post.ID()).(*postModel)
+		assert.Equal(t, "World", post.Title)
+		assert.False(t, post.Published)
+
+		// bulk create
+		tester.Request("POST", "v1/posts", `{
+			"data": [
+				{
+					"type": "posts",
+					"attributes": {
+						"name": "Bulk 1"
+					}
+				},
+				{
+					"type": "posts",
+					"attributes": {
+						"name": "Bulk 2"
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `["Bulk 1", "Bulk 2"]`, gjson.Get(r.Body.String(), "data.#.attributes.name").Raw)
+			assert.JSONEq(t, `["none", "none"]`, gjson.Get(r.Body.String(), "data.#.attributes.summary").Raw)
+		})
+		assert.Equal(t, 3, tester.Count(&postModel{}))
+
+		// current version
+		tester.Request("GET", "posts/"+id, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "v3", r.Header().Get(APIVersionHeader))
+
+			attrs := gjson.Get(r.Body.String(), "data.attributes")
+			assert.Equal(t, "World", attrs.Get("title").String())
+			assert.True(t, attrs.Get("published").Exists())
+			assert.False(t, attrs.Get("summary").Exists())
+		})
+	})
+}
+
+func TestTransformersIncluded(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Model: &postModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &commentModel{},
+			Store: tester.Store,
+			Transformers: []*Transformer{
+				RenameAttribute("v1", "text", "message"),
+			},
+		}, &Controller{
+			Model: &selectionModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &noteModel{},
+			Store: tester.Store,
+		})
+		group.APIVersions("v1", "v2")
+		tester.Handler = serve.Compose(xo.RootHandler(), group.Endpoint("api"))
+
+		post := tester.Insert(&postModel{
+			Title: "Post",
+		}).ID()
+
+		tester.Insert(&commentModel{
+			Message: "Comment",
+			Post:    post,
+		})
+
+		tester.Request("GET", "api/v1/posts/"+post.Hex()+"?include=comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Post", gjson.Get(r.Body.String(), "data.attributes.title").String())
+			assert.Equal(t, "Comment", gjson.Get(r.Body.String(), "included.0.attributes.text").String())
+			assert.False(t, gjson.Get(r.Body.String(), "included.0.attributes.message").Exists())
+			assert.Equal(t, "/api/v1/posts/"+post.Hex()+"/relationships/comments", gjson.Get(r.Body.String(), "data.relationships.comments.links.self").String())
+		})
+
+		tester.Request("GET", "api/comments", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "Comment", gjson.Get(r.Body.String(), "data.0.attributes.message").String())
+		})
+	})
+}
+
+func TestTransformersSafeError(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Model: &postModel{},
+			Store: tester.Store,
+			Transformers: []*Transformer{
+				{
+					Version: "v1",
+					Up: func(ctx *Context, res *jsonapi.Resource) error {
+						return xo.SF("unsupported attribute")
+					},
+					Down: func(ctx *Context, res *jsonapi.Resource) error {
+						return nil
+					},
+				},
+			},
+		}, &Controller{
+			Model: &commentModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &selectionModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &noteModel{},
+			Store: tester.Store,
+		})
+		group.APIVersions("v1", "v2")
+		tester.Handler = serve.Compose(xo.RootHandler(), group.Endpoint(""))
+
+		tester.Request("POST", "v1/posts", `{
+			"data": {
+				"type": "posts",
+				"attributes": {
+					"title": "Hello"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "unsupported attribute", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+	})
+}
+
+func TestTransformersValidation(t *testing.T) {
+	assert.PanicsWithValue(t, `fire: invalid API version "a/b"`, func() {
+		NewGroup(xo.Crash).APIVersions("v1", "a/b")
+	})
+
+	assert.PanicsWithValue(t, `fire: invalid API version "v1"`, func() {
+		NewGroup(xo.Crash).APIVersions("v1", "v1")
+	})
+
+	group := NewGroup(xo.Crash)
+	group.Add(&Controller{
+		Model: &postModel{},
+		Store: lungoStore,
+	})
+	group.APIVersions("posts")
+	assert.PanicsWithValue(t, `fire: API version "posts" conflicts with controller or action`, func() {
+		group.Endpoint("")
+	})
+
+	group = NewGroup(xo.Crash)
+	group.Add(&Controller{
+		Model: &postModel{},
+		Store: lungoStore,
+		Transformers: []*Transformer{
+			RenameAttribute("v2", "name", "title"),
+		},
+	})
+	group.APIVersions("v1", "v2")
+	assert.PanicsWithValue(t, `fire: invalid transformer version "v2" for model "fire.postModel"`, func() {
+		group.Endpoint("")
+	})
+}
+
+func TestTransformersSubRequests(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		_, err := tester.Store.DB().Collection("posts_versions").DeleteMany(tester.Context, bson.M{})
+		assert.NoError(t, err)
+		assert.NoError(t, EnsureVersionIndexes(tester.Store, &postModel{}))
+
+		var up int
+		var versions []string
+		group := NewGroup(xo.Crash)
+		group.Add(&Controller{
+			Model:      &postModel{},
+			Store:      tester.Store,
+			Versioning: true,
+			Authorizers: L{
+				C("TestTransformersSubRequests", Authorizer, Only(Create|Update), func(ctx *Context) error {
+					versions = append(versions, ctx.APIVersion)
+					return nil
+				}),
+			},
+			Transformers: []*Transformer{
+				RenameAttribute("v1", "name", "title"),
+				{
+					Version: "v1",
+					Up: func(ctx *Context, res *jsonapi.Resource) error {
+						up++
+						return nil
+					},
+					Down: func(ctx *Context, res *jsonapi.Resource) error {
+						return nil
+					},
+				},
+			},
+		}, &Controller{
+			Model: &commentModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &selectionModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &noteModel{},
+			Store: tester.Store,
+		})
+		group.Handle("operations", &GroupAction{
+			Action: AtomicOperations(tester.Store),
+		})
+		group.APIVersions("v1", "v2")
+		tester.Handler = serve.Compose(xo.RootHandler(), group.Endpoint(""))
+
+		// create with operations
+		var id string
+		tester.Header["Content-Type"] = AtomicMediaType
+		tester.Request("POST", "v1/operations", `{
+			"atomic:operations": [
+				{
+					"op": "add",
+					"data": {
+						"type": "posts",
+						"attributes": {
+							"name": "A"
+						}
+					}
+				}
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "atomic:results.0.data.attributes.name").String(), tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "atomic:results.0.data.attributes.title").Exists(), tester.DebugRequest(rq, r))
+			id = gjson.Get(r.Body.String(), "atomic:results.0.data.id").String()
+		})
+		delete(tester.Header, "Content-Type")
+		assert.Equal(t, "A", tester.Fetch(&postModel{}, coal.MustFromHex(id)).(*postModel).Title)
+
+		// update
+		tester.Request("PATCH", "v1/posts/"+id, `{
+			"data": {
+				"type": "posts",
+				"id": "`+id+`",
+				"attributes": {
+					"name": "B"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, 2, up)
+
+		// restore version
+		tester.Request("POST", "v1/posts/"+id+"/restore", `{"version":1}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "data.attributes.name").String(), tester.DebugRequest(rq, r))
+			assert.False(t, gjson.Get(r.Body.String(), "data.attributes.title").Exists(), tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, 2, up)
+		assert.Equal(t, "A", tester.Fetch(&postModel{}, coal.MustFromHex(id)).(*postModel).Title)
+		assert.Equal(t, []string{"v1", "v1", "v1"}, versions)
+
+		// reject legacy GraphQL
+		tester.Handler = serve.Compose(xo.RootHandler(), group.GraphQL(0, 0))
+		tester.Header[APIVersionHeader] = "v1"
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { id } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "unsupported API version", gjson.Get(r.Body.String(), "errors.0.message").String(), tester.DebugRequest(rq, r))
+		})
+
+		// allow current GraphQL
+		tester.Header[APIVersionHeader] = "v2"
+		tester.Request("POST", "graphql", `{
+			"query": "{ posts { id title } }"
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "data.posts.0.title").String(), tester.DebugRequest(rq, r))
+		})
+		delete(tester.Header, APIVersionHeader)
+	})
+}
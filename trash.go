@@ -0,0 +1,182 @@
+package fire
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func (c *Controller) trashFilter(ctx *Context) string {
+	// check trash
+	if !c.Trash {
+		return ""
+	}
+
+	// get values
+	values, ok := ctx.JSONAPIRequest.Filters["deleted"]
+	if !ok {
+		return ""
+	}
+
+	// check values
+	if len(values) != 1 || (values[0] != "true" && values[0] != "false" && values[0] != "only") {
+		xo.Abort(jsonapi.BadRequestParam("invalid filter value", "filter[deleted]"))
+	}
+
+	// ignore false
+	if values[0] == "false" {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c *Controller) trashAction(ctx *Context) bool {
+	// check trash and operation
+	if !c.Trash || ctx.Operation != ResourceAction {
+		return false
+	}
+
+	// check action
+	action := ctx.JSONAPIRequest.ResourceAction
+	return action == "restore" || action == "purge"
+}
+
+func (c *Controller) isDeleted(model coal.Model) bool {
+	// get soft delete field
+	softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+
+	return stick.MustGet(model, softDeleteField).(*time.Time) != nil
+}
+
+func (c *Controller) restoreResource(ctx *Context) error {
+	// restore soft deleted documents
+	if c.Trash && c.isDeleted(ctx.Model) {
+		return c.restoreDeleted(ctx)
+	}
+
+	// otherwise, restore version
+	if c.Versioning {
+		return c.restoreVersion(ctx)
+	}
+
+	return jsonapi.BadRequest("resource is not deleted")
+}
+
+func (c *Controller) restoreDeleted(ctx *Context) error {
+	// get soft delete field
+	softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+
+	// set original
+	original := c.meta.Make()
+	xo.AbortIf(stick.BSON.Transfer(ctx.Model, original))
+	ctx.Original = original
+
+	// clear soft delete field
+	stick.MustSet(ctx.Model, softDeleteField, stick.N[time.Time]())
+
+	// validate model
+	err := ctx.Model.Validate()
+	if xo.IsSafe(err) {
+		xo.Abort(jsonapi.BadRequest(err.Error()))
+	} else if err != nil {
+		xo.Abort(err)
+	}
+
+	// run validators
+	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
+
+	// restore model like a regular update
+	ctx.Operation = Update
+	defer func() {
+		ctx.Operation = ResourceAction
+	}()
+
+	// restore model, prepare response and run notifiers in transaction
+	var res *jsonapi.Resource
+	xo.AbortIf(ctx.Store.T(ctx.Context, false, func(tc context.Context) error {
+		return ctx.With(tc, func() error {
+			// restore model
+			found, err := ctx.Store.M(c.Model).Update(ctx, nil, ctx.Model.ID(), bson.M{
+				"$set": bson.M{
+					softDeleteField: nil,
+				},
+			}, false)
+			xo.AbortIf(err)
+
+			// check if missing
+			if !found {
+				xo.Abort(ErrResourceNotFound.Wrap())
+			}
+
+			// preload relationships
+			relationships := c.preloadRelationships(ctx, []coal.Model{ctx.Model})
+
+			// construct resource
+			res = c.resourceForModel(ctx, ctx.Model, relationships)
+
+			// run notifiers
+			c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
+
+			return nil
+		})
+	}))
+
+	return jsonapi.WriteResponse(ctx.ResponseWriter, http.StatusOK, &jsonapi.Document{
+		Data: &jsonapi.HybridResource{
+			One: res,
+		},
+	})
+}
+
+func (c *Controller) purgeResource(ctx *Context) error {
+	// check state
+	if !c.isDeleted(ctx.Model) {
+		return jsonapi.BadRequest("resource is not deleted")
+	}
+
+	// run validators
+	c.runCallbacks(ctx, Validator, c.Validators, http.StatusBadRequest)
+
+	// purge model like a regular deletion
+	ctx.Operation = Delete
+	defer func() {
+		ctx.Operation = ResourceAction
+	}()
+
+	// delete model, store last revision and run notifiers in transaction
+	xo.AbortIf(ctx.Store.T(ctx.Context, false, func(tc context.Context) error {
+		return ctx.With(tc, func() error {
+			// delete model
+			found, err := ctx.Store.M(c.Model).Delete(ctx, nil, ctx.Model.ID())
+			xo.AbortIf(err)
+
+			// check if missing
+			if !found {
+				xo.Abort(ErrResourceNotFound.Wrap())
+			}
+
+			// store last revision if enabled
+			if c.Versioning {
+				c.storeVersion(ctx, ctx.Model)
+			}
+
+			// run notifiers
+			c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
+
+			return nil
+		})
+	}))
+
+	// write status
+	ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
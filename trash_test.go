@@ -0,0 +1,204 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
+)
+
+func TestTrash(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.PanicsWithValue(t, `fire: trash requires soft delete for model "fire.postModel"`, func() {
+			tester.Assign("", &Controller{
+				Model: &postModel{},
+				Trash: true,
+			})
+		})
+
+		assert.PanicsWithValue(t, `fire: trash requires trash access for model "fire.postModel"`, func() {
+			tester.Assign("", &Controller{
+				Model:      &postModel{},
+				SoftDelete: true,
+				Trash:      true,
+			})
+		})
+
+		var notified []string
+		tester.Assign("", &Controller{
+			Model:      &postModel{},
+			SoftDelete: true,
+			Trash:      true,
+			TrashAccess: func(ctx *Context) bool {
+				return ctx.HTTPRequest.Header.Get("Admin") != ""
+			},
+			Notifiers: L{
+				C("TestNotifier", Notifier, Only(Update|Delete), func(ctx *Context) error {
+					notified = append(notified, ctx.Operation.String()+" "+ctx.Model.(*postModel).Title)
+					return nil
+				}),
+			},
+			Validators: L{
+				C("TestValidator", Validator, Only(ResourceAction), func(ctx *Context) error {
+					if ctx.Model.(*postModel).Title == "Locked" {
+						return xo.SF("post is locked")
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post1 := tester.Insert(&postModel{
+			Title: "Post 1",
+		}).ID().Hex()
+		post2 := tester.Insert(&postModel{
+			Title:   "Post 2",
+			Deleted: stick.P(time.Now()),
+		}).ID().Hex()
+		post3 := tester.Insert(&postModel{
+			Title:   "Locked",
+			Deleted: stick.P(time.Now()),
+		}).ID().Hex()
+
+		// list posts
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+post1+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// list all posts without access
+		tester.Request("GET", "posts?filter[deleted]=true", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusUnauthorized, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "trash access denied", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// restore deleted post without access
+		tester.Request("POST", "posts/"+post2+"/restore", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// purge deleted post without access
+		tester.Request("DELETE", "posts/"+post2+"/purge", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Header["Admin"] = "true"
+
+		// list all posts
+		tester.Request("GET", "posts?filter[deleted]=true", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+post1+`","`+post2+`","`+post3+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// list deleted posts
+		tester.Request("GET", "posts?filter[deleted]=only", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+post2+`","`+post3+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// list with invalid filter
+		tester.Request("GET", "posts?filter[deleted]=foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// restore existing post
+		tester.Request("POST", "posts/"+post1+"/restore", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "resource is not deleted", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// restore locked post
+		tester.Request("POST", "posts/"+post3+"/restore", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "post is locked", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// restore deleted post
+		tester.Request("POST", "posts/"+post2+"/restore", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, post2, gjson.Get(r.Body.String(), "data.id").String())
+			assert.Equal(t, "Post 2", gjson.Get(r.Body.String(), "data.attributes.title").String())
+		})
+
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["`+post1+`","`+post2+`"]`, gjson.Get(r.Body.String(), "data.#.id").Raw)
+		})
+
+		// purge existing post
+		tester.Request("DELETE", "posts/"+post1+"/purge", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "resource is not deleted", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// delete and purge post
+		tester.Request("DELETE", "posts/"+post1, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		tester.Request("DELETE", "posts/"+post1+"/purge", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// purge locked post
+		tester.Request("DELETE", "posts/"+post3+"/purge", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 2, tester.Count(&postModel{}))
+		assert.Equal(t, []string{"Update Post 2", "Delete Post 1", "Delete Post 1"}, notified)
+	})
+}
+
+func TestTrashPurgeVersioning(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		_, err := tester.Store.DB().Collection("posts_versions").DeleteMany(tester.Context, bson.M{})
+		assert.NoError(t, err)
+
+		tester.Assign("", &Controller{
+			Model:      &postModel{},
+			SoftDelete: true,
+			Trash:      true,
+			Versioning: true,
+			TrashAccess: func(ctx *Context) bool {
+				return true
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title:   "Post",
+			Deleted: stick.P(time.Now()),
+		}).ID().Hex()
+
+		tester.Request("DELETE", "posts/"+post+"/purge", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 0, tester.Count(&postModel{}))
+
+		var versions []Version
+		cursor, err := tester.Store.DB().Collection("posts_versions").Find(tester.Context, bson.M{})
+		assert.NoError(t, err)
+		assert.NoError(t, cursor.All(tester.Context, &versions))
+		assert.Len(t, versions, 1)
+		assert.Equal(t, "Delete", versions[0].Operation)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/256dpi/xo"
 
 	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/glut"
 	"github.com/256dpi/fire/stick"
 )
 
@@ -93,10 +94,37 @@ type barModel struct {
 	stick.NoValidation `json:"-" bson:"-"`
 }
 
+type productModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"products"`
+	Name               string       `json:"name"`
+	Price              coal.Decimal `json:"price"`
+	Stock              int          `json:"stock"`
+	ReleaseDate        coal.Date    `json:"release-date" bson:"release_date"`
+	PublishedAt        *time.Time   `json:"published-at" bson:"published_at"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+type projectModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"projects"`
+	Name               string       `json:"name"`
+	Tenant             coal.ID      `json:"tenant" coal:"fire-tenant"`
+	Tasks              coal.HasMany `json:"-" bson:"-" coal:"tasks:tasks:project"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+type taskModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"tasks"`
+	Title              string    `json:"title"`
+	Tenant             coal.ID   `json:"-" coal:"fire-tenant"`
+	Project            *coal.ID  `json:"-" bson:"project_id" coal:"project:projects"`
+	Related            []coal.ID `json:"-" bson:"related_ids" coal:"related:tasks"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
 var mongoStore = coal.MustConnect("mongodb://0.0.0.0/test-fire", xo.Crash)
 var lungoStore = coal.MustOpen(nil, "test-fire", xo.Crash)
 
-var modelList = []coal.Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}, &fooModel{}, &barModel{}}
+var modelList = []coal.Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}, &fooModel{}, &barModel{}, &productModel{}, &Audit{}, &projectModel{}, &taskModel{}, &glut.Model{}}
 
 func withTester(t *testing.T, fn func(*testing.T, *Tester)) {
 	t.Run("Mongo", func(t *testing.T) {
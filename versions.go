@@ -0,0 +1,470 @@
+package fire
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func init() {
+	// add indexes
+	coal.AddIndex(&Version{}, true, 0, "Resource", "Number")
+}
+
+// versionAttempts is the number of attempts to store a version if the number
+// has been taken by a concurrent request.
+const versionAttempts = 3
+
+// Version is a stored revision of a document. Versions are stored in the
+// "<collection>_versions" collection of the versioned model.
+type Version struct {
+	coal.Base `json:"-" bson:",inline" coal:"versions"`
+
+	// The versioned document.
+	Resource coal.ID `json:"-"`
+
+	// The sequential version number.
+	Number int `json:"number"`
+
+	// The operation that replaced the revision e.g. "Update" or "Delete".
+	Operation string `json:"operation"`
+
+	// The time when the revision has been replaced.
+	Time time.Time `json:"time"`
+
+	// The encoded document.
+	Data stick.Map `json:"-"`
+}
+
+// Validate will validate the model.
+func (v *Version) Validate() error {
+	return stick.Validate(v, func(v *stick.Validator) {
+		v.Value("Resource", false, stick.IsNotZero)
+		v.Value("Number", false, stick.IsNotZero)
+		v.Value("Operation", false, stick.IsNotZero)
+		v.Value("Time", false, stick.IsNotZero)
+	})
+}
+
+// EnsureVersionIndexes will ensure that the indexes registered with the Version
+// model exist in the versions collections of the specified models. The unique
+// index on the resource and number prevents concurrent updates from storing
+// revisions with the same number and should be ensured for all models of
+// controllers with versioning enabled.
+func EnsureVersionIndexes(store *coal.Store, models ...coal.Model) error {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// get indexes
+	indexes := coal.GetMeta(&Version{}).Indexes
+
+	// ensure indexes
+	for _, model := range models {
+		coll := store.DB().Collection(coal.GetMeta(model).Collection + "_versions")
+		for _, index := range indexes {
+			_, err := coll.Indexes().CreateOne(ctx, index.Compile())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) parseVersion(prefix string, ctx *Context) {
+	// check method
+	if ctx.HTTPRequest.Method != "GET" {
+		return
+	}
+
+	// get path segments
+	path := strings.Trim(ctx.HTTPRequest.URL.Path, "/")
+	path = strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	segments := strings.Split(path, "/")
+
+	// check path
+	if len(segments) != 4 || segments[0] != c.meta.PluralName || segments[2] != "versions" {
+		return
+	}
+
+	// set request
+	ctx.JSONAPIRequest = &jsonapi.Request{
+		Prefix:         prefix,
+		Intent:         jsonapi.ResourceAction,
+		ResourceType:   segments[0],
+		ResourceID:     segments[1],
+		ResourceAction: "versions",
+	}
+}
+
+func (c *Controller) storeVersion(ctx *Context, model coal.Model) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.storeVersion")
+	defer ctx.Tracer.Pop()
+
+	// get collection
+	coll := ctx.Store.DB().Collection(c.meta.Collection + "_versions")
+
+	// prepare version
+	version := &Version{
+		Base:      coal.B(),
+		Resource:  model.ID(),
+		Operation: ctx.Operation.String(),
+		Time:      time.Now(),
+		Data:      stick.MustMap(model, stick.BSON),
+	}
+
+	for attempt := 1; ; attempt++ {
+		// find latest version
+		var latest Version
+		err := coll.FindOne(ctx, bson.M{
+			"resource": model.ID(),
+		}, options.FindOne().SetSort(bson.M{
+			"number": -1,
+		})).Decode(&latest)
+		if err != nil && !coal.IsMissing(err) {
+			xo.Abort(err)
+		}
+
+		// set next number
+		if latest.Number > version.Number {
+			version.Number = latest.Number
+		}
+		version.Number++
+
+		// insert version
+		_, err = coll.InsertOne(ctx, version)
+		if coal.IsDuplicate(err) && attempt < versionAttempts {
+			continue
+		}
+		xo.AbortIf(err)
+
+		return
+	}
+}
+
+func (c *Controller) loadVersions(ctx *Context, number int, skip, limit int64) []Version {
+	// trace
+	ctx.Tracer.Push("fire/Controller.loadVersions")
+	defer ctx.Tracer.Pop()
+
+	// prepare query
+	query := bson.M{
+		"resource": ctx.Model.ID(),
+	}
+	if number > 0 {
+		query["number"] = number
+	}
+
+	// prepare options
+	opts := options.Find().SetSort(bson.M{
+		"number": 1,
+	})
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	// find versions
+	var versions []Version
+	cursor, err := ctx.Store.DB().Collection(c.meta.Collection+"_versions").Find(ctx, query, opts)
+	xo.AbortIf(err)
+	xo.AbortIf(cursor.All(ctx, &versions))
+
+	return versions
+}
+
+func (c *Controller) findContext(ctx *Context) *Context {
+	// prepare context
+	findCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		Operation:      Find,
+		HTTPRequest:    ctx.HTTPRequest,
+		ResponseWriter: ctx.ResponseWriter,
+		Controller:     c,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: &jsonapi.Request{
+			Prefix:       ctx.JSONAPIRequest.Prefix,
+			Intent:       jsonapi.FindResource,
+			ResourceType: c.meta.PluralName,
+			ResourceID:   ctx.Model.ID().Hex(),
+		},
+		tenant: ctx.tenant,
+	}
+	c.prepareContext(findCtx, nil)
+
+	// authorize and find model in transaction
+	xo.AbortIf(c.Store.T(ctx.Context, true, func(tc context.Context) error {
+		return findCtx.With(tc, func() error {
+			c.prepareModel(findCtx)
+			c.findModel(findCtx)
+			return nil
+		})
+	}))
+
+	return findCtx
+}
+
+func (c *Controller) versionResource(ctx *Context, version Version) *jsonapi.Resource {
+	// decode model
+	model := c.meta.Make()
+	xo.AbortIf(version.Data.Unmarshal(model, stick.BSON))
+
+	// construct resource
+	resource := c.constructResource(ctx, model, nil)
+	resource.Meta = jsonapi.Map{
+		"version":   version.Number,
+		"operation": version.Operation,
+		"time":      version.Time,
+	}
+
+	return resource
+}
+
+func (c *Controller) listVersions(ctx *Context) error {
+	// get number
+	var number int
+	if path := strings.Split(strings.Trim(ctx.HTTPRequest.URL.Path, "/"), "/"); len(path) >= 2 && path[len(path)-2] == "versions" {
+		n, err := strconv.Atoi(path[len(path)-1])
+		if err != nil || n <= 0 {
+			return jsonapi.BadRequest("invalid version number")
+		}
+		number = n
+	}
+
+	// use the readable fields of a find operation
+	findCtx := c.findContext(ctx)
+
+	// handle single version
+	if number > 0 {
+		versions := c.loadVersions(ctx, number, 0, 0)
+		if len(versions) == 0 {
+			return jsonapi.NotFound("version not found")
+		}
+
+		return jsonapi.WriteResponse(ctx.ResponseWriter, http.StatusOK, &jsonapi.Document{
+			Data: &jsonapi.HybridResource{
+				One: c.versionResource(findCtx, versions[0]),
+			},
+		})
+	}
+
+	// prepare list request
+	listRequest := ctx.HTTPRequest.Clone(ctx)
+	listRequest.URL.Path = "/" + strings.Trim(ctx.JSONAPIRequest.Prefix+"/"+c.meta.PluralName, "/")
+	listRequest.Header.Del("Accept")
+	listRequest.Header.Del("Content-Type")
+
+	// parse list request
+	listReq, err := jsonapi.ParseRequest(listRequest, ctx.JSONAPIRequest.Prefix)
+	if err != nil {
+		return err
+	}
+
+	// check pagination
+	if listReq.PageOffset > 0 || listReq.PageLimit > 0 || listReq.PageBefore != "" || listReq.PageAfter != "" || listReq.Pagination != "" {
+		return jsonapi.BadRequest("pagination not supported")
+	}
+
+	// copy request
+	req := *ctx.JSONAPIRequest
+	req.PageNumber = listReq.PageNumber
+	req.PageSize = listReq.PageSize
+
+	// enforce list limit
+	if c.ListLimit > 0 && (req.PageSize <= 0 || req.PageSize > c.ListLimit) {
+		req.PageSize = c.ListLimit
+	}
+	if req.PageSize > 0 && req.PageNumber <= 0 {
+		req.PageNumber = 1
+	}
+
+	// load versions
+	var skip int64
+	if req.PageSize > 0 {
+		skip = (req.PageNumber - 1) * req.PageSize
+	}
+	versions := c.loadVersions(ctx, 0, skip, req.PageSize)
+
+	// prepare resources
+	resources := make([]*jsonapi.Resource, 0, len(versions))
+	for _, version := range versions {
+		resources = append(resources, c.versionResource(findCtx, version))
+	}
+
+	// prepare links
+	links := &jsonapi.DocumentLinks{
+		Self: jsonapi.Link(req.Self()),
+	}
+
+	// add pagination links
+	if req.PageSize > 0 {
+		// count versions
+		count, err := ctx.Store.DB().Collection(c.meta.Collection+"_versions").CountDocuments(ctx, bson.M{
+			"resource": ctx.Model.ID(),
+		})
+		xo.AbortIf(err)
+
+		// calculate last page
+		lastPage := int64(math.Ceil(float64(count) / float64(req.PageSize)))
+
+		// copy request
+		page := req
+
+		// add first and last links
+		page.PageNumber = 1
+		links.First = jsonapi.Link(page.Self())
+		page.PageNumber = lastPage
+		links.Last = jsonapi.Link(page.Self())
+
+		// add previous link if not on first page
+		if req.PageNumber > 1 {
+			page.PageNumber = req.PageNumber - 1
+			links.Previous = jsonapi.Link(page.Self())
+		}
+
+		// add next link if not on last page
+		if req.PageNumber < lastPage {
+			page.PageNumber = req.PageNumber + 1
+			links.Next = jsonapi.Link(page.Self())
+		}
+	}
+
+	return jsonapi.WriteResponse(ctx.ResponseWriter, http.StatusOK, &jsonapi.Document{
+		Data: &jsonapi.HybridResource{
+			Many: resources,
+		},
+		Links: links,
+	})
+}
+
+func (c *Controller) restoreVersion(ctx *Context) error {
+	// parse body
+	var body struct {
+		Version int `json:"version"`
+	}
+	err := ctx.Parse(&body)
+	if err != nil {
+		return err
+	}
+
+	// load version
+	versions := c.loadVersions(ctx, body.Version, 0, 0)
+	if body.Version <= 0 || len(versions) == 0 {
+		return jsonapi.NotFound("version not found")
+	}
+
+	// decode model
+	model := c.meta.Make()
+	xo.AbortIf(versions[0].Data.Unmarshal(model, stick.BSON))
+
+	// get writable fields
+	writableFields := c.writableFields(ctx, ctx.Model)
+
+	// get consistent update field
+	var consistentUpdateField string
+	if c.ConsistentUpdate {
+		consistentUpdateField = coal.L(c.Model, "fire-consistent-update", true)
+	}
+
+	// prepare attributes from writable fields
+	var keys []string
+	for key, field := range c.meta.Attributes {
+		if field.Name != consistentUpdateField && stick.Contains(writableFields, field.Name) {
+			keys = append(keys, key)
+		}
+	}
+	attributes, err := jsonapi.StructToMap(model, keys)
+	if err != nil {
+		return err
+	}
+
+	// use current consistent update token
+	if consistentUpdateField != "" {
+		if key := c.meta.Fields[consistentUpdateField].JSONKey; key != "" {
+			attributes[key] = stick.MustGet(ctx.Model, consistentUpdateField)
+		}
+	}
+
+	// prepare resource
+	res := &jsonapi.Resource{
+		Type:          c.meta.PluralName,
+		ID:            model.ID().Hex(),
+		Attributes:    attributes,
+		Relationships: map[string]*jsonapi.Document{},
+	}
+
+	// add writable relationships
+	for name, field := range c.meta.Relationships {
+		if !stick.Contains(writableFields, field.Name) {
+			continue
+		}
+		if field.ToOne {
+			var ref *jsonapi.Resource
+			if !field.Optional {
+				ref = &jsonapi.Resource{Type: field.RelType, ID: stick.MustGet(model, field.Name).(coal.ID).Hex()}
+			} else if id := stick.MustGet(model, field.Name).(*coal.ID); id != nil {
+				ref = &jsonapi.Resource{Type: field.RelType, ID: id.Hex()}
+			}
+			res.Relationships[name] = &jsonapi.Document{
+				Data: &jsonapi.HybridResource{One: ref},
+			}
+		} else if field.ToMany {
+			refs := make([]*jsonapi.Resource, 0)
+			for _, id := range stick.MustGet(model, field.Name).([]coal.ID) {
+				refs = append(refs, &jsonapi.Resource{Type: field.RelType, ID: id.Hex()})
+			}
+			res.Relationships[name] = &jsonapi.Document{
+				Data: &jsonapi.HybridResource{Many: refs},
+			}
+		}
+	}
+
+	// prepare context
+	subCtx := &Context{
+		Context:        ctx,
+		Data:           stick.Map{},
+		HTTPRequest:    ctx.HTTPRequest,
+		ResponseWriter: ctx.ResponseWriter,
+		Controller:     c,
+		Group:          ctx.Group,
+		APIVersion:     ctx.APIVersion,
+		Tracer:         ctx.Tracer,
+		JSONAPIRequest: &jsonapi.Request{
+			Prefix:       ctx.JSONAPIRequest.Prefix,
+			Intent:       jsonapi.UpdateResource,
+			ResourceType: c.meta.PluralName,
+			ResourceID:   res.ID,
+		},
+		Request: &jsonapi.Document{
+			Data: &jsonapi.HybridResource{
+				One: res,
+			},
+		},
+		currentRequest: true,
+		tenant:         ctx.tenant,
+	}
+
+	// run update
+	c.handle(ctx.JSONAPIRequest.Prefix, subCtx, nil, true)
+
+	return nil
+}
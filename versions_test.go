@@ -0,0 +1,236 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func TestVersioning(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		_, err := tester.Store.DB().Collection("posts_versions").DeleteMany(tester.Context, bson.M{})
+		assert.NoError(t, err)
+		assert.NoError(t, EnsureVersionIndexes(tester.Store, &postModel{}))
+
+		tester.Assign("", &Controller{
+			Model:      &postModel{},
+			Versioning: true,
+			Authorizers: L{
+				C("TestAuthorizer", Authorizer, All(), func(ctx *Context) error {
+					ctx.WritableFields = stick.Subtract(ctx.WritableFields, []string{"Published"})
+					if ctx.Operation == Find {
+						ctx.ReadableFields = stick.Subtract(ctx.ReadableFields, []string{"TextBody"})
+					}
+					return nil
+				}),
+			},
+			Validators: L{
+				C("TestValidator", Validator, Only(Update), func(ctx *Context) error {
+					if ctx.Original.(*postModel).Title == "Locked" {
+						return xo.SF("post is locked")
+					}
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "A",
+		}).(*postModel)
+		id := post.ID().Hex()
+
+		update := func(title string) {
+			tester.Request("PATCH", "posts/"+id, `{
+				"data": {
+					"type": "posts",
+					"id": "`+id+`",
+					"attributes": {
+						"title": "`+title+`"
+					}
+				}
+			}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			})
+		}
+
+		update("B")
+		update("C")
+
+		// list versions
+		tester.Request("GET", "posts/"+id+"/versions", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["A","B"]`, gjson.Get(r.Body.String(), "data.#.attributes.title").Raw)
+			assert.Equal(t, `[1,2]`, gjson.Get(r.Body.String(), "data.#.meta.version").Raw)
+			assert.Equal(t, `["Update","Update"]`, gjson.Get(r.Body.String(), "data.#.meta.operation").Raw)
+			assert.Equal(t, id, gjson.Get(r.Body.String(), "data.0.id").String())
+			assert.False(t, gjson.Get(r.Body.String(), "data.0.attributes.text-body").Exists())
+		})
+
+		// list versions paginated
+		tester.Request("GET", "posts/"+id+"/versions?page[number]=2&page[size]=1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["B"]`, gjson.Get(r.Body.String(), "data.#.attributes.title").Raw)
+			assert.Equal(t, `[2]`, gjson.Get(r.Body.String(), "data.#.meta.version").Raw)
+			assert.Equal(t, "/posts/"+id+"/versions?page%5Bnumber%5D=1&page%5Bsize%5D=1", gjson.Get(r.Body.String(), "links.first").String())
+			assert.Equal(t, "/posts/"+id+"/versions?page%5Bnumber%5D=1&page%5Bsize%5D=1", gjson.Get(r.Body.String(), "links.prev").String())
+			assert.Equal(t, "/posts/"+id+"/versions?page%5Bnumber%5D=2&page%5Bsize%5D=1", gjson.Get(r.Body.String(), "links.last").String())
+			assert.False(t, gjson.Get(r.Body.String(), "links.next").Exists())
+		})
+
+		// list versions with unsupported pagination
+		tester.Request("GET", "posts/"+id+"/versions?page[offset]=1&page[limit]=1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// get version
+		tester.Request("GET", "posts/"+id+"/versions/1", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "data.attributes.title").String())
+			assert.Equal(t, int64(1), gjson.Get(r.Body.String(), "data.meta.version").Int())
+		})
+
+		// get missing version
+		tester.Request("GET", "posts/"+id+"/versions/3", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// get invalid version
+		tester.Request("GET", "posts/"+id+"/versions/foo", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// change read-only field
+		tester.Update(post, bson.M{"$set": bson.M{"published": true}})
+
+		// restore version
+		tester.Request("POST", "posts/"+id+"/restore", `{"version":1}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "data.attributes.title").String())
+			assert.True(t, gjson.Get(r.Body.String(), "data.attributes.published").Bool())
+		})
+
+		assert.Equal(t, "A", tester.Fetch(&postModel{}, post.ID()).(*postModel).Title)
+
+		// restore missing version
+		tester.Request("POST", "posts/"+id+"/restore", `{"version":7}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		tester.Request("GET", "posts/"+id+"/versions", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, `["A","B","C"]`, gjson.Get(r.Body.String(), "data.#.attributes.title").Raw)
+		})
+
+		// restore version with validators
+		tester.Update(post, bson.M{"$set": bson.M{"title": "Locked"}})
+		tester.Request("POST", "posts/"+id+"/restore", `{"version":2}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "post is locked", gjson.Get(r.Body.String(), "errors.0.detail").String())
+		})
+
+		// remove first version
+		_, err = tester.Store.DB().Collection("posts_versions").DeleteOne(tester.Context, bson.M{
+			"resource": post.ID(),
+			"number":   1,
+		})
+		assert.NoError(t, err)
+
+		// delete post
+		tester.Request("DELETE", "posts/"+id, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		var versions []Version
+		cursor, err := tester.Store.DB().Collection("posts_versions").Find(tester.Context, bson.M{
+			"resource": post.ID(),
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, cursor.All(tester.Context, &versions))
+		assert.Len(t, versions, 3)
+		assert.Equal(t, 4, versions[2].Number)
+		assert.Equal(t, "Delete", versions[2].Operation)
+		assert.Equal(t, "Locked", versions[2].Data["title"])
+
+		// insert duplicate version
+		_, err = tester.Store.DB().Collection("posts_versions").InsertOne(tester.Context, &Version{
+			Base:      coal.B(),
+			Resource:  post.ID(),
+			Number:    4,
+			Operation: "Update",
+			Time:      time.Now(),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestVersioningConsistentUpdate(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		_, err := tester.Store.DB().Collection("selections_versions").DeleteMany(tester.Context, bson.M{})
+		assert.NoError(t, err)
+
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			Versioning:       true,
+			ConsistentUpdate: true,
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		// create selection
+		var id, token string
+		tester.Request("POST", "selections", `{
+			"data": {
+				"type": "selections",
+				"attributes": {
+					"name": "A"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			id = gjson.Get(r.Body.String(), "data.id").String()
+			token = gjson.Get(r.Body.String(), "data.attributes.update-token").String()
+		})
+
+		// update selection
+		tester.Request("PATCH", "selections/"+id, `{
+			"data": {
+				"type": "selections",
+				"id": "`+id+`",
+				"attributes": {
+					"name": "B",
+					"update-token": "`+token+`"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.NotEqual(t, token, gjson.Get(r.Body.String(), "data.attributes.update-token").String())
+		})
+
+		// restore version
+		tester.Request("POST", "selections/"+id+"/restore", `{"version":1}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "A", gjson.Get(r.Body.String(), "data.attributes.name").String())
+		})
+
+		assert.Equal(t, "A", tester.Fetch(&selectionModel{}, coal.MustFromHex(id)).(*selectionModel).Name)
+	})
+}
@@ -0,0 +1,286 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/axe"
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/heat"
+	"github.com/256dpi/fire/stick"
+)
+
+// The headers set on deliveries.
+const (
+	EventHeader     = "Webhook-Event"
+	DeliveryHeader  = "Webhook-Delivery"
+	SignatureHeader = "Webhook-Signature"
+)
+
+// DeliveryJob is the job enqueued to deliver an event.
+type DeliveryJob struct {
+	axe.Base           `json:"-" axe:"webhook/deliver"`
+	stick.NoValidation `json:"-"`
+}
+
+// Dispatcher manages the delivery of events to webhooks.
+type Dispatcher struct {
+	store  *coal.Store
+	client *http.Client
+}
+
+// NewDispatcher creates and returns a new dispatcher.
+func NewDispatcher(store *coal.Store, client *http.Client) *Dispatcher {
+	// set default client
+	if client == nil {
+		client = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+
+	return &Dispatcher{
+		store:  store,
+		client: client,
+	}
+}
+
+// Notifier returns a callback that creates a delivery and enqueues a delivery
+// job for every webhook subscribed to the created, updated or deleted resource.
+// The payload is the JSON:API document of the resource or the resource
+// identifier for deleted resources. The controller must use the dispatcher
+// store to create deliveries in the same transaction as the modification.
+// Otherwise, the callback fails with an error.
+func (d *Dispatcher) Notifier() *fire.Callback {
+	return fire.C("webhook/Dispatcher.Notifier", fire.Notifier, fire.Only(fire.Create|fire.Update|fire.Delete), func(ctx *fire.Context) error {
+		// get event
+		typ := coal.GetMeta(ctx.Model).PluralName
+		event := typ + "." + strings.ToLower(ctx.Operation.String())
+
+		// check store
+		if ctx.Controller.Store != d.store {
+			return xo.F("webhook: dispatcher store does not match controller store")
+		}
+
+		// find webhooks
+		var webhooks []Webhook
+		err := d.store.M(&Webhook{}).FindAll(ctx, &webhooks, bson.M{
+			"Events": event,
+		}, nil, 0, 0, false)
+		if err != nil {
+			return err
+		}
+
+		// check webhooks
+		if len(webhooks) == 0 {
+			return nil
+		}
+
+		// prepare document
+		doc := &jsonapi.Document{
+			Data: &jsonapi.HybridResource{
+				One: &jsonapi.Resource{
+					Type: typ,
+					ID:   ctx.Model.ID().Hex(),
+				},
+			},
+		}
+		if ctx.Response != nil && ctx.Response.Data != nil && ctx.Response.Data.One != nil {
+			doc.Data.One = ctx.Response.Data.One
+		}
+
+		// encode payload
+		payload, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		// create deliveries
+		for _, webhook := range webhooks {
+			// insert delivery
+			delivery := &Delivery{
+				Base:     coal.B(),
+				Webhook:  webhook.ID(),
+				Event:    event,
+				Payload:  string(payload),
+				Created:  time.Now(),
+				Attempts: []Attempt{},
+			}
+			err = d.store.M(delivery).Insert(ctx, delivery)
+			if err != nil {
+				return err
+			}
+
+			// enqueue job
+			_, err = axe.Enqueue(ctx, d.store, &DeliveryJob{
+				Base: axe.B(delivery.ID().Hex()),
+			}, 0, 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Deliver will attempt to deliver the specified delivery and record the
+// attempt. It will return an error if the delivery failed.
+func (d *Dispatcher) Deliver(ctx context.Context, id coal.ID) error {
+	// trace
+	ctx, span := xo.Trace(ctx, "webhook/Dispatcher.Deliver")
+	defer span.End()
+
+	// get delivery
+	var delivery Delivery
+	found, err := d.store.M(&delivery).Find(ctx, &delivery, id, false)
+	if err != nil {
+		return err
+	} else if !found {
+		return xo.F("missing delivery")
+	}
+
+	// check state
+	if delivery.Delivered != nil {
+		return nil
+	}
+
+	// get webhook
+	var webhook Webhook
+	found, err = d.store.M(&webhook).Find(ctx, &webhook, delivery.Webhook, false)
+	if err != nil {
+		return err
+	} else if !found {
+		return xo.F("missing webhook")
+	}
+
+	// prepare request
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return err
+	}
+
+	// set headers
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", jsonapi.MediaType)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID().Hex())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	// perform request
+	start := time.Now()
+	attempt := Attempt{
+		Time: start,
+	}
+	res, err := d.client.Do(req)
+	if err == nil {
+		_ = res.Body.Close()
+		attempt.Status = res.StatusCode
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			err = xo.F("unexpected status %d", res.StatusCode)
+		}
+	}
+	attempt.Duration = time.Since(start)
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	// prepare update
+	update := bson.M{
+		"$push": bson.M{
+			"Attempts": attempt,
+		},
+	}
+	if err == nil {
+		update["$set"] = bson.M{
+			"Delivered": time.Now(),
+		}
+	}
+
+	// record attempt
+	_, updateErr := d.store.M(&delivery).Update(ctx, nil, delivery.ID(), update, false)
+	if updateErr != nil {
+		return updateErr
+	}
+
+	return err
+}
+
+// DeliveryTask returns a task that delivers enqueued deliveries. Failed
+// deliveries are retried with an exponential backoff until the specified
+// maximum attempts have been reached. Zero means that deliveries are retried
+// forever.
+func (d *Dispatcher) DeliveryTask(maxAttempts int) *axe.Task {
+	return &axe.Task{
+		Job:         &DeliveryJob{},
+		MaxAttempts: maxAttempts,
+		Handler: func(ctx *axe.Context) error {
+			// get job
+			job := ctx.Job.(*DeliveryJob)
+
+			// get delivery
+			id, err := coal.FromHex(job.Label)
+			if err != nil {
+				return axe.E("invalid delivery", false)
+			}
+
+			// deliver
+			err = d.Deliver(ctx, id)
+			if err != nil {
+				return axe.E(err.Error(), maxAttempts == 0 || ctx.Attempt < maxAttempts)
+			}
+
+			return nil
+		},
+	}
+}
+
+// Sign will return the signature for the provided payload. The signing key is
+// derived from the webhook secret and the signature has the form
+// "t=<timestamp>,v1=<hex hmac-sha256 of timestamp.payload>".
+func Sign(secret string, timestamp int64, payload []byte) string {
+	// derive key
+	key := heat.Secret(secret).Derive("webhook")
+
+	// compute mac
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(payload)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify will verify the provided signature for the payload. Signatures older
+// than the specified tolerance are rejected if the tolerance is positive.
+func Verify(secret, signature string, payload []byte, tolerance time.Duration) bool {
+	// parse timestamp
+	var timestamp int64
+	for _, part := range strings.Split(signature, ",") {
+		if value, ok := strings.CutPrefix(part, "t="); ok {
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if timestamp == 0 {
+		return false
+	}
+
+	// check tolerance
+	if tolerance > 0 && time.Since(time.Unix(timestamp, 0)) > tolerance {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload)))
+}
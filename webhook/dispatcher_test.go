@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/axe"
+	"github.com/256dpi/fire/coal"
+)
+
+func TestDispatcher(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *fire.Tester) {
+		type request struct {
+			header http.Header
+			body   string
+		}
+
+		requests := make(chan request, 10)
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests <- request{header: r.Header, body: string(body)}
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer server.Close()
+
+		dispatcher := NewDispatcher(tester.Store, nil)
+
+		tester.Assign("", &fire.Controller{
+			Model: &itemModel{},
+			Notifiers: fire.L{
+				dispatcher.Notifier(),
+			},
+		})
+
+		webhook := tester.Insert(&Webhook{
+			URL:    server.URL,
+			Secret: "0123456789abcdef",
+			Events: []string{"items.create", "items.delete"},
+		}).(*Webhook)
+
+		// create item
+		var id string
+		tester.Request("POST", "items", `{
+			"data": {
+				"type": "items",
+				"attributes": {
+					"name": "Foo"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			id = gjson.Get(r.Body.String(), "data.id").String()
+		})
+
+		// update item
+		tester.Request("PATCH", "items/"+id, `{
+			"data": {
+				"type": "items",
+				"id": "`+id+`",
+				"attributes": {
+					"name": "Bar"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, 1, tester.Count(&Delivery{}))
+		assert.Equal(t, 1, tester.Count(&axe.Model{}))
+
+		delivery := tester.FindLast(&Delivery{}).(*Delivery)
+		assert.Equal(t, webhook.ID(), delivery.Webhook)
+		assert.Equal(t, "items.create", delivery.Event)
+		assert.Equal(t, id, gjson.Get(delivery.Payload, "data.id").String())
+		assert.Equal(t, "Foo", gjson.Get(delivery.Payload, "data.attributes.name").String())
+
+		queue := axe.NewQueue(axe.Options{
+			Store:    tester.Store,
+			Reporter: xo.Crash,
+		})
+
+		task := dispatcher.DeliveryTask(3)
+		task.MinDelay = 10 * time.Millisecond
+
+		done := make(chan bool, 1)
+		task.Notifier = func(ctx *axe.Context, cancelled bool, reason string) error {
+			done <- cancelled
+			return nil
+		}
+
+		queue.Add(task)
+		<-queue.Run()
+		defer queue.Close()
+
+		assert.False(t, <-done)
+
+		req1 := <-requests
+		req2 := <-requests
+		assert.Equal(t, req1.body, req2.body)
+		assert.Equal(t, delivery.Payload, req2.body)
+		assert.Equal(t, "items.create", req2.header.Get(EventHeader))
+		assert.Equal(t, delivery.ID().Hex(), req2.header.Get(DeliveryHeader))
+		assert.True(t, Verify(webhook.Secret, req2.header.Get(SignatureHeader), []byte(req2.body), time.Minute))
+		assert.False(t, Verify("foo", req2.header.Get(SignatureHeader), []byte(req2.body), time.Minute))
+
+		delivery = tester.Fetch(&Delivery{}, delivery.ID()).(*Delivery)
+		assert.NotNil(t, delivery.Delivered)
+		assert.Len(t, delivery.Attempts, 2)
+		assert.Equal(t, http.StatusInternalServerError, delivery.Attempts[0].Status)
+		assert.Equal(t, "unexpected status 500", delivery.Attempts[0].Error)
+		assert.Equal(t, http.StatusOK, delivery.Attempts[1].Status)
+		assert.Empty(t, delivery.Attempts[1].Error)
+
+		// delete item
+		tester.Request("DELETE", "items/"+id, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNoContent, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.False(t, <-done)
+
+		req3 := <-requests
+		assert.Equal(t, "items.delete", req3.header.Get(EventHeader))
+		assert.JSONEq(t, `{
+			"data": {
+				"type": "items",
+				"id": "`+id+`"
+			}
+		}`, req3.body)
+	})
+}
+
+func TestDispatcherStoreMismatch(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *fire.Tester) {
+		dispatcher := NewDispatcher(coal.MustOpen(nil, "test-fire-webhook-other", xo.Crash), nil)
+
+		tester.Assign("", &fire.Controller{
+			Model: &itemModel{},
+			Notifiers: fire.L{
+				dispatcher.Notifier(),
+			},
+		})
+
+		assert.Panics(t, func() {
+			tester.Request("POST", "items", `{
+				"data": {
+					"type": "items",
+					"attributes": {
+						"name": "Foo"
+					}
+				}
+			}`, nil)
+		})
+
+		assert.Equal(t, 0, tester.Count(&itemModel{}))
+		assert.Equal(t, 0, tester.Count(&Delivery{}))
+	})
+}
+
+func TestSignature(t *testing.T) {
+	signature := Sign("secret", 1234, []byte("payload"))
+	assert.Equal(t, Sign("secret", 1234, []byte("payload")), signature)
+	assert.NotEqual(t, Sign("secret", 1235, []byte("payload")), signature)
+	assert.NotEqual(t, Sign("secret", 1234, []byte("payload!")), signature)
+
+	assert.True(t, Verify("secret", signature, []byte("payload"), 0))
+	assert.False(t, Verify("secret", signature, []byte("payload"), time.Minute))
+	assert.False(t, Verify("secret", signature, []byte("foo"), 0))
+	assert.False(t, Verify("foo", signature, []byte("payload"), 0))
+	assert.False(t, Verify("secret", "foo", []byte("payload"), 0))
+
+	signature = Sign("secret", time.Now().Unix(), []byte("payload"))
+	assert.True(t, Verify("secret", signature, []byte("payload"), time.Minute))
+}
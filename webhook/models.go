@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+func init() {
+	// add indexes
+	coal.AddIndex(&Webhook{}, false, 0, "Events")
+	coal.AddIndex(&Delivery{}, false, 0, "Webhook")
+	coal.AddIndex(&Delivery{}, false, 0, "Created")
+}
+
+// Webhook is a subscription to resource events.
+type Webhook struct {
+	coal.Base `json:"-" bson:",inline" coal:"webhooks"`
+
+	// The URL that receives the events.
+	URL string `json:"url"`
+
+	// The secret used to sign deliveries.
+	Secret string `json:"secret"`
+
+	// The subscribed events in the form "<type>.<operation>" e.g.
+	// "posts.create", "posts.update" or "posts.delete".
+	Events []string `json:"events"`
+}
+
+// Validate will validate the model.
+func (w *Webhook) Validate() error {
+	return stick.Validate(w, func(v *stick.Validator) {
+		v.Value("URL", false, stick.IsNotZero, stick.IsURL(true))
+		v.Value("Secret", false, stick.IsNotZero, stick.IsMinLen(16))
+		v.Value("Events", false, stick.IsNotEmpty)
+		v.Items("Events", stick.IsPatternMatch(`^[a-z0-9-]+\.(create|update|delete)$`))
+	})
+}
+
+// Attempt describes a single delivery attempt.
+type Attempt struct {
+	// The time when the attempt was made.
+	Time time.Time `json:"time"`
+
+	// The received status code, if any.
+	Status int `json:"status"`
+
+	// The error, if any.
+	Error string `json:"error"`
+
+	// The duration of the attempt.
+	Duration time.Duration `json:"duration"`
+}
+
+// Delivery stores an event that is delivered to a webhook.
+type Delivery struct {
+	coal.Base `json:"-" bson:",inline" coal:"webhook-deliveries:webhook_deliveries"`
+
+	// The webhook that receives the event.
+	Webhook coal.ID `json:"-" bson:"webhook_id" coal:"webhook:webhooks"`
+
+	// The delivered event e.g. "posts.create".
+	Event string `json:"event"`
+
+	// The JSON encoded payload.
+	Payload string `json:"payload"`
+
+	// The time when the delivery was created.
+	Created time.Time `json:"created-at" bson:"created_at"`
+
+	// The time when the delivery succeeded.
+	Delivered *time.Time `json:"delivered-at" bson:"delivered_at"`
+
+	// The individual delivery attempts.
+	Attempts []Attempt `json:"attempts"`
+}
+
+// Validate will validate the model.
+func (d *Delivery) Validate() error {
+	return stick.Validate(d, func(v *stick.Validator) {
+		v.Value("Webhook", false, stick.IsNotZero)
+		v.Value("Event", false, stick.IsNotZero)
+		v.Value("Payload", false, stick.IsNotZero)
+		v.Value("Created", false, stick.IsNotZero)
+		v.Value("Delivered", true, stick.IsNotZero)
+	})
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/axe"
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+var mongoStore = coal.MustConnect("mongodb://0.0.0.0/test-fire-webhook", xo.Crash)
+var lungoStore = coal.MustOpen(nil, "test-fire-webhook", xo.Crash)
+
+var modelList = []coal.Model{&Webhook{}, &Delivery{}, &axe.Model{}, &itemModel{}}
+
+type itemModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"items"`
+	Name               string `json:"name"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+func withTester(t *testing.T, fn func(*testing.T, *fire.Tester)) {
+	t.Run("Mongo", func(t *testing.T) {
+		tester := fire.NewTester(mongoStore, modelList...)
+		tester.Clean()
+		fn(t, tester)
+	})
+
+	t.Run("Lungo", func(t *testing.T) {
+		tester := fire.NewTester(lungoStore, modelList...)
+		tester.Clean()
+		fn(t, tester)
+	})
+}